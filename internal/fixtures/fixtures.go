@@ -0,0 +1,69 @@
+/*
+   Copyright Farcloser.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// Package fixtures provides the filesystem layouts of real hosts, to test host probing against.
+// Layouts only hold the files that are probed, under their usual location (eg: /sys/fs/cgroup, /proc/self).
+package fixtures
+
+import (
+	"path/filepath"
+	"runtime"
+)
+
+const (
+	// CgroupV1Legacy is a RHEL 7 like host, with co-mounted v1 controllers (cpu,cpuacct) and no cgroup namespaces.
+	CgroupV1Legacy = "cgroupv1-legacy"
+	// CgroupV1Hybrid is a hybrid host, with v2 mounted at /sys/fs/cgroup/unified, and a kernel lacking swap and
+	// kernel memory accounting, blkio weights, pids and devices.
+	CgroupV1Hybrid = "cgroupv1-hybrid"
+	// CgroupV2Systemd is an Ubuntu 22.04 like host, in unified mode, with systemd and AppArmor.
+	// The session scope of the process uses the BFQ I/O scheduler, and has no utilization clamping.
+	CgroupV2Systemd = "cgroupv2-systemd"
+	// CgroupV2NoSystemd is an Alpine like host, in unified mode, booted with OpenRC and IPv4 forwarding disabled.
+	CgroupV2NoSystemd = "cgroupv2-nosystemd"
+	// CgroupV2Rootless is a Fedora like host, as seen from the user namespace of a rootless engine, whose cgroup
+	// is under user@1000.service, with systemd default delegation (memory and pids only).
+	CgroupV2Rootless = "cgroupv2-rootless"
+	// NestedNoCgroupNS is a Docker container running with the cgroup namespace of the host (--cgroupns=host), on a
+	// unified host: the group of the container is bind mounted at /sys/fs/cgroup.
+	NestedNoCgroupNS = "nested-nocgroupns"
+	// NestedCgroupNS is a Docker container running systemd with a private cgroup namespace, on a unified host: the
+	// process is in the init.scope group of the container.
+	NestedCgroupNS = "nested-cgroupns"
+	// WSL is a WSL2 like host, with all v1 controllers mounted separately, no systemd and no AppArmor.
+	WSL = "wsl"
+)
+
+// Hosts returns the name of all the available layouts.
+func Hosts() []string {
+	return []string{
+		CgroupV1Legacy,
+		CgroupV1Hybrid,
+		CgroupV2Systemd,
+		CgroupV2NoSystemd,
+		CgroupV2Rootless,
+		NestedNoCgroupNS,
+		NestedCgroupNS,
+		WSL,
+	}
+}
+
+// Host returns the absolute path of the root of the layout `name`.
+func Host(name string) string {
+	_, file, _, _ := runtime.Caller(0) //nolint:dogsled
+
+	return filepath.Join(filepath.Dir(file), "hosts", name)
+}
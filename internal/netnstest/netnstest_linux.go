@@ -0,0 +1,68 @@
+/*
+   Copyright Farcloser.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// Package netnstest creates network namespaces for tests, in temporary stores that are cleaned up when tests end.
+package netnstest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/sys/unix"
+	"gotest.tools/v3/assert"
+
+	"go.farcloser.world/containers/netns"
+)
+
+// Store returns a namespace store in a temporary directory, which is unmounted when the test ends.
+// The test is skipped when not running as root.
+func Store(t *testing.T) *netns.Store {
+	t.Helper()
+
+	if os.Geteuid() != 0 {
+		t.Skip("test requires root")
+	}
+
+	dir := filepath.Join(t.TempDir(), "netns")
+	t.Cleanup(func() {
+		_ = unix.Unmount(dir, unix.MNT_DETACH)
+	})
+
+	return netns.NewStore(dir)
+}
+
+// Namespaces creates the namespaces `names` in a new Store. They are closed and deleted when the test ends.
+func Namespaces(t *testing.T, names ...string) []*netns.Namespace {
+	t.Helper()
+
+	store := Store(t)
+	namespaces := make([]*netns.Namespace, 0, len(names))
+
+	for _, name := range names {
+		namespace, err := store.Create(name)
+		assert.NilError(t, err)
+
+		t.Cleanup(func() {
+			_ = namespace.Close()
+			_ = store.Delete(name)
+		})
+
+		namespaces = append(namespaces, namespace)
+	}
+
+	return namespaces
+}
@@ -0,0 +1,93 @@
+/*
+   Copyright Farcloser.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package netlink
+
+import (
+	"errors"
+	"net"
+)
+
+var (
+	ErrUnsupported  = errors.New("network configuration is only supported on linux")
+	ErrAddressFail  = errors.New("unable to configure address")
+	ErrRouteFail    = errors.New("unable to configure route")
+	ErrRuleFail     = errors.New("unable to configure routing rule")
+	ErrNeighborFail = errors.New("unable to configure neighbor")
+	ErrInvalidScope = errors.New("invalid scope")
+)
+
+// Address is an IP address assigned to an interface.
+type Address struct {
+	Interface string
+	// IPNet is the address, with the mask of its subnet (eg: 10.4.0.2/24).
+	IPNet     *net.IPNet
+	Broadcast net.IP
+	Label     string
+	// Scope is one of "global", "site", "link", "host" or "nowhere".
+	Scope string
+}
+
+// Route is an entry of a routing table.
+type Route struct {
+	// Interface is the outgoing interface. It may be left empty when adding a route via a gateway.
+	Interface string
+	// Destination is nil for the default route.
+	Destination *net.IPNet
+	Gateway     net.IP
+	// Source is the preferred source address.
+	Source net.IP
+	Metric int
+	// Table is the main table if 0.
+	Table int
+	// Scope is one of "global", "site", "link", "host" or "nowhere". The kernel decides if empty.
+	Scope string
+}
+
+// Rule is a routing policy rule.
+type Rule struct {
+	// Priority is chosen by the kernel if 0 when adding.
+	Priority          int
+	Table             int
+	Source            *net.IPNet
+	Destination       *net.IPNet
+	IncomingInterface string
+	OutgoingInterface string
+	Mark              uint32
+	Invert            bool
+	// IPv6 selects the family of rules without source or destination.
+	IPv6 bool
+}
+
+// Neighbor is an entry of the ARP or NDP table.
+type Neighbor struct {
+	Interface string
+	IP        net.IP
+	MAC       net.HardwareAddr
+	// State is one of "permanent", "reachable", "stale", etc. Entries are added as "permanent".
+	State string
+}
+
+// InterfaceConfig is an interface of a namespace, with its addresses.
+type InterfaceConfig struct {
+	Name      string
+	Index     int
+	Type      string
+	MAC       net.HardwareAddr
+	MTU       int
+	Up        bool
+	Addresses []Address
+}
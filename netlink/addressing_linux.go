@@ -0,0 +1,484 @@
+/*
+   Copyright Farcloser.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package netlink
+
+import (
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+
+	"go.farcloser.world/containers/netns"
+)
+
+var (
+	scopes = map[netlink.Scope]string{
+		netlink.SCOPE_UNIVERSE: "global",
+		netlink.SCOPE_SITE:     "site",
+		netlink.SCOPE_LINK:     "link",
+		netlink.SCOPE_HOST:     "host",
+		netlink.SCOPE_NOWHERE:  "nowhere",
+	}
+
+	neighborStates = map[int]string{
+		netlink.NUD_INCOMPLETE: "incomplete",
+		netlink.NUD_REACHABLE:  "reachable",
+		netlink.NUD_STALE:      "stale",
+		netlink.NUD_DELAY:      "delay",
+		netlink.NUD_PROBE:      "probe",
+		netlink.NUD_FAILED:     "failed",
+		netlink.NUD_NOARP:      "noarp",
+		netlink.NUD_PERMANENT:  "permanent",
+	}
+)
+
+// AddAddress assigns `addr` to the interface `iface` of namespace `ns`, nil standing for the current namespace.
+// IPv6 addresses skip duplicate address detection, so that they are usable right away.
+func AddAddress(ns *netns.Namespace, iface string, addr *net.IPNet) error {
+	return withHandle(ns, ErrAddressFail, func(handle *netlink.Handle) error {
+		link, err := linkByName(handle, iface)
+		if err != nil {
+			return err
+		}
+
+		nlAddr := &netlink.Addr{IPNet: addr}
+		if addr.IP.To4() == nil {
+			nlAddr.Flags = unix.IFA_F_NODAD
+		}
+
+		return handle.AddrAdd(link, nlAddr)
+	})
+}
+
+// RemoveAddress removes `addr` from the interface `iface` of namespace `ns`.
+func RemoveAddress(ns *netns.Namespace, iface string, addr *net.IPNet) error {
+	return withHandle(ns, ErrAddressFail, func(handle *netlink.Handle) error {
+		link, err := linkByName(handle, iface)
+		if err != nil {
+			return err
+		}
+
+		return handle.AddrDel(link, &netlink.Addr{IPNet: addr})
+	})
+}
+
+// ListAddresses returns the addresses of the interface `iface` of namespace `ns`, or of all interfaces if empty.
+func ListAddresses(ns *netns.Namespace, iface string) (addresses []Address, err error) {
+	err = withHandle(ns, ErrAddressFail, func(handle *netlink.Handle) error {
+		var link netlink.Link
+
+		if iface != "" {
+			if link, err = linkByName(handle, iface); err != nil {
+				return err
+			}
+		}
+
+		names, err := linkNames(handle)
+		if err != nil {
+			return err
+		}
+
+		nlAddrs, err := handle.AddrList(link, netlink.FAMILY_ALL)
+		if err != nil {
+			return err
+		}
+
+		for _, nlAddr := range nlAddrs {
+			addresses = append(addresses, addressFrom(nlAddr, names))
+		}
+
+		return nil
+	})
+
+	return addresses, err
+}
+
+// ListInterfaces returns all the interfaces of namespace `ns`, with their addresses.
+func ListInterfaces(ns *netns.Namespace) (interfaces []InterfaceConfig, err error) {
+	err = withHandle(ns, ErrAddressFail, func(handle *netlink.Handle) error {
+		links, err := handle.LinkList()
+		if err != nil {
+			return err
+		}
+
+		nlAddrs, err := handle.AddrList(nil, netlink.FAMILY_ALL)
+		if err != nil {
+			return err
+		}
+
+		names := make(map[int]string, len(links))
+		for _, link := range links {
+			names[link.Attrs().Index] = link.Attrs().Name
+		}
+
+		byIndex := map[int][]Address{}
+		for _, nlAddr := range nlAddrs {
+			byIndex[nlAddr.LinkIndex] = append(byIndex[nlAddr.LinkIndex], addressFrom(nlAddr, names))
+		}
+
+		for _, link := range links {
+			attrs := link.Attrs()
+			interfaces = append(interfaces, InterfaceConfig{
+				Name:      attrs.Name,
+				Index:     attrs.Index,
+				Type:      link.Type(),
+				MAC:       attrs.HardwareAddr,
+				MTU:       attrs.MTU,
+				Up:        attrs.Flags&net.FlagUp != 0,
+				Addresses: byIndex[attrs.Index],
+			})
+		}
+
+		return nil
+	})
+
+	return interfaces, err
+}
+
+// AddRoute adds `route` to namespace `ns`. It fails if an identical route exists.
+func AddRoute(ns *netns.Namespace, route Route) error {
+	return withHandle(ns, ErrRouteFail, func(handle *netlink.Handle) error {
+		nlRoute, err := routeTo(handle, route)
+		if err != nil {
+			return err
+		}
+
+		return handle.RouteAdd(nlRoute)
+	})
+}
+
+// SetDefaultRoute sets the default route of namespace `ns` to go through `gateway`, replacing any existing one of
+// the same family. `iface` may be left empty.
+func SetDefaultRoute(ns *netns.Namespace, iface string, gateway net.IP) error {
+	return withHandle(ns, ErrRouteFail, func(handle *netlink.Handle) error {
+		nlRoute, err := routeTo(handle, Route{Interface: iface, Gateway: gateway})
+		if err != nil {
+			return err
+		}
+
+		return handle.RouteReplace(nlRoute)
+	})
+}
+
+// RemoveRoute removes `route` from namespace `ns`.
+func RemoveRoute(ns *netns.Namespace, route Route) error {
+	return withHandle(ns, ErrRouteFail, func(handle *netlink.Handle) error {
+		nlRoute, err := routeTo(handle, route)
+		if err != nil {
+			return err
+		}
+
+		return handle.RouteDel(nlRoute)
+	})
+}
+
+// ListRoutes returns the IPv4 and IPv6 routes of the main table of namespace `ns`, going through the interface
+// `iface`, or through any interface if empty.
+func ListRoutes(ns *netns.Namespace, iface string) (routes []Route, err error) {
+	err = withHandle(ns, ErrRouteFail, func(handle *netlink.Handle) error {
+		var link netlink.Link
+
+		if iface != "" {
+			if link, err = linkByName(handle, iface); err != nil {
+				return err
+			}
+		}
+
+		names, err := linkNames(handle)
+		if err != nil {
+			return err
+		}
+
+		for _, family := range []int{netlink.FAMILY_V4, netlink.FAMILY_V6} {
+			nlRoutes, err := handle.RouteList(link, family)
+			if err != nil {
+				return err
+			}
+
+			for _, nlRoute := range nlRoutes {
+				routes = append(routes, routeFrom(nlRoute, names))
+			}
+		}
+
+		return nil
+	})
+
+	return routes, err
+}
+
+// AddRule adds `rule` to namespace `ns`.
+func AddRule(ns *netns.Namespace, rule Rule) error {
+	return withHandle(ns, ErrRuleFail, func(handle *netlink.Handle) error {
+		return handle.RuleAdd(ruleTo(rule))
+	})
+}
+
+// RemoveRule removes the first rule of namespace `ns` matching `rule`.
+func RemoveRule(ns *netns.Namespace, rule Rule) error {
+	return withHandle(ns, ErrRuleFail, func(handle *netlink.Handle) error {
+		return handle.RuleDel(ruleTo(rule))
+	})
+}
+
+// ListRules returns the IPv4 and IPv6 rules of namespace `ns`.
+func ListRules(ns *netns.Namespace) (rules []Rule, err error) {
+	err = withHandle(ns, ErrRuleFail, func(handle *netlink.Handle) error {
+		for _, family := range []int{netlink.FAMILY_V4, netlink.FAMILY_V6} {
+			nlRules, err := handle.RuleList(family)
+			if err != nil {
+				return err
+			}
+
+			for _, nlRule := range nlRules {
+				rules = append(rules, Rule{
+					Priority:          max(nlRule.Priority, 0),
+					Table:             nlRule.Table,
+					Source:            nlRule.Src,
+					Destination:       nlRule.Dst,
+					IncomingInterface: nlRule.IifName,
+					OutgoingInterface: nlRule.OifName,
+					Mark:              nlRule.Mark,
+					Invert:            nlRule.Invert,
+					IPv6:              family == netlink.FAMILY_V6,
+				})
+			}
+		}
+
+		return nil
+	})
+
+	return rules, err
+}
+
+// SetNeighbor adds a permanent entry for `neighbor` to namespace `ns`, replacing any existing one.
+func SetNeighbor(ns *netns.Namespace, neighbor Neighbor) error {
+	return withHandle(ns, ErrNeighborFail, func(handle *netlink.Handle) error {
+		link, err := linkByName(handle, neighbor.Interface)
+		if err != nil {
+			return err
+		}
+
+		return handle.NeighSet(&netlink.Neigh{
+			LinkIndex:    link.Attrs().Index,
+			Family:       family(neighbor.IP),
+			State:        netlink.NUD_PERMANENT,
+			IP:           neighbor.IP,
+			HardwareAddr: neighbor.MAC,
+		})
+	})
+}
+
+// RemoveNeighbor removes the entry for `neighbor` from namespace `ns`.
+func RemoveNeighbor(ns *netns.Namespace, neighbor Neighbor) error {
+	return withHandle(ns, ErrNeighborFail, func(handle *netlink.Handle) error {
+		link, err := linkByName(handle, neighbor.Interface)
+		if err != nil {
+			return err
+		}
+
+		return handle.NeighDel(&netlink.Neigh{
+			LinkIndex: link.Attrs().Index,
+			Family:    family(neighbor.IP),
+			IP:        neighbor.IP,
+		})
+	})
+}
+
+// ListNeighbors returns the neighbors of the interface `iface` of namespace `ns`, or of all interfaces if empty.
+func ListNeighbors(ns *netns.Namespace, iface string) (neighbors []Neighbor, err error) {
+	err = withHandle(ns, ErrNeighborFail, func(handle *netlink.Handle) error {
+		index := 0
+
+		if iface != "" {
+			link, err := linkByName(handle, iface)
+			if err != nil {
+				return err
+			}
+
+			index = link.Attrs().Index
+		}
+
+		names, err := linkNames(handle)
+		if err != nil {
+			return err
+		}
+
+		nlNeighs, err := handle.NeighList(index, netlink.FAMILY_ALL)
+		if err != nil {
+			return err
+		}
+
+		for _, nlNeigh := range nlNeighs {
+			state, ok := neighborStates[nlNeigh.State]
+			if !ok {
+				state = "none"
+			}
+
+			neighbors = append(neighbors, Neighbor{
+				Interface: names[nlNeigh.LinkIndex],
+				IP:        nlNeigh.IP,
+				MAC:       nlNeigh.HardwareAddr,
+				State:     state,
+			})
+		}
+
+		return nil
+	})
+
+	return neighbors, err
+}
+
+func withHandle(ns *netns.Namespace, failure error, fn func(handle *netlink.Handle) error) error {
+	handle, err := newHandle(ns)
+	if err != nil {
+		return errors.Join(failure, err)
+	}
+	defer handle.Close()
+
+	if err = fn(handle); err != nil {
+		return errors.Join(failure, err)
+	}
+
+	return nil
+}
+
+func linkByName(handle *netlink.Handle, name string) (netlink.Link, error) { //nolint:ireturn // netlink links are interfaces
+	link, err := handle.LinkByName(name)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %w", ErrLinkNotFound, name, err)
+	}
+
+	return link, nil
+}
+
+func linkNames(handle *netlink.Handle) (map[int]string, error) {
+	links, err := handle.LinkList()
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[int]string, len(links))
+	for _, link := range links {
+		names[link.Attrs().Index] = link.Attrs().Name
+	}
+
+	return names, nil
+}
+
+func addressFrom(nlAddr netlink.Addr, names map[int]string) Address {
+	return Address{
+		Interface: names[nlAddr.LinkIndex],
+		IPNet:     nlAddr.IPNet,
+		Broadcast: nlAddr.Broadcast,
+		Label:     nlAddr.Label,
+		Scope:     scopes[netlink.Scope(nlAddr.Scope)],
+	}
+}
+
+func routeTo(handle *netlink.Handle, route Route) (*netlink.Route, error) {
+	nlRoute := &netlink.Route{
+		Dst:      route.Destination,
+		Gw:       route.Gateway,
+		Src:      route.Source,
+		Priority: route.Metric,
+		Table:    route.Table,
+	}
+
+	if route.Interface != "" {
+		link, err := linkByName(handle, route.Interface)
+		if err != nil {
+			return nil, err
+		}
+
+		nlRoute.LinkIndex = link.Attrs().Index
+	}
+
+	if route.Scope != "" {
+		found := false
+
+		for scope, name := range scopes {
+			if name == route.Scope {
+				nlRoute.Scope, found = scope, true
+			}
+		}
+
+		if !found {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidScope, route.Scope)
+		}
+	}
+
+	return nlRoute, nil
+}
+
+func routeFrom(nlRoute netlink.Route, names map[int]string) Route {
+	route := Route{
+		Interface:   names[nlRoute.LinkIndex],
+		Destination: nlRoute.Dst,
+		Gateway:     nlRoute.Gw,
+		Source:      nlRoute.Src,
+		Metric:      nlRoute.Priority,
+		Table:       nlRoute.Table,
+		Scope:       scopes[nlRoute.Scope],
+	}
+
+	// Default routes may come back as 0.0.0.0/0 or ::/0
+	if route.Destination != nil {
+		if ones, _ := route.Destination.Mask.Size(); ones == 0 {
+			route.Destination = nil
+		}
+	}
+
+	return route
+}
+
+func ruleTo(rule Rule) *netlink.Rule {
+	nlRule := netlink.NewRule()
+	nlRule.Table = rule.Table
+	nlRule.Src = rule.Source
+	nlRule.Dst = rule.Destination
+	nlRule.IifName = rule.IncomingInterface
+	nlRule.OifName = rule.OutgoingInterface
+	nlRule.Mark = rule.Mark
+	nlRule.Invert = rule.Invert
+
+	if rule.Priority != 0 {
+		nlRule.Priority = rule.Priority
+	}
+
+	switch {
+	case rule.Source != nil:
+		nlRule.Family = family(rule.Source.IP)
+	case rule.Destination != nil:
+		nlRule.Family = family(rule.Destination.IP)
+	case rule.IPv6:
+		nlRule.Family = netlink.FAMILY_V6
+	default:
+		nlRule.Family = netlink.FAMILY_V4
+	}
+
+	return nlRule
+}
+
+func family(ip net.IP) int {
+	if ip.To4() != nil {
+		return netlink.FAMILY_V4
+	}
+
+	return netlink.FAMILY_V6
+}
@@ -0,0 +1,260 @@
+/*
+   Copyright Farcloser.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package netlink_test
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	"gotest.tools/v3/assert"
+
+	"go.farcloser.world/containers/internal/netnstest"
+	"go.farcloser.world/containers/netlink"
+	"go.farcloser.world/containers/netns"
+)
+
+func mustCIDR(t *testing.T, cidr string) *net.IPNet {
+	t.Helper()
+
+	ip, ipNet, err := net.ParseCIDR(cidr)
+	assert.NilError(t, err)
+
+	ipNet.IP = ip
+
+	return ipNet
+}
+
+func newContainerInterface(t *testing.T) *netns.Namespace {
+	t.Helper()
+
+	namespaces := netnstest.Namespaces(t, "host", "container")
+	host, container := namespaces[0], namespaces[1]
+
+	assert.NilError(t, host.Do(func() error {
+		return errors.Join(
+			netlink.CreateVeth("veth0", netlink.VethOptions{PeerName: "eth0", PeerNamespace: container}),
+			netlink.SetUp("veth0", nil),
+		)
+	}))
+	assert.NilError(t, netlink.SetUp("eth0", container))
+
+	return container
+}
+
+func TestAddresses(t *testing.T) {
+	t.Parallel()
+
+	container := newContainerInterface(t)
+
+	v4 := mustCIDR(t, "10.4.0.2/24")
+	v6 := mustCIDR(t, "fd00:4::2/64")
+
+	assert.NilError(t, netlink.AddAddress(container, "eth0", v4))
+	assert.NilError(t, netlink.AddAddress(container, "eth0", v6))
+
+	err := netlink.AddAddress(container, "eth0", v4)
+	assert.ErrorIs(t, err, netlink.ErrAddressFail)
+
+	err = netlink.AddAddress(container, "missing", v4)
+	assert.ErrorIs(t, err, netlink.ErrAddressFail)
+	assert.ErrorIs(t, err, netlink.ErrLinkNotFound)
+
+	addresses, err := netlink.ListAddresses(container, "eth0")
+	assert.NilError(t, err)
+
+	found := map[string]netlink.Address{}
+	for _, address := range addresses {
+		assert.Equal(t, address.Interface, "eth0")
+		found[address.IPNet.String()] = address
+	}
+
+	assert.Equal(t, found["10.4.0.2/24"].Scope, "global")
+	assert.Equal(t, found["10.4.0.2/24"].Broadcast.String(), "10.4.0.255")
+	assert.Equal(t, found["fd00:4::2/64"].Scope, "global")
+
+	interfaces, err := netlink.ListInterfaces(container)
+	assert.NilError(t, err)
+	assert.Equal(t, len(interfaces), 2)
+
+	for _, iface := range interfaces {
+		switch iface.Name {
+		case "lo":
+			assert.Equal(t, iface.Type, "device")
+			assert.Assert(t, !iface.Up)
+		case "eth0":
+			assert.Equal(t, iface.Type, "veth")
+			assert.Assert(t, iface.Up)
+			assert.Equal(t, len(iface.Addresses), len(addresses))
+		default:
+			t.Fatalf("unexpected interface %q", iface.Name)
+		}
+	}
+
+	assert.NilError(t, netlink.RemoveAddress(container, "eth0", v4))
+
+	addresses, err = netlink.ListAddresses(container, "")
+	assert.NilError(t, err)
+
+	for _, address := range addresses {
+		assert.Assert(t, !address.IPNet.IP.Equal(v4.IP))
+	}
+}
+
+func TestRoutes(t *testing.T) {
+	t.Parallel()
+
+	container := newContainerInterface(t)
+
+	assert.NilError(t, netlink.AddAddress(container, "eth0", mustCIDR(t, "10.4.0.2/24")))
+	assert.NilError(t, netlink.AddAddress(container, "eth0", mustCIDR(t, "fd00:4::2/64")))
+
+	assert.NilError(t, netlink.SetDefaultRoute(container, "eth0", net.ParseIP("10.4.0.1")))
+	// Replaces the previous one
+	assert.NilError(t, netlink.SetDefaultRoute(container, "", net.ParseIP("10.4.0.254")))
+	assert.NilError(t, netlink.SetDefaultRoute(container, "eth0", net.ParseIP("fd00:4::1")))
+
+	static := netlink.Route{
+		Destination: mustCIDR(t, "192.168.100.0/24"),
+		Gateway:     net.ParseIP("10.4.0.1"),
+		Metric:      100,
+	}
+	assert.NilError(t, netlink.AddRoute(container, static))
+
+	err := netlink.AddRoute(container, static)
+	assert.ErrorIs(t, err, netlink.ErrRouteFail)
+
+	err = netlink.AddRoute(container, netlink.Route{Interface: "eth0", Scope: "galaxy"})
+	assert.ErrorIs(t, err, netlink.ErrInvalidScope)
+
+	routes, err := netlink.ListRoutes(container, "eth0")
+	assert.NilError(t, err)
+
+	var defaults, statics, links int
+
+	for _, route := range routes {
+		assert.Equal(t, route.Interface, "eth0")
+
+		switch {
+		case route.Destination == nil:
+			defaults++
+
+			assert.Assert(t, route.Gateway.Equal(net.ParseIP("10.4.0.254")) || route.Gateway.Equal(net.ParseIP("fd00:4::1")))
+		case route.Destination.String() == "192.168.100.0/24":
+			statics++
+
+			assert.Equal(t, route.Metric, 100)
+			assert.Equal(t, route.Scope, "global")
+		case route.Destination.String() == "10.4.0.0/24":
+			links++
+
+			assert.Equal(t, route.Scope, "link")
+		}
+	}
+
+	assert.Equal(t, defaults, 2)
+	assert.Equal(t, statics, 1)
+	assert.Equal(t, links, 1)
+
+	assert.NilError(t, netlink.RemoveRoute(container, static))
+
+	routes, err = netlink.ListRoutes(container, "")
+	assert.NilError(t, err)
+
+	for _, route := range routes {
+		assert.Assert(t, route.Destination == nil || route.Destination.String() != "192.168.100.0/24")
+	}
+}
+
+func TestRules(t *testing.T) {
+	t.Parallel()
+
+	container := newContainerInterface(t)
+
+	rule := netlink.Rule{Priority: 1000, Table: 100, Source: mustCIDR(t, "10.4.0.0/24")}
+	assert.NilError(t, netlink.AddRule(container, rule))
+	assert.NilError(t, netlink.AddRule(container, netlink.Rule{Priority: 1001, Table: 101, Mark: 42, IPv6: true}))
+
+	rules, err := netlink.ListRules(container)
+	assert.NilError(t, err)
+
+	found := map[int]netlink.Rule{}
+	for _, rule := range rules {
+		found[rule.Priority] = rule
+	}
+
+	assert.Equal(t, found[1000].Table, 100)
+	assert.Equal(t, found[1000].Source.String(), "10.4.0.0/24")
+	assert.Assert(t, !found[1000].IPv6)
+	assert.Equal(t, found[1001].Table, 101)
+	assert.Equal(t, found[1001].Mark, uint32(42))
+	assert.Assert(t, found[1001].IPv6)
+	// The main table lookup is always there
+	assert.Equal(t, found[32766].Table, 254)
+
+	assert.NilError(t, netlink.RemoveRule(container, rule))
+
+	rules, err = netlink.ListRules(container)
+	assert.NilError(t, err)
+
+	for _, rule := range rules {
+		assert.Assert(t, rule.Priority != 1000)
+	}
+}
+
+func TestNeighbors(t *testing.T) {
+	t.Parallel()
+
+	container := newContainerInterface(t)
+
+	mac, err := net.ParseMAC("02:42:0a:04:00:01")
+	assert.NilError(t, err)
+
+	neighbor := netlink.Neighbor{Interface: "eth0", IP: net.ParseIP("10.4.0.1"), MAC: mac}
+	assert.NilError(t, netlink.SetNeighbor(container, neighbor))
+	// Replaces the previous one
+	assert.NilError(t, netlink.SetNeighbor(container, neighbor))
+
+	err = netlink.SetNeighbor(container, netlink.Neighbor{Interface: "missing", IP: neighbor.IP, MAC: mac})
+	assert.ErrorIs(t, err, netlink.ErrNeighborFail)
+
+	neighbors, err := netlink.ListNeighbors(container, "eth0")
+	assert.NilError(t, err)
+
+	found := false
+
+	for _, entry := range neighbors {
+		if entry.IP.Equal(neighbor.IP) {
+			found = true
+
+			assert.Equal(t, entry.Interface, "eth0")
+			assert.Equal(t, entry.MAC.String(), mac.String())
+			assert.Equal(t, entry.State, "permanent")
+		}
+	}
+
+	assert.Assert(t, found)
+
+	assert.NilError(t, netlink.RemoveNeighbor(container, neighbor))
+
+	neighbors, err = netlink.ListNeighbors(container, "")
+	assert.NilError(t, err)
+
+	for _, entry := range neighbors {
+		assert.Assert(t, !entry.IP.Equal(neighbor.IP))
+	}
+}
@@ -0,0 +1,81 @@
+//go:build !linux
+
+/*
+   Copyright Farcloser.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package netlink
+
+import (
+	"net"
+
+	"go.farcloser.world/containers/netns"
+)
+
+func AddAddress(_ *netns.Namespace, _ string, _ *net.IPNet) error {
+	return ErrUnsupported
+}
+
+func RemoveAddress(_ *netns.Namespace, _ string, _ *net.IPNet) error {
+	return ErrUnsupported
+}
+
+func ListAddresses(_ *netns.Namespace, _ string) ([]Address, error) {
+	return nil, ErrUnsupported
+}
+
+func ListInterfaces(_ *netns.Namespace) ([]InterfaceConfig, error) {
+	return nil, ErrUnsupported
+}
+
+func AddRoute(_ *netns.Namespace, _ Route) error {
+	return ErrUnsupported
+}
+
+func SetDefaultRoute(_ *netns.Namespace, _ string, _ net.IP) error {
+	return ErrUnsupported
+}
+
+func RemoveRoute(_ *netns.Namespace, _ Route) error {
+	return ErrUnsupported
+}
+
+func ListRoutes(_ *netns.Namespace, _ string) ([]Route, error) {
+	return nil, ErrUnsupported
+}
+
+func AddRule(_ *netns.Namespace, _ Rule) error {
+	return ErrUnsupported
+}
+
+func RemoveRule(_ *netns.Namespace, _ Rule) error {
+	return ErrUnsupported
+}
+
+func ListRules(_ *netns.Namespace) ([]Rule, error) {
+	return nil, ErrUnsupported
+}
+
+func SetNeighbor(_ *netns.Namespace, _ Neighbor) error {
+	return ErrUnsupported
+}
+
+func RemoveNeighbor(_ *netns.Namespace, _ Neighbor) error {
+	return ErrUnsupported
+}
+
+func ListNeighbors(_ *netns.Namespace, _ string) ([]Neighbor, error) {
+	return nil, ErrUnsupported
+}
@@ -0,0 +1,269 @@
+/*
+   Copyright Farcloser.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package netlink
+
+import (
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/vishvananda/netlink"
+	vnetns "github.com/vishvananda/netns"
+
+	"go.farcloser.world/containers/netns"
+)
+
+type (
+	MacvlanMode string
+	IPVlanMode  string
+	IPVlanFlag  string
+)
+
+const (
+	MacvlanBridge   MacvlanMode = "bridge"
+	MacvlanPrivate  MacvlanMode = "private"
+	MacvlanVEPA     MacvlanMode = "vepa"
+	MacvlanPassthru MacvlanMode = "passthru"
+
+	IPVlanL2  IPVlanMode = "l2"
+	IPVlanL3  IPVlanMode = "l3"
+	IPVlanL3S IPVlanMode = "l3s"
+
+	IPVlanBridge  IPVlanFlag = "bridge"
+	IPVlanPrivate IPVlanFlag = "private"
+	IPVlanVEPA    IPVlanFlag = "vepa"
+)
+
+var (
+	ErrCreateFail    = errors.New("unable to create network interface")
+	ErrConfigureFail = errors.New("unable to configure network interface")
+	ErrInvalidMode   = errors.New("invalid mode")
+)
+
+// LinkOptions are the settings common to all links. Zero values are left to the kernel.
+type LinkOptions struct {
+	MTU int
+	MAC net.HardwareAddr
+	// Namespace is where the link is created, instead of the current one.
+	Namespace *netns.Namespace
+}
+
+// VethOptions are the settings of a veth pair.
+type VethOptions struct {
+	// LinkOptions apply to the end named after the pair, except for the MTU which applies to both.
+	LinkOptions
+	// PeerName is the name of the other end (eg: eth0).
+	PeerName string
+	PeerMAC  net.HardwareAddr
+	// PeerNamespace is where the other end is created (eg: a container namespace), instead of the current one.
+	PeerNamespace *netns.Namespace
+}
+
+// BridgeOptions are the settings of a bridge.
+type BridgeOptions struct {
+	LinkOptions
+	VLANFiltering bool
+	// DefaultPVID is the VLAN untagged traffic is assigned to, when VLANFiltering is set (1 if 0).
+	DefaultPVID uint16
+}
+
+// CreateVeth creates the veth pair `name` and `opts.PeerName`, with each end in its own namespace.
+func CreateVeth(name string, opts VethOptions) error {
+	veth := &netlink.Veth{
+		LinkAttrs:        linkAttrs(name, opts.LinkOptions),
+		PeerName:         opts.PeerName,
+		PeerHardwareAddr: opts.PeerMAC,
+	}
+
+	if opts.PeerNamespace != nil {
+		veth.PeerNamespace = netlink.NsFd(opts.PeerNamespace.Fd())
+	}
+
+	return linkAdd(veth)
+}
+
+// CreateBridge creates the bridge `name`.
+func CreateBridge(name string, opts BridgeOptions) error {
+	bridge := &netlink.Bridge{LinkAttrs: linkAttrs(name, opts.LinkOptions)}
+
+	// Kernels built without vlan filtering reject the attribute altogether, so, only send it when asked for
+	if opts.VLANFiltering {
+		bridge.VlanFiltering = &opts.VLANFiltering
+
+		if opts.DefaultPVID != 0 {
+			bridge.VlanDefaultPVID = &opts.DefaultPVID
+		}
+	}
+
+	return linkAdd(bridge)
+}
+
+// CreateMacvlan creates the macvlan `name`, on top of the link `parent` of the current namespace.
+func CreateMacvlan(name string, parent string, mode MacvlanMode, opts LinkOptions) error {
+	modes := map[MacvlanMode]netlink.MacvlanMode{
+		MacvlanBridge:   netlink.MACVLAN_MODE_BRIDGE,
+		MacvlanPrivate:  netlink.MACVLAN_MODE_PRIVATE,
+		MacvlanVEPA:     netlink.MACVLAN_MODE_VEPA,
+		MacvlanPassthru: netlink.MACVLAN_MODE_PASSTHRU,
+	}
+
+	kernelMode, ok := modes[mode]
+	if !ok {
+		return errors.Join(ErrCreateFail, fmt.Errorf("%w: macvlan %q", ErrInvalidMode, mode))
+	}
+
+	attrs, err := childAttrs(name, parent, opts)
+	if err != nil {
+		return err
+	}
+
+	return linkAdd(&netlink.Macvlan{LinkAttrs: attrs, Mode: kernelMode})
+}
+
+// CreateIPVlan creates the ipvlan `name`, on top of the link `parent` of the current namespace.
+func CreateIPVlan(name string, parent string, mode IPVlanMode, flag IPVlanFlag, opts LinkOptions) error {
+	modes := map[IPVlanMode]netlink.IPVlanMode{
+		IPVlanL2:  netlink.IPVLAN_MODE_L2,
+		IPVlanL3:  netlink.IPVLAN_MODE_L3,
+		IPVlanL3S: netlink.IPVLAN_MODE_L3S,
+	}
+
+	flags := map[IPVlanFlag]netlink.IPVlanFlag{
+		"":            netlink.IPVLAN_FLAG_BRIDGE,
+		IPVlanBridge:  netlink.IPVLAN_FLAG_BRIDGE,
+		IPVlanPrivate: netlink.IPVLAN_FLAG_PRIVATE,
+		IPVlanVEPA:    netlink.IPVLAN_FLAG_VEPA,
+	}
+
+	kernelMode, ok := modes[mode]
+	if !ok {
+		return errors.Join(ErrCreateFail, fmt.Errorf("%w: ipvlan %q", ErrInvalidMode, mode))
+	}
+
+	kernelFlag, ok := flags[flag]
+	if !ok {
+		return errors.Join(ErrCreateFail, fmt.Errorf("%w: ipvlan flag %q", ErrInvalidMode, flag))
+	}
+
+	attrs, err := childAttrs(name, parent, opts)
+	if err != nil {
+		return err
+	}
+
+	return linkAdd(&netlink.IPVlan{LinkAttrs: attrs, Mode: kernelMode, Flag: kernelFlag})
+}
+
+// SetUp brings the link `name` of namespace `ns` up, nil standing for the current namespace.
+func SetUp(name string, ns *netns.Namespace) error {
+	return configure(name, ns, func(handle *netlink.Handle, link netlink.Link) error {
+		return handle.LinkSetUp(link)
+	})
+}
+
+// SetDown brings the link `name` of namespace `ns` down.
+func SetDown(name string, ns *netns.Namespace) error {
+	return configure(name, ns, func(handle *netlink.Handle, link netlink.Link) error {
+		return handle.LinkSetDown(link)
+	})
+}
+
+// SetMTU sets the MTU of the link `name` of namespace `ns`.
+func SetMTU(name string, mtu int, ns *netns.Namespace) error {
+	return configure(name, ns, func(handle *netlink.Handle, link netlink.Link) error {
+		return handle.LinkSetMTU(link, mtu)
+	})
+}
+
+// SetMAC sets the hardware address of the link `name` of namespace `ns`.
+func SetMAC(name string, mac net.HardwareAddr, ns *netns.Namespace) error {
+	return configure(name, ns, func(handle *netlink.Handle, link netlink.Link) error {
+		return handle.LinkSetHardwareAddr(link, mac)
+	})
+}
+
+// SetMaster attaches the link `name` to the bridge `master`, both in the current namespace.
+func SetMaster(name string, master string) error {
+	return configure(name, nil, func(handle *netlink.Handle, link netlink.Link) error {
+		bridge, err := handle.LinkByName(master)
+		if err != nil {
+			return fmt.Errorf("%w: %s: %w", ErrLinkNotFound, master, err)
+		}
+
+		return handle.LinkSetMaster(link, bridge)
+	})
+}
+
+// newHandle returns a handle on namespace `ns`, or on the current one if nil.
+func newHandle(ns *netns.Namespace) (*netlink.Handle, error) {
+	if ns == nil {
+		return netlink.NewHandle()
+	}
+
+	return netlink.NewHandleAt(vnetns.NsHandle(ns.Fd()))
+}
+
+func configure(name string, ns *netns.Namespace, apply func(handle *netlink.Handle, link netlink.Link) error) error {
+	handle, err := newHandle(ns)
+	if err != nil {
+		return errors.Join(ErrConfigureFail, err)
+	}
+	defer handle.Close()
+
+	link, err := handle.LinkByName(name)
+	if err != nil {
+		return errors.Join(ErrConfigureFail, fmt.Errorf("%w: %s: %w", ErrLinkNotFound, name, err))
+	}
+
+	if err = apply(handle, link); err != nil {
+		return errors.Join(ErrConfigureFail, err)
+	}
+
+	return nil
+}
+
+func linkAttrs(name string, opts LinkOptions) netlink.LinkAttrs {
+	attrs := netlink.NewLinkAttrs()
+	attrs.Name = name
+	attrs.MTU = opts.MTU
+	attrs.HardwareAddr = opts.MAC
+
+	if opts.Namespace != nil {
+		attrs.Namespace = netlink.NsFd(opts.Namespace.Fd())
+	}
+
+	return attrs
+}
+
+func childAttrs(name string, parent string, opts LinkOptions) (netlink.LinkAttrs, error) {
+	parentLink, err := netlink.LinkByName(parent)
+	if err != nil {
+		return netlink.LinkAttrs{}, errors.Join(ErrCreateFail, fmt.Errorf("%w: %s: %w", ErrLinkNotFound, parent, err))
+	}
+
+	attrs := linkAttrs(name, opts)
+	attrs.ParentIndex = parentLink.Attrs().Index
+
+	return attrs, nil
+}
+
+func linkAdd(link netlink.Link) error {
+	if err := netlink.LinkAdd(link); err != nil {
+		return errors.Join(ErrCreateFail, fmt.Errorf("%s %s: %w", link.Type(), link.Attrs().Name, err))
+	}
+
+	return nil
+}
@@ -0,0 +1,170 @@
+/*
+   Copyright Farcloser.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package netlink_test
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	vnetlink "github.com/vishvananda/netlink"
+	vnetns "github.com/vishvananda/netns"
+	"golang.org/x/sys/unix"
+	"gotest.tools/v3/assert"
+
+	"go.farcloser.world/containers/internal/netnstest"
+	"go.farcloser.world/containers/netlink"
+	"go.farcloser.world/containers/netns"
+)
+
+func linkIn(t *testing.T, namespace *netns.Namespace, name string) vnetlink.Link {
+	t.Helper()
+
+	handle, err := vnetlink.NewHandleAt(vnetns.NsHandle(namespace.Fd()))
+	assert.NilError(t, err)
+
+	defer handle.Close()
+
+	link, err := handle.LinkByName(name)
+	assert.NilError(t, err)
+
+	return link
+}
+
+func TestCreateVeth(t *testing.T) {
+	t.Parallel()
+
+	namespaces := netnstest.Namespaces(t, "host", "container")
+	host, container := namespaces[0], namespaces[1]
+
+	mac, err := net.ParseMAC("02:42:ac:11:00:02")
+	assert.NilError(t, err)
+
+	assert.NilError(t, host.Do(func() error {
+		return errors.Join(
+			netlink.CreateBridge("br0", netlink.BridgeOptions{}),
+			netlink.SetUp("br0", nil),
+			netlink.CreateVeth("veth0", netlink.VethOptions{
+				LinkOptions:   netlink.LinkOptions{MTU: 1400},
+				PeerName:      "eth0",
+				PeerMAC:       mac,
+				PeerNamespace: container,
+			}),
+			netlink.SetMaster("veth0", "br0"),
+			netlink.SetUp("veth0", nil),
+		)
+	}))
+
+	bridge := linkIn(t, host, "br0")
+	assert.Equal(t, bridge.Type(), "bridge")
+
+	veth := linkIn(t, host, "veth0")
+	assert.Equal(t, veth.Type(), "veth")
+	assert.Equal(t, veth.Attrs().MTU, 1400)
+	assert.Equal(t, veth.Attrs().MasterIndex, bridge.Attrs().Index)
+	assert.Assert(t, veth.Attrs().Flags&net.FlagUp != 0)
+
+	// The peer was created in the container namespace directly, under its final name
+	assert.NilError(t, netlink.SetMTU("eth0", 1300, container))
+	assert.NilError(t, netlink.SetUp("eth0", container))
+
+	peer := linkIn(t, container, "eth0")
+	assert.Equal(t, peer.Type(), "veth")
+	assert.Equal(t, peer.Attrs().HardwareAddr.String(), mac.String())
+	assert.Equal(t, peer.Attrs().MTU, 1300)
+
+	newMAC, err := net.ParseMAC("02:42:ac:11:00:03")
+	assert.NilError(t, err)
+	assert.NilError(t, netlink.SetMAC("eth0", newMAC, container))
+	assert.Equal(t, linkIn(t, container, "eth0").Attrs().HardwareAddr.String(), newMAC.String())
+
+	var existsErr, masterErr, configureErr error
+
+	assert.NilError(t, host.Do(func() error {
+		existsErr = netlink.CreateVeth("veth0", netlink.VethOptions{PeerName: "veth1"})
+		masterErr = netlink.SetMaster("veth0", "missing")
+		configureErr = netlink.SetUp("eth0", nil)
+
+		return nil
+	}))
+
+	assert.ErrorIs(t, existsErr, netlink.ErrCreateFail)
+	assert.ErrorIs(t, existsErr, unix.EEXIST)
+	assert.ErrorIs(t, masterErr, netlink.ErrConfigureFail)
+	assert.ErrorIs(t, masterErr, netlink.ErrLinkNotFound)
+	assert.ErrorIs(t, configureErr, netlink.ErrLinkNotFound)
+}
+
+func TestCreateBridgeVLANFiltering(t *testing.T) {
+	t.Parallel()
+
+	host := netnstest.Namespaces(t, "host")[0]
+
+	err := host.Do(func() error {
+		return netlink.CreateBridge("br0", netlink.BridgeOptions{VLANFiltering: true, DefaultPVID: 10})
+	})
+	if errors.Is(err, unix.EOPNOTSUPP) {
+		t.Skip("kernel does not support bridge vlan filtering")
+	}
+
+	assert.NilError(t, err)
+
+	bridge, ok := linkIn(t, host, "br0").(*vnetlink.Bridge)
+	assert.Assert(t, ok)
+	assert.Assert(t, *bridge.VlanFiltering)
+	assert.Equal(t, *bridge.VlanDefaultPVID, uint16(10))
+}
+
+func TestCreateMacvlan(t *testing.T) {
+	t.Parallel()
+
+	host := netnstest.Namespaces(t, "host")[0]
+
+	var invalidErr, missingErr, ipvlanErr error
+
+	assert.NilError(t, host.Do(func() error {
+		// A veth end is as good a parent as any physical interface
+		if err := netlink.CreateVeth("parent0", netlink.VethOptions{PeerName: "parent1"}); err != nil {
+			return err
+		}
+
+		invalidErr = netlink.CreateMacvlan("macvlan0", "parent0", "invalid", netlink.LinkOptions{})
+		missingErr = netlink.CreateMacvlan("macvlan0", "missing", netlink.MacvlanBridge, netlink.LinkOptions{})
+		ipvlanErr = netlink.CreateIPVlan("ipvlan0", "parent1", netlink.IPVlanL3, "", netlink.LinkOptions{MTU: 1400})
+
+		return netlink.CreateMacvlan("macvlan0", "parent0", netlink.MacvlanPrivate, netlink.LinkOptions{MTU: 1400})
+	}))
+
+	assert.ErrorIs(t, invalidErr, netlink.ErrInvalidMode)
+	assert.ErrorIs(t, missingErr, netlink.ErrLinkNotFound)
+
+	macvlan, ok := linkIn(t, host, "macvlan0").(*vnetlink.Macvlan)
+	assert.Assert(t, ok)
+	assert.Equal(t, macvlan.Mode, vnetlink.MACVLAN_MODE_PRIVATE)
+	assert.Equal(t, macvlan.Attrs().MTU, 1400)
+	assert.Equal(t, macvlan.Attrs().ParentIndex, linkIn(t, host, "parent0").Attrs().Index)
+
+	if errors.Is(ipvlanErr, unix.EOPNOTSUPP) {
+		t.Skip("kernel does not support ipvlan")
+	}
+
+	assert.NilError(t, ipvlanErr)
+
+	ipvlan, ok := linkIn(t, host, "ipvlan0").(*vnetlink.IPVlan)
+	assert.Assert(t, ok)
+	assert.Equal(t, ipvlan.Mode, vnetlink.IPVLAN_MODE_L3)
+}
@@ -0,0 +1,197 @@
+/*
+   Copyright Farcloser.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package netlink
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netns"
+)
+
+const vethType = "veth"
+
+// LinkFilter selects the links of a namespace. The zero value excludes loopback and down links.
+type LinkFilter struct {
+	IncludeLoopback bool
+	IncludeDown     bool
+}
+
+// InterfaceStats is the counters of a network interface.
+type InterfaceStats struct {
+	Name     string
+	Index    int
+	Type     string
+	Up       bool
+	Loopback bool
+	// HostPeer is the name of the other end of a veth interface, if it is in the namespace of the caller.
+	HostPeer  string
+	RxBytes   uint64
+	TxBytes   uint64
+	RxPackets uint64
+	TxPackets uint64
+	RxErrors  uint64
+	TxErrors  uint64
+	RxDropped uint64
+	TxDropped uint64
+	Multicast uint64
+	// peerIndex is the index of the other end of a veth interface, in its namespace.
+	peerIndex int
+	// peerNetNsID is the id of the namespace of the other end, as seen from the namespace of the interface (-1 if
+	// they share it).
+	peerNetNsID int
+}
+
+// FilterLinks returns the links selected by `filter`.
+func FilterLinks(links []Link, filter LinkFilter) []Link {
+	var res []Link
+
+	for _, nlink := range links {
+		attrs := nlink.Attrs()
+		if !filter.IncludeDown && attrs.Flags&net.FlagUp == 0 {
+			continue
+		}
+
+		if !filter.IncludeLoopback && isLoopback(attrs) {
+			continue
+		}
+
+		res = append(res, nlink)
+	}
+
+	return res
+}
+
+// GetNetNsLinksFiltered returns the links of the network namespace of process `pid` selected by `filter`.
+func GetNetNsLinksFiltered(pid int, filter LinkFilter) (nlinks []Link, err error) {
+	var (
+		nlHandle *netlink.Handle
+		nsHandle netns.NsHandle
+	)
+
+	nsHandle, err = netns.GetFromPid(pid)
+	if err != nil {
+		err = fmt.Errorf("failed to retrieve the statistics in netns %s: %w", nsHandle, err)
+
+		return nil, err
+	}
+
+	defer func() {
+		err = errors.Join(nsHandle.Close(), err)
+	}()
+
+	nlHandle, err = netlink.NewHandleAt(nsHandle)
+	if err != nil {
+		err = fmt.Errorf("failed to retrieve the statistics in netns %s: %w", nsHandle, err)
+
+		return nil, err
+	}
+
+	defer nlHandle.Close()
+
+	candidates, err := nlHandle.LinkList()
+	if err != nil {
+		return nil, err
+	}
+
+	return FilterLinks(candidates, filter), nil
+}
+
+// InterfaceStatsForLinks returns the counters of each link.
+func InterfaceStatsForLinks(links []Link) []InterfaceStats {
+	res := make([]InterfaceStats, 0, len(links))
+
+	for _, nlink := range links {
+		attrs := nlink.Attrs()
+		stats := InterfaceStats{
+			Name:     attrs.Name,
+			Index:    attrs.Index,
+			Type:     nlink.Type(),
+			Up:       attrs.Flags&net.FlagUp != 0,
+			Loopback: isLoopback(attrs),
+		}
+
+		if stats.Type == vethType {
+			stats.peerIndex = attrs.ParentIndex
+			stats.peerNetNsID = attrs.NetNsID
+		}
+
+		if counters := attrs.Statistics; counters != nil {
+			stats.RxBytes = counters.RxBytes
+			stats.TxBytes = counters.TxBytes
+			stats.RxPackets = counters.RxPackets
+			stats.TxPackets = counters.TxPackets
+			stats.RxErrors = counters.RxErrors
+			stats.TxErrors = counters.TxErrors
+			stats.RxDropped = counters.RxDropped
+			stats.TxDropped = counters.TxDropped
+			stats.Multicast = counters.Multicast
+		}
+
+		res = append(res, stats)
+	}
+
+	return res
+}
+
+// GetNetNsInterfaceStats returns the counters of the interfaces of the network namespace of process `pid` selected
+// by `filter`. Veth interfaces are mapped to their peer in the namespace of the caller (usually, the host).
+func GetNetNsInterfaceStats(pid int, filter LinkFilter) ([]InterfaceStats, error) {
+	links, err := GetNetNsLinksFiltered(pid, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := InterfaceStatsForLinks(links)
+
+	// Peer indexes are only meaningful in the namespace of the peer
+	callerID, err := callerNetNsID(pid)
+	if err != nil {
+		return stats, nil
+	}
+
+	for index := range stats {
+		if stats[index].peerIndex == 0 || stats[index].peerNetNsID != callerID {
+			continue
+		}
+
+		if peer, err := netlink.LinkByIndex(stats[index].peerIndex); err == nil && peer.Type() == vethType {
+			stats[index].HostPeer = peer.Attrs().Name
+		}
+	}
+
+	return stats, nil
+}
+
+// TotalsForInterfaces returns the bytes received and sent over all interfaces.
+func TotalsForInterfaces(stats []InterfaceStats) (uint64, uint64) {
+	var received, transmitted uint64
+
+	for _, stat := range stats {
+		received += stat.RxBytes
+		transmitted += stat.TxBytes
+	}
+
+	return received, transmitted
+}
+
+func isLoopback(attrs *netlink.LinkAttrs) bool {
+	return attrs.Flags&net.FlagLoopback != 0 || strings.HasPrefix(attrs.Name, "lo")
+}
@@ -0,0 +1,59 @@
+/*
+   Copyright Farcloser.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package netlink
+
+import (
+	"errors"
+
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netns"
+)
+
+var errNoNetNsID = errors.New("namespace has no id")
+
+// callerNetNsID returns the id of the namespace of the caller, as seen from the network namespace of process `pid`
+// (-1 if it is the same).
+func callerNetNsID(pid int) (int, error) {
+	target, err := netns.GetFromPid(pid)
+	if err != nil {
+		return 0, err
+	}
+	defer target.Close()
+
+	current, err := netns.Get()
+	if err != nil {
+		return 0, err
+	}
+	defer current.Close()
+
+	if target.Equal(current) {
+		return -1, nil
+	}
+
+	handle, err := netlink.NewHandleAt(target)
+	if err != nil {
+		return 0, err
+	}
+	defer handle.Close()
+
+	id, err := handle.GetNetNsIdByFd(int(current))
+	if err == nil && id < 0 {
+		err = errNoNetNsID
+	}
+
+	return id, err
+}
@@ -0,0 +1,94 @@
+/*
+   Copyright Farcloser.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package netlink_test
+
+import (
+	"os"
+	"os/exec"
+	"testing"
+
+	"gotest.tools/v3/assert"
+
+	"go.farcloser.world/containers/internal/netnstest"
+	"go.farcloser.world/containers/netlink"
+)
+
+func TestGetNetNsInterfaceStats(t *testing.T) {
+	t.Parallel()
+
+	stats, err := netlink.GetNetNsInterfaceStats(os.Getpid(), netlink.LinkFilter{IncludeLoopback: true})
+	assert.NilError(t, err)
+
+	found := false
+
+	for _, stat := range stats {
+		assert.Assert(t, stat.Up)
+		found = found || stat.Loopback
+	}
+
+	assert.Assert(t, found, "no loopback interface in %v", stats)
+
+	stats, err = netlink.GetNetNsInterfaceStats(os.Getpid(), netlink.LinkFilter{})
+	assert.NilError(t, err)
+
+	for _, stat := range stats {
+		assert.Assert(t, !stat.Loopback)
+	}
+}
+
+func TestGetNetNsInterfaceStatsHostPeer(t *testing.T) {
+	t.Parallel()
+
+	namespaces := netnstest.Namespaces(t, "host", "container", "other")
+	host, container, other := namespaces[0], namespaces[1], namespaces[2]
+
+	// veth0 and eth1-peer get the same index, in their own namespace
+	assert.NilError(t, host.Do(func() error {
+		return netlink.CreateVeth("veth0", netlink.VethOptions{PeerName: "eth0", PeerNamespace: container})
+	}))
+	assert.NilError(t, container.Do(func() error {
+		return netlink.CreateVeth("eth1", netlink.VethOptions{PeerName: "eth1-peer", PeerNamespace: other})
+	}))
+
+	// Holding a thread of the test in the namespace instead could hold the main thread, whose namespace is that of
+	// the test process
+	sleep := exec.Command("sleep", "infinity")
+	assert.NilError(t, container.Do(sleep.Start))
+
+	defer func() {
+		_ = sleep.Process.Kill()
+		_ = sleep.Wait()
+	}()
+
+	pid := sleep.Process.Pid
+
+	var stats []netlink.InterfaceStats
+
+	assert.NilError(t, host.Do(func() error {
+		var err error
+		stats, err = netlink.GetNetNsInterfaceStats(pid, netlink.LinkFilter{IncludeDown: true})
+
+		return err
+	}))
+
+	peers := map[string]string{}
+	for _, stat := range stats {
+		peers[stat.Name] = stat.HostPeer
+	}
+
+	assert.DeepEqual(t, peers, map[string]string{"eth0": "veth0", "eth1": ""})
+}
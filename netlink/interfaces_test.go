@@ -0,0 +1,84 @@
+/*
+   Copyright Farcloser.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package netlink_test
+
+import (
+	"net"
+	"testing"
+
+	vnetlink "github.com/vishvananda/netlink"
+	"gotest.tools/v3/assert"
+
+	"go.farcloser.world/containers/netlink"
+)
+
+func links() []netlink.Link {
+	return []netlink.Link{
+		&vnetlink.Device{LinkAttrs: vnetlink.LinkAttrs{
+			Name: "lo", Index: 1, Flags: net.FlagUp | net.FlagLoopback,
+			Statistics: &vnetlink.LinkStatistics{RxBytes: 100, TxBytes: 100},
+		}},
+		&vnetlink.Veth{LinkAttrs: vnetlink.LinkAttrs{
+			Name: "eth0", Index: 2, ParentIndex: 12, Flags: net.FlagUp,
+			Statistics: &vnetlink.LinkStatistics{
+				RxBytes: 1 << 40, TxBytes: 2048, RxPackets: 10, TxPackets: 20, RxDropped: 1, Multicast: 3,
+			},
+		}},
+		&vnetlink.Dummy{LinkAttrs: vnetlink.LinkAttrs{
+			Name: "dummy0", Index: 3,
+			Statistics: &vnetlink.LinkStatistics{RxBytes: 7},
+		}},
+	}
+}
+
+func TestFilterLinks(t *testing.T) {
+	t.Parallel()
+
+	names := func(filter netlink.LinkFilter) []string {
+		res := []string{}
+		for _, link := range netlink.FilterLinks(links(), filter) {
+			res = append(res, link.Attrs().Name)
+		}
+
+		return res
+	}
+
+	assert.DeepEqual(t, names(netlink.LinkFilter{}), []string{"eth0"})
+	assert.DeepEqual(t, names(netlink.LinkFilter{IncludeLoopback: true}), []string{"lo", "eth0"})
+	assert.DeepEqual(t, names(netlink.LinkFilter{IncludeDown: true}), []string{"eth0", "dummy0"})
+}
+
+func TestInterfaceStatsForLinks(t *testing.T) {
+	t.Parallel()
+
+	stats := netlink.InterfaceStatsForLinks(links())
+	assert.Equal(t, len(stats), 3)
+
+	assert.Assert(t, stats[0].Loopback)
+	assert.Equal(t, stats[1].Name, "eth0")
+	assert.Equal(t, stats[1].Type, "veth")
+	assert.Assert(t, stats[1].Up)
+	assert.Equal(t, stats[1].RxBytes, uint64(1<<40))
+	assert.Equal(t, stats[1].TxPackets, uint64(20))
+	assert.Equal(t, stats[1].RxDropped, uint64(1))
+	assert.Equal(t, stats[1].Multicast, uint64(3))
+	assert.Assert(t, !stats[2].Up)
+
+	received, transmitted := netlink.TotalsForInterfaces(stats[1:])
+	assert.Equal(t, received, uint64(1<<40+7))
+	assert.Equal(t, transmitted, uint64(2048))
+}
@@ -19,11 +19,8 @@ package netlink
 import (
 	"errors"
 	"fmt"
-	"net"
-	"strings"
 
 	"github.com/vishvananda/netlink"
-	"github.com/vishvananda/netns"
 )
 
 var (
@@ -36,6 +33,10 @@ type Link = netlink.Link
 func LinkDel(netInterface string) error { //nolint:ireturn,nolintlint // note this is probably a bug in ireturn
 	link, err := netlink.LinkByName(netInterface)
 	if err != nil {
+		if errors.As(err, &netlink.LinkNotFoundError{}) {
+			return fmt.Errorf("%w: %s: %w", ErrLinkNotFound, netInterface, err)
+		}
+
 		return err
 	}
 
@@ -47,47 +48,7 @@ func LinkDel(netInterface string) error { //nolint:ireturn,nolintlint // note th
 	return err
 }
 
-func GetNetNsLinks(pid int) (nlinks []netlink.Link, err error) {
-	var (
-		nlHandle *netlink.Handle
-		nsHandle netns.NsHandle
-	)
-
-	nsHandle, err = netns.GetFromPid(pid)
-	if err != nil {
-		err = fmt.Errorf("failed to retrieve the statistics in netns %s: %w", nsHandle, err)
-
-		return nil, err
-	}
-
-	defer func() {
-		err = errors.Join(nsHandle.Close(), err)
-	}()
-
-	nlHandle, err = netlink.NewHandleAt(nsHandle)
-	if err != nil {
-		err = fmt.Errorf("failed to retrieve the statistics in netns %s: %w", nsHandle, err)
-
-		return nil, err
-	}
-
-	defer nlHandle.Close()
-
-	candidates, err := nlHandle.LinkList()
-	if err != nil {
-		return nil, err
-	}
-
-	for _, nlink := range candidates {
-		// exclude down and loopback interfaces
-		if nlink.Attrs().Flags&net.FlagUp != 0 {
-			if nlink.Attrs().Flags&net.FlagLoopback != 0 || strings.HasPrefix(nlink.Attrs().Name, "lo") {
-				continue
-			}
-
-			nlinks = append(nlinks, nlink)
-		}
-	}
-
-	return nlinks, nil
+// GetNetNsLinks returns the links of the network namespace of process `pid` that are up, except loopback ones.
+func GetNetNsLinks(pid int) ([]netlink.Link, error) {
+	return GetNetNsLinksFiltered(pid, LinkFilter{})
 }
@@ -0,0 +1,49 @@
+/*
+   Copyright Farcloser.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package netlink_test
+
+import (
+	"testing"
+
+	"gotest.tools/v3/assert"
+
+	"go.farcloser.world/containers/internal/netnstest"
+	"go.farcloser.world/containers/netlink"
+)
+
+func TestLinkDel(t *testing.T) {
+	t.Parallel()
+
+	host := netnstest.Namespaces(t, "host")[0]
+
+	var deleteErr, missingErr error
+
+	assert.NilError(t, host.Do(func() error {
+		if err := netlink.CreateVeth("veth0", netlink.VethOptions{PeerName: "veth1"}); err != nil {
+			return err
+		}
+
+		deleteErr = netlink.LinkDel("veth0")
+		// Deleting one end of a veth deletes the other
+		missingErr = netlink.LinkDel("veth1")
+
+		return nil
+	}))
+
+	assert.NilError(t, deleteErr)
+	assert.ErrorIs(t, missingErr, netlink.ErrLinkNotFound)
+}
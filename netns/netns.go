@@ -0,0 +1,77 @@
+/*
+   Copyright Farcloser.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// Package netns creates, opens and enters network namespaces. Named namespaces are bind-mounted under a
+// directory, /run/netns by default as with `ip netns`, so that they persist without any process in them.
+package netns
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// DefaultDir is where `ip netns` keeps named namespaces.
+const DefaultDir = "/run/netns"
+
+var (
+	ErrUnsupported     = errors.New("network namespaces are not supported on this platform")
+	ErrInvalidName     = errors.New("invalid namespace name")
+	ErrCannotCreate    = errors.New("cannot create network namespace")
+	ErrCannotOpen      = errors.New("cannot open network namespace")
+	ErrCannotEnter     = errors.New("cannot enter network namespace")
+	ErrCannotDelete    = errors.New("cannot delete network namespace")
+	ErrNotANamespace   = errors.New("not a network namespace")
+	ErrNamespaceExists = errors.New("network namespace already exists")
+)
+
+// Store keeps named namespaces in a directory.
+type Store struct {
+	Dir string
+}
+
+// NewStore returns a store keeping namespaces in `dir`, or DefaultDir if empty.
+func NewStore(dir string) *Store {
+	if dir == "" {
+		dir = DefaultDir
+	}
+
+	return &Store{Dir: dir}
+}
+
+// Namespace is an open network namespace. It must be closed once done with.
+type Namespace struct {
+	path string
+	fd   int
+}
+
+// Path returns the path the namespace was opened from.
+func (ns *Namespace) Path() string {
+	return ns.path
+}
+
+// Fd returns the file descriptor of the namespace (eg: to move links into it).
+func (ns *Namespace) Fd() int {
+	return ns.fd
+}
+
+func validateName(name string) error {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, "/\x00") {
+		return fmt.Errorf("%w: %q", ErrInvalidName, name)
+	}
+
+	return nil
+}
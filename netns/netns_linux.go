@@ -0,0 +1,252 @@
+/*
+   Copyright Farcloser.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package netns
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	procNetNsFormat     = "/proc/%d/ns/net"
+	procTaskNetNsFormat = "/proc/%d/task/%d/ns/net"
+	dirPermissions      = 0o755
+	filePermissions     = 0o444
+)
+
+// Current returns the network namespace of the calling thread.
+func Current() (*Namespace, error) {
+	return OpenPath(fmt.Sprintf(procTaskNetNsFormat, os.Getpid(), unix.Gettid()))
+}
+
+// OpenPID returns the network namespace of process `pid`.
+func OpenPID(pid int) (*Namespace, error) {
+	return OpenPath(fmt.Sprintf(procNetNsFormat, pid))
+}
+
+// OpenPath returns the network namespace at `pth` (eg: /proc/<pid>/ns/net, or a bind mount of it).
+func OpenPath(pth string) (*Namespace, error) {
+	fd, err := unix.Open(pth, unix.O_RDONLY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, errors.Join(ErrCannotOpen, &os.PathError{Op: "open", Path: pth, Err: err})
+	}
+
+	// Namespaces are on nsfs, or procfs for kernels older than 3.19
+	var stat unix.Statfs_t
+
+	err = unix.Fstatfs(fd, &stat)
+	if err != nil || (stat.Type != unix.NSFS_MAGIC && stat.Type != unix.PROC_SUPER_MAGIC) {
+		_ = unix.Close(fd)
+
+		return nil, errors.Join(ErrCannotOpen, fmt.Errorf("%w: %s", ErrNotANamespace, pth), err)
+	}
+
+	return &Namespace{path: pth, fd: fd}, nil
+}
+
+// Close closes the namespace. Named namespaces persist until deleted.
+func (ns *Namespace) Close() error {
+	if ns.fd < 0 {
+		return nil
+	}
+
+	err := unix.Close(ns.fd)
+	ns.fd = -1
+
+	return err
+}
+
+// Equal returns whether both namespaces are the same.
+func (ns *Namespace) Equal(other *Namespace) bool {
+	var this, that unix.Stat_t
+
+	if unix.Fstat(ns.fd, &this) != nil || unix.Fstat(other.fd, &that) != nil {
+		return false
+	}
+
+	return this.Dev == that.Dev && this.Ino == that.Ino
+}
+
+// Do runs `fn` in the namespace, on a dedicated OS thread. Goroutines started by `fn` do not run in the namespace.
+func (ns *Namespace) Do(fn func() error) error {
+	return onThread(func() error {
+		if err := unix.Setns(ns.fd, unix.CLONE_NEWNET); err != nil {
+			return errors.Join(ErrCannotEnter, err)
+		}
+
+		return fn()
+	})
+}
+
+// Path returns the path of namespace `name`.
+func (s *Store) Path(name string) string {
+	return filepath.Join(s.Dir, name)
+}
+
+// Create creates namespace `name`, and returns it.
+func (s *Store) Create(name string) (*Namespace, error) {
+	if err := validateName(name); err != nil {
+		return nil, err
+	}
+
+	if err := s.ensureDir(); err != nil {
+		return nil, errors.Join(ErrCannotCreate, err)
+	}
+
+	target := s.Path(name)
+
+	file, err := os.OpenFile(target, os.O_RDONLY|os.O_CREATE|os.O_EXCL, filePermissions)
+	if err != nil {
+		if errors.Is(err, os.ErrExist) {
+			return nil, fmt.Errorf("%w: %s", ErrNamespaceExists, name)
+		}
+
+		return nil, errors.Join(ErrCannotCreate, err)
+	}
+
+	_ = file.Close()
+
+	err = onThread(func() error {
+		if err := unix.Unshare(unix.CLONE_NEWNET); err != nil {
+			return err
+		}
+
+		source := fmt.Sprintf(procTaskNetNsFormat, os.Getpid(), unix.Gettid())
+
+		return unix.Mount(source, target, "none", unix.MS_BIND, "")
+	})
+	if err != nil {
+		_ = os.Remove(target)
+
+		return nil, errors.Join(ErrCannotCreate, err)
+	}
+
+	return OpenPath(target)
+}
+
+// Open returns namespace `name`.
+func (s *Store) Open(name string) (*Namespace, error) {
+	if err := validateName(name); err != nil {
+		return nil, err
+	}
+
+	return OpenPath(s.Path(name))
+}
+
+// Delete deletes namespace `name`. It is only destroyed once no process is in it, and no one holds it open.
+// The error wraps os.ErrNotExist if there is no such namespace.
+func (s *Store) Delete(name string) error {
+	if err := validateName(name); err != nil {
+		return err
+	}
+
+	target := s.Path(name)
+
+	// EINVAL if not mounted (eg: left over by a failed Create)
+	if err := unix.Unmount(target, unix.MNT_DETACH); err != nil && !errors.Is(err, unix.EINVAL) {
+		return errors.Join(ErrCannotDelete, &os.PathError{Op: "unmount", Path: target, Err: err})
+	}
+
+	if err := os.Remove(target); err != nil {
+		return errors.Join(ErrCannotDelete, err)
+	}
+
+	return nil
+}
+
+// List returns the names of the namespaces in the store.
+func (s *Store) List() ([]string, error) {
+	entries, err := os.ReadDir(s.Dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return []string{}, nil
+		}
+
+		return nil, err
+	}
+
+	names := []string{}
+
+	for _, entry := range entries {
+		if entry.Type().IsRegular() {
+			names = append(names, entry.Name())
+		}
+	}
+
+	return names, nil
+}
+
+// ensureDir creates the store directory, and makes it a shared mount as `ip netns` does, so that namespaces created
+// afterward are visible from other mount namespaces.
+func (s *Store) ensureDir() error {
+	if err := os.MkdirAll(s.Dir, dirPermissions); err != nil {
+		return err
+	}
+
+	err := unix.Mount("", s.Dir, "none", unix.MS_SHARED|unix.MS_REC, "")
+	if !errors.Is(err, unix.EINVAL) {
+		return err
+	}
+
+	// Not a mount point yet
+	if err = unix.Mount(s.Dir, s.Dir, "none", unix.MS_BIND|unix.MS_REC, ""); err != nil {
+		return err
+	}
+
+	return unix.Mount("", s.Dir, "none", unix.MS_SHARED|unix.MS_REC, "")
+}
+
+// onThread runs `fn` on a dedicated OS thread, and restores the network namespace of the thread afterward.
+func onThread(fn func() error) error {
+	result := make(chan error, 1)
+
+	go func() {
+		result <- withRestore(fn)
+	}()
+
+	return <-result
+}
+
+// withRestore locks the goroutine to its OS thread, runs `fn`, and restores the network namespace of the thread.
+// If that fails, the thread is left locked, so that the runtime terminates it instead of reusing it once the
+// goroutine exits.
+func withRestore(fn func() error) error {
+	runtime.LockOSThread()
+
+	origin, err := Current()
+	if err != nil {
+		runtime.UnlockOSThread()
+
+		return errors.Join(ErrCannotEnter, err)
+	}
+	defer origin.Close()
+
+	err = fn()
+
+	if restoreErr := unix.Setns(origin.fd, unix.CLONE_NEWNET); restoreErr != nil {
+		return errors.Join(err, ErrCannotEnter, restoreErr)
+	}
+
+	runtime.UnlockOSThread()
+
+	return err
+}
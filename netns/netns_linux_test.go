@@ -0,0 +1,144 @@
+/*
+   Copyright Farcloser.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package netns_test
+
+import (
+	"errors"
+	"net"
+	"os"
+	"runtime"
+	"testing"
+
+	"golang.org/x/sys/unix"
+	"gotest.tools/v3/assert"
+
+	"go.farcloser.world/containers/internal/netnstest"
+	"go.farcloser.world/containers/netns"
+)
+
+func TestStore(t *testing.T) {
+	t.Parallel()
+
+	store := netnstest.Store(t)
+
+	_, err := store.Create("../escape")
+	assert.ErrorIs(t, err, netns.ErrInvalidName)
+
+	created, err := store.Create("test")
+	assert.NilError(t, err)
+
+	defer created.Close()
+
+	_, err = store.Create("test")
+	assert.ErrorIs(t, err, netns.ErrNamespaceExists)
+
+	names, err := store.List()
+	assert.NilError(t, err)
+	assert.DeepEqual(t, names, []string{"test"})
+
+	opened, err := store.Open("test")
+	assert.NilError(t, err)
+
+	defer opened.Close()
+
+	current, err := netns.OpenPID(os.Getpid())
+	assert.NilError(t, err)
+
+	defer current.Close()
+
+	assert.Assert(t, opened.Equal(created))
+	assert.Assert(t, !opened.Equal(current))
+
+	// A new namespace only has a loopback interface, which is down
+	var interfaces []net.Interface
+
+	assert.NilError(t, opened.Do(func() error {
+		inside, err := netns.Current()
+		if err != nil {
+			return err
+		}
+		defer inside.Close()
+
+		if !inside.Equal(created) {
+			return errors.New("not in the namespace") //nolint:err113
+		}
+
+		interfaces, err = net.Interfaces()
+
+		return err
+	}))
+	assert.Equal(t, len(interfaces), 1)
+	assert.Equal(t, interfaces[0].Name, "lo")
+
+	assert.NilError(t, store.Delete("test"))
+	assert.ErrorIs(t, store.Delete("test"), os.ErrNotExist)
+
+	_, err = store.Open("test")
+	assert.ErrorIs(t, err, netns.ErrCannotOpen)
+
+	_, err = netns.OpenPath(t.TempDir())
+	assert.ErrorIs(t, err, netns.ErrCannotOpen)
+}
+
+func TestDoRestoresThread(t *testing.T) {
+	t.Parallel()
+
+	created := netnstest.Namespaces(t, "test")[0]
+
+	current, err := netns.OpenPID(os.Getpid())
+	assert.NilError(t, err)
+
+	defer current.Close()
+
+	// The goroutine keeps its thread locked, so that the namespace is checked on the thread that entered it
+	var inside, after *netns.Namespace
+
+	result := make(chan error, 1)
+
+	go func() {
+		runtime.LockOSThread()
+		defer runtime.UnlockOSThread()
+
+		err := netns.WithRestore(func() error {
+			if err := unix.Setns(created.Fd(), unix.CLONE_NEWNET); err != nil {
+				return err
+			}
+
+			var err error
+
+			inside, err = netns.Current()
+
+			return err
+		})
+		if err != nil {
+			result <- err
+
+			return
+		}
+
+		after, err = netns.Current()
+		result <- err
+	}()
+
+	assert.NilError(t, <-result)
+
+	defer inside.Close()
+	defer after.Close()
+
+	assert.Assert(t, inside.Equal(created))
+	assert.Assert(t, after.Equal(current))
+}
@@ -0,0 +1,63 @@
+//go:build !linux
+
+/*
+   Copyright Farcloser.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package netns
+
+func Current() (*Namespace, error) {
+	return nil, ErrUnsupported
+}
+
+func OpenPID(_ int) (*Namespace, error) {
+	return nil, ErrUnsupported
+}
+
+func OpenPath(_ string) (*Namespace, error) {
+	return nil, ErrUnsupported
+}
+
+func (ns *Namespace) Close() error {
+	return ErrUnsupported
+}
+
+func (ns *Namespace) Equal(_ *Namespace) bool {
+	return false
+}
+
+func (ns *Namespace) Do(_ func() error) error {
+	return ErrUnsupported
+}
+
+func (s *Store) Path(_ string) string {
+	return ""
+}
+
+func (s *Store) Create(_ string) (*Namespace, error) {
+	return nil, ErrUnsupported
+}
+
+func (s *Store) Open(_ string) (*Namespace, error) {
+	return nil, ErrUnsupported
+}
+
+func (s *Store) Delete(_ string) error {
+	return ErrUnsupported
+}
+
+func (s *Store) List() ([]string, error) {
+	return nil, ErrUnsupported
+}
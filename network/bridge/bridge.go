@@ -0,0 +1,169 @@
+/*
+   Copyright Farcloser.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// Package bridge is a network driver connecting containers to a bridge on the host, as the default docker network
+// does, without CNI plugins. Addresses come from an ipam.Store, and every operation may be retried after a crash.
+package bridge
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+
+	"go.farcloser.world/containers/network/ipam"
+)
+
+const (
+	// DefaultInterface is the name of the interface in the container namespace.
+	DefaultInterface = "eth0"
+	bridgePrefix     = "br-"
+	vethPrefix       = "veth"
+	// maxInterfaceName is IFNAMSIZ, less the terminating null byte.
+	maxInterfaceName = 15
+)
+
+var (
+	ErrUnsupported      = errors.New("bridge networks are only supported on linux")
+	ErrInvalidNetwork   = errors.New("invalid bridge network")
+	ErrNetworkInUse     = errors.New("bridge network has connected containers")
+	ErrCannotCreate     = errors.New("cannot create bridge network")
+	ErrCannotDelete     = errors.New("cannot delete bridge network")
+	ErrCannotConnect    = errors.New("cannot connect container to bridge network")
+	ErrCannotDisconnect = errors.New("cannot disconnect container from bridge network")
+)
+
+// Network is the configuration of a bridge network.
+type Network struct {
+	Name string
+	// Bridge is the name of the bridge interface, "br-" followed by a hash of the network name if empty.
+	Bridge  string
+	Subnets []ipam.Subnet
+	// MTU applies to the bridge and veths. The kernel default if 0.
+	MTU int
+}
+
+// Endpoint is a container connected to a network.
+type Endpoint struct {
+	Network   string
+	Container string
+	// Interface is the name of the interface in the container namespace. It is only known when connecting.
+	Interface string
+	// HostInterface is the name of the veth end attached to the bridge.
+	HostInterface string
+	MAC           net.HardwareAddr
+	// Addresses are the addresses of the container, one per subnet, with their gateway.
+	Addresses []ipam.Address
+}
+
+// Driver creates bridge networks, and connects containers to them.
+type Driver struct {
+	IPAM *ipam.Store
+}
+
+// NewDriver returns a driver allocating addresses from `store`.
+func NewDriver(store *ipam.Store) *Driver {
+	return &Driver{IPAM: store}
+}
+
+// BridgeName returns the name of the bridge interface of the network. The default name is derived from a hash of
+// the network name, so that networks whose names only differ past the interface name limit do not collide.
+func (n Network) BridgeName() string {
+	if n.Bridge != "" {
+		return n.Bridge
+	}
+
+	sum := sha256.Sum256([]byte(n.Name))
+
+	return (bridgePrefix + hex.EncodeToString(sum[:]))[:maxInterfaceName]
+}
+
+func (n Network) validate() error {
+	if n.Name == "" {
+		return fmt.Errorf("%w: missing name", ErrInvalidNetwork)
+	}
+
+	if len(n.Subnets) == 0 {
+		return fmt.Errorf("%w: %s has no subnet", ErrInvalidNetwork, n.Name)
+	}
+
+	if len(n.BridgeName()) > maxInterfaceName {
+		return fmt.Errorf("%w: bridge name %q is longer than %d characters", ErrInvalidNetwork, n.Bridge,
+			maxInterfaceName)
+	}
+
+	for _, subnet := range n.Subnets {
+		if _, err := subnet.GatewayAddress(); err != nil {
+			return errors.Join(ErrInvalidNetwork, err)
+		}
+	}
+
+	return nil
+}
+
+// hostInterfaceName returns the name of the host end of the veth of `container`. It is derived from the network and
+// container, so that a retry finds what an interrupted attempt left behind.
+func hostInterfaceName(network string, container string) string {
+	sum := sha256.Sum256([]byte(network + "/" + container))
+
+	return (vethPrefix + hex.EncodeToString(sum[:]))[:maxInterfaceName]
+}
+
+// macAddress returns a locally administered address derived from the first IPv4 address, as docker does, or from
+// the network and container otherwise.
+func macAddress(network string, container string, addresses []ipam.Address) net.HardwareAddr {
+	mac := net.HardwareAddr{0x02, 0x42, 0, 0, 0, 0}
+
+	for _, address := range addresses {
+		if ip4 := address.IPNet.IP.To4(); ip4 != nil {
+			copy(mac[2:], ip4)
+
+			return mac
+		}
+	}
+
+	sum := sha256.Sum256([]byte(network + "/" + container))
+	copy(mac[2:], sum[:])
+
+	return mac
+}
+
+func newEndpoint(network Network, container string, iface string, addresses []ipam.Address) *Endpoint {
+	return &Endpoint{
+		Network:       network.Name,
+		Container:     container,
+		Interface:     iface,
+		HostInterface: hostInterfaceName(network.Name, container),
+		MAC:           macAddress(network.Name, container, addresses),
+		Addresses:     addresses,
+	}
+}
+
+// Endpoints returns the containers connected to the network, sorted by container.
+func (d *Driver) Endpoints(network Network) ([]*Endpoint, error) {
+	allocations, err := d.IPAM.Allocations(network.Name, network.Subnets)
+	if err != nil {
+		return nil, err
+	}
+
+	endpoints := make([]*Endpoint, 0, len(allocations))
+	for _, allocation := range allocations {
+		endpoints = append(endpoints, newEndpoint(network, allocation.Owner, "", allocation.Addresses))
+	}
+
+	return endpoints, nil
+}
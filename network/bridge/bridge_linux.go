@@ -0,0 +1,175 @@
+/*
+   Copyright Farcloser.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package bridge
+
+import (
+	"errors"
+
+	"golang.org/x/sys/unix"
+
+	"go.farcloser.world/containers/netlink"
+	"go.farcloser.world/containers/netns"
+)
+
+const loopback = "lo"
+
+// Create creates the bridge of `network`, with the gateway of each subnet, and brings it up. What already exists is
+// left as is.
+func (d *Driver) Create(network Network) error {
+	if err := network.validate(); err != nil {
+		return err
+	}
+
+	bridge := network.BridgeName()
+
+	err := netlink.CreateBridge(bridge, netlink.BridgeOptions{LinkOptions: netlink.LinkOptions{MTU: network.MTU}})
+	if err != nil && !errors.Is(err, unix.EEXIST) {
+		return errors.Join(ErrCannotCreate, err)
+	}
+
+	for _, subnet := range network.Subnets {
+		gateway, err := subnet.GatewayAddress()
+		if err != nil {
+			return errors.Join(ErrCannotCreate, err)
+		}
+
+		if err = netlink.AddAddress(nil, bridge, gateway); err != nil && !errors.Is(err, unix.EEXIST) {
+			return errors.Join(ErrCannotCreate, err)
+		}
+	}
+
+	if err = netlink.SetUp(bridge, nil); err != nil {
+		return errors.Join(ErrCannotCreate, err)
+	}
+
+	return nil
+}
+
+// Delete removes the bridge of `network` and its address allocations. It fails if containers are still connected.
+func (d *Driver) Delete(network Network) error {
+	endpoints, err := d.Endpoints(network)
+	if err != nil {
+		return errors.Join(ErrCannotDelete, err)
+	}
+
+	if len(endpoints) > 0 {
+		return errors.Join(ErrCannotDelete, ErrNetworkInUse)
+	}
+
+	if err = netlink.LinkDel(network.BridgeName()); err != nil && !errors.Is(err, netlink.ErrLinkNotFound) {
+		return errors.Join(ErrCannotDelete, err)
+	}
+
+	if err = d.IPAM.Delete(network.Name); err != nil {
+		return errors.Join(ErrCannotDelete, err)
+	}
+
+	return nil
+}
+
+// Connect allocates addresses to `container`, and connects namespace `ns` to the bridge of `network` through a veth
+// named `iface` inside, DefaultInterface if empty. Default routes go through the gateways. Connecting again
+// recreates the veth with the same addresses.
+func (d *Driver) Connect(network Network, container string, ns *netns.Namespace, iface string) (*Endpoint, error) {
+	if err := network.validate(); err != nil {
+		return nil, err
+	}
+
+	if iface == "" {
+		iface = DefaultInterface
+	}
+
+	addresses, err := d.IPAM.Allocate(network.Name, network.Subnets, container)
+	if err != nil {
+		return nil, errors.Join(ErrCannotConnect, err)
+	}
+
+	endpoint := newEndpoint(network, container, iface, addresses)
+
+	if err = d.wire(network, endpoint, ns); err != nil {
+		_ = netlink.LinkDel(endpoint.HostInterface)
+		_ = d.IPAM.Release(network.Name, container)
+
+		return nil, errors.Join(ErrCannotConnect, err)
+	}
+
+	return endpoint, nil
+}
+
+// Disconnect removes the veth of `container`, and releases its addresses. Disconnecting a container that is not
+// connected is not an error.
+func (d *Driver) Disconnect(network Network, container string) error {
+	// Removing the host end removes the container one as well
+	err := netlink.LinkDel(hostInterfaceName(network.Name, container))
+	if err != nil && !errors.Is(err, netlink.ErrLinkNotFound) {
+		return errors.Join(ErrCannotDisconnect, err)
+	}
+
+	if err = d.IPAM.Release(network.Name, container); err != nil {
+		return errors.Join(ErrCannotDisconnect, err)
+	}
+
+	return nil
+}
+
+func (d *Driver) wire(network Network, endpoint *Endpoint, ns *netns.Namespace) error {
+	// Left behind by an interrupted attempt
+	err := netlink.LinkDel(endpoint.HostInterface)
+	if err != nil && !errors.Is(err, netlink.ErrLinkNotFound) {
+		return err
+	}
+
+	err = netlink.CreateVeth(endpoint.HostInterface, netlink.VethOptions{
+		LinkOptions:   netlink.LinkOptions{MTU: network.MTU},
+		PeerName:      endpoint.Interface,
+		PeerMAC:       endpoint.MAC,
+		PeerNamespace: ns,
+	})
+	if err != nil {
+		return err
+	}
+
+	if err = netlink.SetMaster(endpoint.HostInterface, network.BridgeName()); err != nil {
+		return err
+	}
+
+	if err = netlink.SetUp(endpoint.HostInterface, nil); err != nil {
+		return err
+	}
+
+	for _, address := range endpoint.Addresses {
+		if err = netlink.AddAddress(ns, endpoint.Interface, address.IPNet); err != nil {
+			return err
+		}
+	}
+
+	if err = netlink.SetUp(loopback, ns); err != nil {
+		return err
+	}
+
+	if err = netlink.SetUp(endpoint.Interface, ns); err != nil {
+		return err
+	}
+
+	for _, address := range endpoint.Addresses {
+		if err = netlink.SetDefaultRoute(ns, endpoint.Interface, address.Gateway); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,188 @@
+/*
+   Copyright Farcloser.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package bridge_test
+
+import (
+	"net"
+	"testing"
+
+	"gotest.tools/v3/assert"
+
+	"go.farcloser.world/containers/internal/netnstest"
+	"go.farcloser.world/containers/netlink"
+	"go.farcloser.world/containers/network/bridge"
+	"go.farcloser.world/containers/network/ipam"
+)
+
+func TestDriver(t *testing.T) {
+	t.Parallel()
+
+	namespaces := netnstest.Namespaces(t, "host", "one", "two")
+	host, one, two := namespaces[0], namespaces[1], namespaces[2]
+
+	_, v4, err := net.ParseCIDR("10.4.0.0/24")
+	assert.NilError(t, err)
+
+	_, v6, err := net.ParseCIDR("fd00:4::/64")
+	assert.NilError(t, err)
+
+	network := bridge.Network{
+		Name:    "test",
+		Subnets: []ipam.Subnet{{Subnet: v4}, {Subnet: v6}},
+		MTU:     1400,
+	}
+	driver := bridge.NewDriver(ipam.NewStore(t.TempDir()))
+
+	var (
+		first, second, again *bridge.Endpoint
+		inUseErr             error
+	)
+
+	// Everything is created twice, as a retry after a crash would
+	assert.NilError(t, host.Do(func() error {
+		for range 2 {
+			if err := driver.Create(network); err != nil {
+				return err
+			}
+		}
+
+		if first, err = driver.Connect(network, "one", one, ""); err != nil {
+			return err
+		}
+
+		if again, err = driver.Connect(network, "one", one, ""); err != nil {
+			return err
+		}
+
+		if second, err = driver.Connect(network, "two", two, "net0"); err != nil {
+			return err
+		}
+
+		inUseErr = driver.Delete(network)
+
+		return nil
+	}))
+
+	assert.ErrorIs(t, inUseErr, bridge.ErrNetworkInUse)
+
+	assert.Equal(t, first.Interface, bridge.DefaultInterface)
+	assert.Equal(t, first.MAC.String(), "02:42:0a:04:00:02")
+	assert.Equal(t, first.Addresses[0].IPNet.String(), "10.4.0.2/24")
+	assert.Equal(t, first.Addresses[0].Gateway.String(), "10.4.0.1")
+	assert.Equal(t, first.Addresses[1].IPNet.String(), "fd00:4::2/64")
+	assert.Equal(t, first.Addresses[1].Gateway.String(), "fd00:4::1")
+	assert.DeepEqual(t, again, first)
+	assert.Equal(t, second.Interface, "net0")
+	assert.Equal(t, second.Addresses[0].IPNet.String(), "10.4.0.3/24")
+	assert.Assert(t, second.HostInterface != first.HostInterface)
+
+	endpoints, err := driver.Endpoints(network)
+	assert.NilError(t, err)
+	assert.Equal(t, len(endpoints), 2)
+	assert.Equal(t, endpoints[0].Container, "one")
+	assert.Equal(t, endpoints[0].HostInterface, first.HostInterface)
+	assert.DeepEqual(t, endpoints[0].Addresses, first.Addresses)
+
+	interfaces, err := netlink.ListInterfaces(one)
+	assert.NilError(t, err)
+
+	for _, iface := range interfaces {
+		assert.Assert(t, iface.Up)
+
+		if iface.Name == bridge.DefaultInterface {
+			assert.Equal(t, iface.MAC.String(), first.MAC.String())
+			assert.Equal(t, iface.MTU, 1400)
+		}
+	}
+
+	routes, err := netlink.ListRoutes(one, bridge.DefaultInterface)
+	assert.NilError(t, err)
+
+	gateways := 0
+
+	for _, route := range routes {
+		if route.Destination == nil {
+			gateways++
+		}
+	}
+
+	assert.Equal(t, gateways, 2)
+
+	var hostInterfaces []netlink.InterfaceConfig
+
+	assert.NilError(t, host.Do(func() error {
+		hostInterfaces, err = netlink.ListInterfaces(nil)
+
+		return err
+	}))
+
+	found := map[string]netlink.InterfaceConfig{}
+	for _, iface := range hostInterfaces {
+		found[iface.Name] = iface
+	}
+
+	assert.Assert(t, found[network.BridgeName()].Up)
+	assert.Equal(t, found[network.BridgeName()].Addresses[0].IPNet.String(), "10.4.0.1/24")
+	assert.Assert(t, found[first.HostInterface].Up)
+	assert.Assert(t, found[second.HostInterface].Up)
+
+	assert.NilError(t, host.Do(func() error {
+		for _, container := range []string{"one", "two", "two"} {
+			if err := driver.Disconnect(network, container); err != nil {
+				return err
+			}
+		}
+
+		if err := driver.Delete(network); err != nil {
+			return err
+		}
+
+		hostInterfaces, err = netlink.ListInterfaces(nil)
+
+		return err
+	}))
+
+	assert.Equal(t, len(hostInterfaces), 1)
+
+	interfaces, err = netlink.ListInterfaces(one)
+	assert.NilError(t, err)
+	assert.Equal(t, len(interfaces), 1)
+
+	endpoints, err = driver.Endpoints(network)
+	assert.NilError(t, err)
+	assert.Equal(t, len(endpoints), 0)
+}
+
+func TestDriverInvalid(t *testing.T) {
+	t.Parallel()
+
+	driver := bridge.NewDriver(ipam.NewStore(t.TempDir()))
+
+	_, v4, err := net.ParseCIDR("10.4.0.0/24")
+	assert.NilError(t, err)
+
+	testCases := []bridge.Network{
+		{Subnets: []ipam.Subnet{{Subnet: v4}}},
+		{Name: "test"},
+		{Name: "test", Bridge: "a-very-long-bridge-name", Subnets: []ipam.Subnet{{Subnet: v4}}},
+		{Name: "test", Subnets: []ipam.Subnet{{Subnet: v4, Gateway: net.ParseIP("10.5.0.1")}}},
+	}
+
+	for _, network := range testCases {
+		assert.ErrorIs(t, driver.Create(network), bridge.ErrInvalidNetwork)
+	}
+}
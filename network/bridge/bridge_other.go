@@ -0,0 +1,37 @@
+//go:build !linux
+
+/*
+   Copyright Farcloser.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package bridge
+
+import "go.farcloser.world/containers/netns"
+
+func (d *Driver) Create(_ Network) error {
+	return ErrUnsupported
+}
+
+func (d *Driver) Delete(_ Network) error {
+	return ErrUnsupported
+}
+
+func (d *Driver) Connect(_ Network, _ string, _ *netns.Namespace, _ string) (*Endpoint, error) {
+	return nil, ErrUnsupported
+}
+
+func (d *Driver) Disconnect(_ Network, _ string) error {
+	return ErrUnsupported
+}
@@ -0,0 +1,41 @@
+/*
+   Copyright Farcloser.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package bridge_test
+
+import (
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+
+	"go.farcloser.world/containers/network/bridge"
+)
+
+func TestBridgeName(t *testing.T) {
+	t.Parallel()
+
+	first := bridge.Network{Name: "a-very-long-network-name-1"}.BridgeName()
+	second := bridge.Network{Name: "a-very-long-network-name-2"}.BridgeName()
+
+	// Names only differing past the interface name limit do not collide
+	assert.Assert(t, first != second)
+	assert.Assert(t, strings.HasPrefix(first, "br-"))
+	assert.Equal(t, len(first), 15)
+	assert.Equal(t, first, bridge.Network{Name: "a-very-long-network-name-1"}.BridgeName())
+
+	assert.Equal(t, bridge.Network{Name: "test", Bridge: "docker0"}.BridgeName(), "docker0")
+}
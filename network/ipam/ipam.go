@@ -0,0 +1,408 @@
+/*
+   Copyright Farcloser.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// Package ipam allocates addresses from subnets for network endpoints. Allocations are kept on disk, one file per
+// network under a lock, so that they survive restarts and are shared between processes.
+package ipam
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+const (
+	dirPermissions  = 0o700
+	filePermissions = 0o600
+	stateSuffix     = ".json"
+	lockSuffix      = ".lock"
+)
+
+var (
+	ErrUnsupported   = errors.New("address allocation is only supported on linux")
+	ErrInvalidName   = errors.New("invalid network name")
+	ErrInvalidSubnet = errors.New("invalid subnet")
+	ErrExhausted     = errors.New("no address left in subnet")
+	ErrCannotLock    = errors.New("cannot lock address allocations")
+	ErrCannotRead    = errors.New("cannot read address allocations")
+	ErrCannotWrite   = errors.New("cannot write address allocations")
+)
+
+// Subnet is a range addresses are allocated from.
+type Subnet struct {
+	Subnet *net.IPNet
+	// Gateway is never allocated, and is handed out along with addresses. The first address of the subnet if nil.
+	Gateway net.IP
+}
+
+// Address is an allocated address.
+type Address struct {
+	// IPNet is the address, with the mask of its subnet (eg: 10.4.0.2/24).
+	IPNet   *net.IPNet
+	Gateway net.IP
+}
+
+// Allocation is the addresses of an owner (eg: a container).
+type Allocation struct {
+	Owner     string
+	Addresses []Address
+}
+
+// Store keeps the allocations of each network in a directory.
+type Store struct {
+	Dir string
+}
+
+// state is the content of the allocations file of a network.
+type state struct {
+	// Owners are the addresses of each owner.
+	Owners map[string][]string `json:"owners"`
+	// Last is the address last handed out in each subnet. Searches start after it, so that released addresses are
+	// not reused right away.
+	Last map[string]string `json:"last"`
+}
+
+// NewStore returns a store keeping allocations in `dir`.
+func NewStore(dir string) *Store {
+	return &Store{Dir: dir}
+}
+
+// Allocate returns an address in each subnet for `owner`. Calling it again for the same owner returns the same
+// addresses, so that an interrupted setup may simply be retried.
+func (s *Store) Allocate(network string, subnets []Subnet, owner string) ([]Address, error) {
+	var addresses []Address
+
+	err := s.update(network, func(st *state) error {
+		var err error
+
+		addresses, err = st.allocate(subnets, owner)
+
+		return err
+	})
+
+	return addresses, err
+}
+
+// Release frees the addresses of `owner`. Releasing an unknown owner is not an error.
+func (s *Store) Release(network string, owner string) error {
+	return s.update(network, func(st *state) error {
+		delete(st.Owners, owner)
+
+		return nil
+	})
+}
+
+// Allocations returns the addresses of all owners, sorted by owner.
+// Allocations are replaced atomically, so that reading them takes no lock, and writes nothing.
+func (s *Store) Allocations(network string, subnets []Subnet) ([]Allocation, error) {
+	if err := validateName(network); err != nil {
+		return nil, err
+	}
+
+	st, err := readState(filepath.Join(s.Dir, network+stateSuffix))
+	if err != nil {
+		return nil, err
+	}
+
+	allocations := make([]Allocation, 0, len(st.Owners))
+	for owner := range st.Owners {
+		allocations = append(allocations, Allocation{Owner: owner, Addresses: st.owned(subnets, owner)})
+	}
+
+	slices.SortFunc(allocations, func(a, b Allocation) int {
+		return strings.Compare(a.Owner, b.Owner)
+	})
+
+	return allocations, nil
+}
+
+// Delete removes all allocations of `network`.
+// The lock file is left behind: removing it would let a process waiting on it and a newcomer both hold a lock.
+func (s *Store) Delete(network string) error {
+	return s.locked(network, func(pth string) error {
+		if err := os.Remove(pth); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return errors.Join(ErrCannotWrite, err)
+		}
+
+		return nil
+	})
+}
+
+// update runs `fn` on the allocations of `network` under an exclusive lock, and saves them if it succeeds.
+func (s *Store) update(network string, fn func(st *state) error) error {
+	return s.locked(network, func(pth string) error {
+		st, err := readState(pth)
+		if err != nil {
+			return err
+		}
+
+		if err = fn(st); err != nil {
+			return err
+		}
+
+		content, err := json.Marshal(st)
+		if err != nil {
+			return errors.Join(ErrCannotWrite, err)
+		}
+
+		return writeFile(pth, content)
+	})
+}
+
+// locked runs `fn` with the path of the allocations file of `network`, under an exclusive lock.
+func (s *Store) locked(network string, fn func(pth string) error) error {
+	if err := validateName(network); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(s.Dir, dirPermissions); err != nil {
+		return errors.Join(ErrCannotWrite, err)
+	}
+
+	unlock, err := lock(filepath.Join(s.Dir, network+lockSuffix))
+	if err != nil {
+		return errors.Join(ErrCannotLock, err)
+	}
+	defer unlock()
+
+	return fn(filepath.Join(s.Dir, network+stateSuffix))
+}
+
+// readState returns the allocations saved in `pth`, which are empty if it does not exist.
+func readState(pth string) (*state, error) {
+	st := &state{Owners: map[string][]string{}, Last: map[string]string{}}
+
+	content, err := os.ReadFile(pth)
+	if errors.Is(err, os.ErrNotExist) {
+		return st, nil
+	}
+
+	if err != nil {
+		return nil, errors.Join(ErrCannotRead, err)
+	}
+
+	if err = json.Unmarshal(content, st); err != nil {
+		return nil, errors.Join(ErrCannotRead, fmt.Errorf("%s: %w", pth, err))
+	}
+
+	return st, nil
+}
+
+// writeFile replaces `pth` atomically, so that a crash leaves either the old or the new allocations.
+func writeFile(pth string, content []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(pth), filepath.Base(pth)+".*")
+	if err != nil {
+		return errors.Join(ErrCannotWrite, err)
+	}
+
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(content)
+	if err == nil {
+		err = tmp.Sync()
+	}
+
+	err = errors.Join(err, tmp.Close())
+	if err == nil {
+		err = os.Chmod(tmp.Name(), filePermissions)
+	}
+
+	if err == nil {
+		err = os.Rename(tmp.Name(), pth)
+	}
+
+	if err != nil {
+		return errors.Join(ErrCannotWrite, err)
+	}
+
+	return nil
+}
+
+func (st *state) allocate(subnets []Subnet, owner string) ([]Address, error) {
+	used := map[string]bool{}
+
+	for _, ips := range st.Owners {
+		for _, ip := range ips {
+			used[ip] = true
+		}
+	}
+
+	owned := st.Owners[owner]
+	addresses := make([]Address, 0, len(subnets))
+
+	for _, subnet := range subnets {
+		network, gateway, err := subnet.normalize()
+		if err != nil {
+			return nil, err
+		}
+
+		// Already allocated, by an earlier call that may or may not have completed
+		if ip := find(owned, network); ip != nil {
+			addresses = append(addresses, Address{IPNet: &net.IPNet{IP: ip, Mask: network.Mask}, Gateway: gateway})
+
+			continue
+		}
+
+		first, last := hostRange(network)
+
+		start := first
+		if previous := net.ParseIP(st.Last[network.String()]); previous != nil && network.Contains(previous) {
+			start = next(canonical(previous), first, last)
+		}
+
+		ip := start
+
+		for used[ip.String()] || ip.Equal(gateway) {
+			if ip = next(ip, first, last); ip.Equal(start) {
+				return nil, fmt.Errorf("%w: %s", ErrExhausted, network)
+			}
+		}
+
+		used[ip.String()] = true
+		owned = append(owned, ip.String())
+		st.Last[network.String()] = ip.String()
+		addresses = append(addresses, Address{IPNet: &net.IPNet{IP: ip, Mask: network.Mask}, Gateway: gateway})
+	}
+
+	st.Owners[owner] = owned
+
+	return addresses, nil
+}
+
+func (st *state) owned(subnets []Subnet, owner string) []Address {
+	var addresses []Address
+
+	for _, subnet := range subnets {
+		network, gateway, err := subnet.normalize()
+		if err != nil {
+			continue
+		}
+
+		if ip := find(st.Owners[owner], network); ip != nil {
+			addresses = append(addresses, Address{IPNet: &net.IPNet{IP: ip, Mask: network.Mask}, Gateway: gateway})
+		}
+	}
+
+	return addresses
+}
+
+// GatewayAddress returns the gateway, with the mask of the subnet (eg: 10.4.0.1/24), as set on a bridge.
+func (s Subnet) GatewayAddress() (*net.IPNet, error) {
+	network, gateway, err := s.normalize()
+	if err != nil {
+		return nil, err
+	}
+
+	return &net.IPNet{IP: gateway, Mask: network.Mask}, nil
+}
+
+// normalize returns the subnet with its host bits cleared, and its gateway.
+func (s Subnet) normalize() (*net.IPNet, net.IP, error) {
+	if s.Subnet == nil {
+		return nil, nil, fmt.Errorf("%w: missing", ErrInvalidSubnet)
+	}
+
+	network := &net.IPNet{IP: canonical(s.Subnet.IP).Mask(s.Subnet.Mask), Mask: s.Subnet.Mask}
+
+	ones, bits := network.Mask.Size()
+	if bits == 0 || len(network.IP)*8 != bits || bits-ones < 2 {
+		return nil, nil, fmt.Errorf("%w: %s is too small", ErrInvalidSubnet, s.Subnet)
+	}
+
+	first, last := hostRange(network)
+
+	gateway := first
+	if s.Gateway != nil {
+		gateway = canonical(s.Gateway)
+	}
+
+	if !network.Contains(gateway) || compare(gateway, first) < 0 || compare(gateway, last) > 0 {
+		return nil, nil, fmt.Errorf("%w: gateway %s is not usable in %s", ErrInvalidSubnet, s.Gateway, network)
+	}
+
+	return network, gateway, nil
+}
+
+// hostRange returns the first and last addresses that may be handed out in `network`, leaving out the network and
+// broadcast addresses for IPv4, and the subnet-router anycast address for IPv6.
+func hostRange(network *net.IPNet) (net.IP, net.IP) {
+	first := slices.Clone(network.IP)
+	first[len(first)-1]++
+
+	last := slices.Clone(network.IP)
+	for index := range last {
+		last[index] |= ^network.Mask[index]
+	}
+
+	if len(last) == net.IPv4len {
+		last[len(last)-1]--
+	}
+
+	return first, last
+}
+
+// next returns the address after `ip`, wrapping around to `first` after `last`.
+func next(ip net.IP, first net.IP, last net.IP) net.IP {
+	if compare(ip, last) >= 0 || compare(ip, first) < 0 {
+		return slices.Clone(first)
+	}
+
+	res := slices.Clone(ip)
+	for index := len(res) - 1; index >= 0; index-- {
+		res[index]++
+		if res[index] != 0 {
+			break
+		}
+	}
+
+	return res
+}
+
+func find(ips []string, network *net.IPNet) net.IP {
+	for _, candidate := range ips {
+		if ip := net.ParseIP(candidate); ip != nil && network.Contains(ip) {
+			return canonical(ip)
+		}
+	}
+
+	return nil
+}
+
+func compare(a net.IP, b net.IP) int {
+	return slices.Compare(a, b)
+}
+
+// canonical returns IPv4 addresses on 4 bytes, so that they compare with their subnet.
+func canonical(ip net.IP) net.IP {
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4
+	}
+
+	return ip.To16()
+}
+
+func validateName(name string) error {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, "/\x00") {
+		return fmt.Errorf("%w: %q", ErrInvalidName, name)
+	}
+
+	return nil
+}
@@ -0,0 +1,221 @@
+/*
+   Copyright Farcloser.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package ipam_test
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"gotest.tools/v3/assert"
+
+	"go.farcloser.world/containers/network/ipam"
+)
+
+func mustSubnet(t *testing.T, cidr string, gateway string) ipam.Subnet {
+	t.Helper()
+
+	_, subnet, err := net.ParseCIDR(cidr)
+	assert.NilError(t, err)
+
+	return ipam.Subnet{Subnet: subnet, Gateway: net.ParseIP(gateway)}
+}
+
+func TestAllocate(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	store := ipam.NewStore(dir)
+	subnets := []ipam.Subnet{
+		mustSubnet(t, "10.4.0.0/24", ""),
+		mustSubnet(t, "fd00:4::/64", "fd00:4::ffff"),
+	}
+
+	first, err := store.Allocate("test", subnets, "one")
+	assert.NilError(t, err)
+	assert.Equal(t, len(first), 2)
+	assert.Equal(t, first[0].IPNet.String(), "10.4.0.2/24")
+	assert.Equal(t, first[0].Gateway.String(), "10.4.0.1")
+	assert.Equal(t, first[1].IPNet.String(), "fd00:4::1/64")
+	assert.Equal(t, first[1].Gateway.String(), "fd00:4::ffff")
+
+	// Idempotent, and persisted
+	again, err := ipam.NewStore(dir).Allocate("test", subnets, "one")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, again, first)
+
+	second, err := store.Allocate("test", subnets, "two")
+	assert.NilError(t, err)
+	assert.Equal(t, second[0].IPNet.String(), "10.4.0.3/24")
+	assert.Equal(t, second[1].IPNet.String(), "fd00:4::2/64")
+
+	// Released addresses are not handed out again right away
+	assert.NilError(t, store.Release("test", "one"))
+	assert.NilError(t, store.Release("test", "unknown"))
+
+	third, err := store.Allocate("test", subnets, "three")
+	assert.NilError(t, err)
+	assert.Equal(t, third[0].IPNet.String(), "10.4.0.4/24")
+
+	allocations, err := store.Allocations("test", subnets)
+	assert.NilError(t, err)
+	assert.Equal(t, len(allocations), 2)
+	assert.Equal(t, allocations[0].Owner, "three")
+	assert.Equal(t, allocations[1].Owner, "two")
+	assert.DeepEqual(t, allocations[1].Addresses, second)
+
+	// Networks do not share addresses
+	other, err := store.Allocate("other", subnets, "one")
+	assert.NilError(t, err)
+	assert.Equal(t, other[0].IPNet.String(), "10.4.0.2/24")
+
+	assert.NilError(t, store.Delete("other"))
+
+	_, err = os.Stat(filepath.Join(dir, "other.json"))
+	assert.Assert(t, os.IsNotExist(err))
+	// Processes waiting on the lock and newcomers must keep sharing it
+	_, err = os.Stat(filepath.Join(dir, "other.lock"))
+	assert.NilError(t, err)
+
+	allocations, err = store.Allocations("other", subnets)
+	assert.NilError(t, err)
+	assert.Equal(t, len(allocations), 0)
+}
+
+func TestAllocationsReadOnly(t *testing.T) {
+	t.Parallel()
+
+	dir := filepath.Join(t.TempDir(), "ipam")
+	store := ipam.NewStore(dir)
+	subnets := []ipam.Subnet{mustSubnet(t, "10.4.0.0/24", "")}
+
+	// Reading unknown networks creates nothing
+	allocations, err := store.Allocations("test", subnets)
+	assert.NilError(t, err)
+	assert.Equal(t, len(allocations), 0)
+
+	_, err = os.Stat(dir)
+	assert.Assert(t, os.IsNotExist(err))
+
+	_, err = store.Allocate("test", subnets, "one")
+	assert.NilError(t, err)
+
+	// Nor does reading existing ones rewrite them
+	before, err := os.Stat(filepath.Join(dir, "test.json"))
+	assert.NilError(t, err)
+
+	allocations, err = store.Allocations("test", subnets)
+	assert.NilError(t, err)
+	assert.Equal(t, len(allocations), 1)
+	assert.Equal(t, allocations[0].Addresses[0].IPNet.String(), "10.4.0.2/24")
+
+	after, err := os.Stat(filepath.Join(dir, "test.json"))
+	assert.NilError(t, err)
+	assert.Assert(t, os.SameFile(before, after))
+}
+
+func TestAllocateExhausted(t *testing.T) {
+	t.Parallel()
+
+	store := ipam.NewStore(t.TempDir())
+	// 10.4.0.1 is the gateway, leaving 10.4.0.2 only
+	subnets := []ipam.Subnet{mustSubnet(t, "10.4.0.0/30", "")}
+
+	addresses, err := store.Allocate("test", subnets, "one")
+	assert.NilError(t, err)
+	assert.Equal(t, addresses[0].IPNet.String(), "10.4.0.2/30")
+
+	_, err = store.Allocate("test", subnets, "two")
+	assert.ErrorIs(t, err, ipam.ErrExhausted)
+
+	// Wraps around once released
+	assert.NilError(t, store.Release("test", "one"))
+
+	addresses, err = store.Allocate("test", subnets, "two")
+	assert.NilError(t, err)
+	assert.Equal(t, addresses[0].IPNet.String(), "10.4.0.2/30")
+}
+
+func TestAllocateInvalid(t *testing.T) {
+	t.Parallel()
+
+	store := ipam.NewStore(t.TempDir())
+
+	testCases := []struct {
+		network string
+		subnet  ipam.Subnet
+		err     error
+	}{
+		{"../escape", mustSubnet(t, "10.4.0.0/24", ""), ipam.ErrInvalidName},
+		{"test", ipam.Subnet{}, ipam.ErrInvalidSubnet},
+		{"test", mustSubnet(t, "10.4.0.0/31", ""), ipam.ErrInvalidSubnet},
+		{"test", mustSubnet(t, "10.4.0.0/24", "10.5.0.1"), ipam.ErrInvalidSubnet},
+		{"test", mustSubnet(t, "10.4.0.0/24", "10.4.0.255"), ipam.ErrInvalidSubnet},
+	}
+
+	for _, testCase := range testCases {
+		_, err := store.Allocate(testCase.network, []ipam.Subnet{testCase.subnet}, "one")
+		assert.ErrorIs(t, err, testCase.err)
+	}
+}
+
+func TestAllocateConcurrent(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	subnets := []ipam.Subnet{mustSubnet(t, "10.4.0.0/24", "")}
+
+	const owners = 20
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		handed  = map[string]string{}
+		failure error
+	)
+
+	for index := range owners {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			// Separate stores, as separate processes would have
+			owner := fmt.Sprintf("owner-%d", index)
+			addresses, err := ipam.NewStore(dir).Allocate("test", subnets, owner)
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			if err != nil {
+				failure = err
+
+				return
+			}
+
+			handed[addresses[0].IPNet.IP.String()] = owner
+		}()
+	}
+
+	wg.Wait()
+
+	assert.NilError(t, failure)
+	assert.Equal(t, len(handed), owners)
+}
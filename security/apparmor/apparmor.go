@@ -15,3 +15,138 @@
 */
 
 package apparmor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/containerd/containerd/v2/core/containers"
+	"github.com/containerd/containerd/v2/pkg/oci"
+	"github.com/opencontainers/runtime-spec/specs-go"
+)
+
+type mode = string
+
+type Profile struct {
+	// Name of the profile, relative to its namespace. Hats are named `parent//hat`.
+	Name string `json:"name"`
+	Mode mode   `json:"mode,omitempty"`
+	// Namespace is empty for the root namespace. Nested namespaces are named `parent//child`.
+	Namespace string `json:"namespace,omitempty"`
+	// Attach is the attachment specification of the profile, usually an executable path or glob.
+	Attach string     `json:"attach,omitempty"`
+	Hats   []*Profile `json:"hats,omitempty"`
+}
+
+const (
+	Enforce    = mode("enforce")
+	Unconfined = mode("unconfined")
+	Complain   = mode("complain")
+	Kill       = mode("kill")
+	// Mixed is reported for stacked profiles that do not share the same mode.
+	Mixed = mode("mixed")
+
+	kernelPath = "/sys/kernel/security/apparmor"
+	procPath   = "/proc"
+)
+
+var (
+	// ErrUnsupported is returned by functions probing or modifying the host on platforms without AppArmor.
+	ErrUnsupported = errors.New("apparmor is not supported on this platform")
+
+	ErrInvalidProfileName  = errors.New("invalid profile name")
+	ErrCannotLoadProfile   = errors.New("cannot load apparmor profile")
+	ErrParserFailed        = errors.New("apparmor_parser failed")
+	ErrParserTimeout       = errors.New("apparmor_parser timed out")
+	ErrCannotUnloadProfile = errors.New("cannot unload apparmor profile")
+	ErrProfileNotLoaded    = errors.New("apparmor profile is not loaded")
+	ErrPermissionDenied    = errors.New("permission denied")
+)
+
+// ParseError is a syntax or semantic error reported by apparmor_parser for a specific line of a profile.
+type ParseError struct {
+	File    string
+	Line    int
+	Message string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Message)
+}
+
+// Loader compiles and loads profiles from their text through apparmor_parser.
+type Loader struct {
+	// ParserPath is the apparmor_parser binary to use. If empty, it is looked up in PATH.
+	ParserPath string
+
+	// CacheDir, if set, is used by apparmor_parser to store compiled policies, and by the Loader to remember the
+	// source of loaded profiles, so that reloading an identical profile can be skipped altogether.
+	CacheDir string
+
+	// Timeout bounds the execution of apparmor_parser. Defaults to 30 seconds.
+	Timeout time.Duration
+
+	// ListProfiles returns the currently loaded profiles. Defaults to Profiles.
+	ListProfiles func() ([]*Profile, error)
+}
+
+// LoadProfile compiles and loads (or replaces) the profile `name` from `text`, using the default Loader.
+func LoadProfile(name string, text string) error {
+	return (&Loader{}).LoadProfile(context.Background(), name, text)
+}
+
+// SecurityFS is the AppArmor interface exposed by the kernel in securityfs.
+type SecurityFS struct {
+	// Root is where the AppArmor securityfs directory is mounted, usually /sys/kernel/security/apparmor.
+	Root string
+}
+
+// DefaultSecurityFS returns the host AppArmor securityfs.
+func DefaultSecurityFS() *SecurityFS {
+	return &SecurityFS{Root: kernelPath}
+}
+
+// ProcFS gives access to the AppArmor attributes of processes.
+type ProcFS struct {
+	// Root is where procfs is mounted, usually /proc.
+	Root string
+}
+
+// DefaultProcFS returns the host procfs.
+func DefaultProcFS() *ProcFS {
+	return &ProcFS{Root: procPath}
+}
+
+// UnloadProfile removes the profile `name` from the kernel.
+// It needs write access to /sys/kernel/security/apparmor/.remove .
+func UnloadProfile(name string) error {
+	return DefaultSecurityFS().UnloadProfile(name)
+}
+
+// Profiles return the list of currently loaded profiles.
+//
+// Root is not needed, but ability to read /sys/kernel/security/apparmor/policy/profiles is.
+// This might not be accessible from user namespaces (because securityfs cannot be mounted in a user namespace).
+func Profiles() ([]*Profile, error) {
+	return DefaultSecurityFS().Profiles()
+}
+
+// ProfileOf returns the confinement of the process `pid`, using the host procfs.
+func ProfileOf(pid int) (*Confinement, error) {
+	return DefaultProcFS().ProfileOf(pid)
+}
+
+// WithProfile returns a SpecOpts that attaches the profile to the spec.
+func WithProfile(name string) oci.SpecOpts {
+	return func(_ context.Context, _ oci.Client, _ *containers.Container, s *specs.Spec) error {
+		if s.Process == nil {
+			s.Process = &specs.Process{}
+		}
+
+		s.Process.ApparmorProfile = name
+
+		return nil
+	}
+}
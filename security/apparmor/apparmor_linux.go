@@ -17,39 +17,17 @@
 package apparmor
 
 import (
-	"context"
-	"errors"
 	"os"
 	"os/exec"
 	"path/filepath"
-	"strings"
 	"sync"
 
 	"github.com/containerd/containerd/v2/contrib/apparmor"
-	"github.com/containerd/containerd/v2/core/containers"
-	"github.com/containerd/containerd/v2/pkg/oci"
 	"github.com/moby/sys/userns"
-	"github.com/opencontainers/runtime-spec/specs-go"
-
-	"go.farcloser.world/core/filesystem"
 )
 
-type mode = string
-
-type Profile struct {
-	Name string `json:"name"`
-	Mode mode   `json:"mode,omitempty"`
-}
-
 const (
-	Enforce    = mode("enforce")
-	Unconfined = mode("unconfined")
-	Complain   = mode("complain")
-
-	kernelPath   = "/sys/kernel/security/apparmor"
-	removePath   = "/sys/kernel/security/apparmor/.remove"
-	profilesPath = "/sys/kernel/security/apparmor/policy/profiles"
-	enabledPath  = "/sys/module/apparmor/parameters/enabled"
+	enabledPath = "/sys/module/apparmor/parameters/enabled"
 
 	execBinary = "aa-exec"
 )
@@ -67,24 +45,35 @@ var (
 // Note this may not be accessible from user namespaces.
 func Supported() bool {
 	checkAppArmor.Do(func() {
-		if _, err := os.Stat(kernelPath); err == nil {
-			appArmorSupported = true
-		}
+		appArmorSupported = SupportedAt("/")
 	})
 
 	return appArmorSupported
 }
 
+// SupportedAt checks whether AppArmor is supported by the host filesystem mounted at `root`.
+func SupportedAt(root string) bool {
+	_, err := os.Stat(filepath.Join(root, kernelPath))
+
+	return err == nil
+}
+
 // Enabled checks whether AppArmnor is enabled.
 func Enabled() bool {
 	checkParamEnabled.Do(func() {
-		buf, err := os.ReadFile(enabledPath)
-		paramEnabled = err == nil && len(buf) > 1 && buf[0] == 'Y'
+		paramEnabled = EnabledAt("/")
 	})
 
 	return paramEnabled
 }
 
+// EnabledAt checks whether AppArmor is enabled, according to the host filesystem mounted at `root`.
+func EnabledAt(root string) bool {
+	buf, err := os.ReadFile(filepath.Join(root, enabledPath))
+
+	return err == nil && len(buf) > 1 && buf[0] == 'Y'
+}
+
 // CanLoadProfile checks if we can load a new profile. This requires root and full access.
 func CanLoadProfile() bool {
 	// In some rare circumstances, apparmor may be enabled, but the tooling could be missing
@@ -105,7 +94,7 @@ func CanLoadProfile() bool {
 }
 
 // CanApplyProfile checks if we can apply an already loaded profile.
-// Note that this does not require access to profilesPath.
+// Note that this does not require access to securityfs.
 func CanApplyProfile(profileName string) bool {
 	if !Enabled() {
 		return false
@@ -126,63 +115,3 @@ func DumpCurrentProfileAs(name string) (string, error) {
 func LoadDefaultProfileAs(name string) error {
 	return apparmor.LoadDefaultProfile(name)
 }
-
-// UnloadProfile needs access to /sys/kernel/security/apparmor/.remove .
-func UnloadProfile(name string) error {
-	// FIXME: not safe
-	remover, err := os.OpenFile(
-		removePath,
-		os.O_RDWR|os.O_TRUNC,
-		filesystem.FilePermissionsDefault)
-	if err != nil {
-		return err
-	}
-
-	_, err = remover.WriteString(name)
-
-	return errors.Join(err, remover.Close())
-}
-
-// WithProfile returns a SpecOpts that attaches the profile to the spec.
-func WithProfile(name string) oci.SpecOpts {
-	return func(_ context.Context, _ oci.Client, _ *containers.Container, s *specs.Spec) error {
-		s.Process.ApparmorProfile = name
-
-		return nil
-	}
-}
-
-// Profiles return the list of currently loaded profiles.
-//
-// Root is not needed, but ability to read /sys/kernel/security/apparmor/policy/profiles is.
-// This might not be accessible from user namespaces (because securityfs cannot be mounted in a user namespace).
-func Profiles() ([]*Profile, error) {
-	entries, err := os.ReadDir(profilesPath)
-	if err != nil {
-		return nil, err
-	}
-
-	res := []*Profile{}
-
-	for _, entry := range entries {
-		var bytes []byte
-
-		profile := &Profile{}
-
-		bytes, err = os.ReadFile(filepath.Join(profilesPath, entry.Name(), "name"))
-		if err != nil {
-			continue
-		}
-
-		profile.Name = strings.TrimSpace(string(bytes))
-
-		bytes, err = os.ReadFile(filepath.Join(profilesPath, entry.Name(), "mode"))
-		if err == nil {
-			profile.Mode = strings.TrimSpace(string(bytes))
-		}
-
-		res = append(res, profile)
-	}
-
-	return res, nil
-}
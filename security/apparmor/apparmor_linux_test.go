@@ -0,0 +1,38 @@
+/*
+   Copyright Farcloser.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package apparmor_test
+
+import (
+	"testing"
+
+	"gotest.tools/v3/assert"
+
+	"go.farcloser.world/containers/internal/fixtures"
+	"go.farcloser.world/containers/security/apparmor"
+)
+
+func TestSupportedAt(t *testing.T) {
+	t.Parallel()
+
+	root := fixtures.Host(fixtures.CgroupV2Systemd)
+	assert.Assert(t, apparmor.SupportedAt(root))
+	assert.Assert(t, apparmor.EnabledAt(root))
+
+	root = fixtures.Host(fixtures.WSL)
+	assert.Assert(t, !apparmor.SupportedAt(root))
+	assert.Assert(t, !apparmor.EnabledAt(root))
+}
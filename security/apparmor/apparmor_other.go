@@ -0,0 +1,71 @@
+//go:build !linux
+
+/*
+   Copyright Farcloser.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package apparmor
+
+import "context"
+
+func Supported() bool {
+	return false
+}
+
+func SupportedAt(_ string) bool {
+	return false
+}
+
+func Enabled() bool {
+	return false
+}
+
+func EnabledAt(_ string) bool {
+	return false
+}
+
+func CanLoadProfile() bool {
+	return false
+}
+
+func CanApplyProfile(_ string) bool {
+	return false
+}
+
+func DumpCurrentProfileAs(_ string) (string, error) {
+	return "", ErrUnsupported
+}
+
+func LoadDefaultProfileAs(_ string) error {
+	return ErrUnsupported
+}
+
+func (*Loader) LoadProfile(_ context.Context, _ string, _ string) error {
+	return ErrUnsupported
+}
+
+func (*Loader) forget(_ string) {}
+
+func (*SecurityFS) Profiles() ([]*Profile, error) {
+	return nil, ErrUnsupported
+}
+
+func (*SecurityFS) UnloadProfile(_ string) error {
+	return ErrUnsupported
+}
+
+func (*ProcFS) ProfileOf(_ int) (*Confinement, error) {
+	return nil, ErrUnsupported
+}
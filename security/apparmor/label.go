@@ -0,0 +1,95 @@
+/*
+   Copyright Farcloser.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package apparmor
+
+import "strings"
+
+const (
+	hatSeparator       = "//"
+	stackSeparator     = "//&"
+	namespaceDelimiter = ":"
+)
+
+// Confinement is the AppArmor label of a task: one profile, or several stacked profiles.
+type Confinement struct {
+	// Profiles lists the stacked profiles, outermost first. There is usually only one.
+	Profiles []*Profile `json:"profiles"`
+	Mode     mode       `json:"mode,omitempty"`
+}
+
+// Unconfined tells whether the task is not confined at all.
+func (c *Confinement) Unconfined() bool {
+	return len(c.Profiles) == 1 && c.Profiles[0].Name == Unconfined && c.Profiles[0].Namespace == ""
+}
+
+// String returns the label in the format used by the kernel.
+func (c *Confinement) String() string {
+	parts := make([]string, 0, len(c.Profiles))
+	for _, profile := range c.Profiles {
+		part := profile.Name
+		if profile.Namespace != "" {
+			part = namespaceDelimiter + profile.Namespace + namespaceDelimiter + part
+		}
+
+		parts = append(parts, part)
+	}
+
+	label := strings.Join(parts, stackSeparator)
+	if c.Mode != "" && !c.Unconfined() {
+		label += " (" + c.Mode + ")"
+	}
+
+	return label
+}
+
+// ParseLabel parses a task label, as found in /proc/<pid>/attr/apparmor/current.
+// For example: `unconfined`, `docker-default (enforce)`, `:ns:profile//hat (complain)`, or `a//&:ns:b (mixed)`.
+func ParseLabel(label string) *Confinement {
+	label = strings.TrimSpace(strings.TrimRight(label, "\x00\n"))
+
+	confinement := &Confinement{}
+
+	if idx := strings.LastIndex(label, " ("); idx >= 0 && strings.HasSuffix(label, ")") {
+		confinement.Mode = label[idx+2 : len(label)-1]
+		label = label[:idx]
+	}
+
+	for _, part := range strings.Split(label, stackSeparator) {
+		profile := &Profile{}
+
+		if strings.HasPrefix(part, namespaceDelimiter) {
+			namespace, name, _ := strings.Cut(part[1:], namespaceDelimiter)
+			profile.Namespace = namespace
+			// `:ns://profile` is equivalent to `:ns:profile`
+			part = strings.TrimPrefix(name, hatSeparator)
+		}
+
+		profile.Name = part
+		if confinement.Mode != Mixed {
+			profile.Mode = confinement.Mode
+		}
+
+		confinement.Profiles = append(confinement.Profiles, profile)
+	}
+
+	if confinement.Unconfined() {
+		confinement.Mode = Unconfined
+		confinement.Profiles[0].Mode = Unconfined
+	}
+
+	return confinement
+}
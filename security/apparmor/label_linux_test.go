@@ -0,0 +1,53 @@
+/*
+   Copyright Farcloser.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package apparmor_test
+
+import (
+	"testing"
+
+	"gotest.tools/v3/assert"
+
+	"go.farcloser.world/containers/security/apparmor"
+)
+
+func TestProfileOf(t *testing.T) {
+	t.Parallel()
+
+	procFS := &apparmor.ProcFS{Root: "testdata/proc"}
+
+	// attr/apparmor/current is preferred over attr/current
+	confinement, err := procFS.ProfileOf(1)
+	assert.NilError(t, err)
+	assert.Equal(t, confinement.String(), "docker-default (enforce)")
+
+	// Fallback to attr/current
+	confinement, err = procFS.ProfileOf(2)
+	assert.NilError(t, err)
+	assert.Equal(t, confinement.String(), "/usr/sbin/nginx//reload (complain)")
+
+	confinement, err = procFS.ProfileOf(3)
+	assert.NilError(t, err)
+	assert.Equal(t, len(confinement.Profiles), 2)
+	assert.Equal(t, confinement.Mode, apparmor.Mixed)
+
+	confinement, err = procFS.ProfileOf(4)
+	assert.NilError(t, err)
+	assert.Assert(t, confinement.Unconfined())
+
+	_, err = procFS.ProfileOf(5)
+	assert.Assert(t, err != nil)
+}
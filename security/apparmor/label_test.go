@@ -0,0 +1,80 @@
+/*
+   Copyright Farcloser.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package apparmor_test
+
+import (
+	"testing"
+
+	"gotest.tools/v3/assert"
+
+	"go.farcloser.world/containers/security/apparmor"
+)
+
+func TestParseLabel(t *testing.T) {
+	t.Parallel()
+
+	needles := map[string]*apparmor.Confinement{
+		"unconfined\n": {
+			Profiles: []*apparmor.Profile{{Name: "unconfined", Mode: apparmor.Unconfined}},
+			Mode:     apparmor.Unconfined,
+		},
+		"docker-default (enforce)\n": {
+			Profiles: []*apparmor.Profile{{Name: "docker-default", Mode: apparmor.Enforce}},
+			Mode:     apparmor.Enforce,
+		},
+		"/usr/sbin/nginx//reload (complain)": {
+			Profiles: []*apparmor.Profile{{Name: "/usr/sbin/nginx//reload", Mode: apparmor.Complain}},
+			Mode:     apparmor.Complain,
+		},
+		":container:inner (enforce)": {
+			Profiles: []*apparmor.Profile{{Name: "inner", Namespace: "container", Mode: apparmor.Enforce}},
+			Mode:     apparmor.Enforce,
+		},
+		":container://inner (enforce)": {
+			Profiles: []*apparmor.Profile{{Name: "inner", Namespace: "container", Mode: apparmor.Enforce}},
+			Mode:     apparmor.Enforce,
+		},
+		"docker-default//&:container:inner (mixed)": {
+			Profiles: []*apparmor.Profile{
+				{Name: "docker-default"},
+				{Name: "inner", Namespace: "container"},
+			},
+			Mode: apparmor.Mixed,
+		},
+	}
+
+	for label, expected := range needles {
+		t.Run(label, func(t *testing.T) {
+			t.Parallel()
+
+			confinement := apparmor.ParseLabel(label)
+			assert.DeepEqual(t, confinement, expected)
+		})
+	}
+}
+
+func TestConfinementString(t *testing.T) {
+	t.Parallel()
+
+	for _, label := range []string{
+		"unconfined",
+		"docker-default (enforce)",
+		"docker-default//&:container:inner (mixed)",
+	} {
+		assert.Equal(t, apparmor.ParseLabel(label).String(), label)
+	}
+}
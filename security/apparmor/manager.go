@@ -0,0 +1,189 @@
+/*
+   Copyright Farcloser.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package apparmor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+var ErrProfileNotAcquired = errors.New("apparmor profile was not acquired")
+
+// Manager tracks the users of the profiles it loads, and only unloads a profile once its last user releases it.
+// Reference counts are held in memory: a single Manager should be shared by everything in a process that manages
+// a given set of profiles.
+type Manager struct {
+	mutex      sync.Mutex
+	loader     *Loader
+	securityFS *SecurityFS
+	references map[string]int
+	// loading holds the profiles being loaded by Acquire, with a channel closed once the load is over.
+	loading map[string]chan struct{}
+}
+
+// NewManager returns a Manager loading profiles with `loader` and unloading them through `securityFS`.
+// Either can be nil, in which case defaults are used.
+func NewManager(loader *Loader, securityFS *SecurityFS) *Manager {
+	if securityFS == nil {
+		securityFS = DefaultSecurityFS()
+	}
+
+	ldr := &Loader{}
+	if loader != nil {
+		*ldr = *loader
+	}
+
+	if ldr.ListProfiles == nil {
+		ldr.ListProfiles = securityFS.Profiles
+	}
+
+	return &Manager{
+		loader:     ldr,
+		securityFS: securityFS,
+		references: map[string]int{},
+		loading:    map[string]chan struct{}{},
+	}
+}
+
+// Acquire loads the profile `name` from `text` if it is not in use yet, and records a new user of it.
+// Profiles are loaded without holding up the other profiles of the Manager. Concurrent callers acquiring the same
+// profile wait for the ongoing load, and try again if it failed.
+func (m *Manager) Acquire(ctx context.Context, name string, text string) error {
+	for {
+		m.mutex.Lock()
+
+		if m.references[name] > 0 {
+			m.references[name]++
+			m.mutex.Unlock()
+
+			return nil
+		}
+
+		if done, ok := m.loading[name]; ok {
+			m.mutex.Unlock()
+
+			select {
+			case <-done:
+				continue
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+		done := make(chan struct{})
+		m.loading[name] = done
+		m.mutex.Unlock()
+
+		err := m.loader.LoadProfile(ctx, name, text)
+
+		m.mutex.Lock()
+		delete(m.loading, name)
+
+		if err == nil {
+			m.references[name]++
+		}
+
+		m.mutex.Unlock()
+		close(done)
+
+		return err
+	}
+}
+
+// Release records that a user of the profile `name` went away, and unloads the profile if it was the last one.
+// A profile that already disappeared from the kernel is not an error.
+func (m *Manager) Release(name string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.references[name] == 0 {
+		return fmt.Errorf("%w %q", ErrProfileNotAcquired, name)
+	}
+
+	m.references[name]--
+	if m.references[name] > 0 {
+		return nil
+	}
+
+	delete(m.references, name)
+	m.loader.forget(name)
+
+	if err := m.securityFS.UnloadProfile(name); err != nil && !errors.Is(err, ErrProfileNotLoaded) {
+		return err
+	}
+
+	return nil
+}
+
+// References returns the number of current users of the profile `name`.
+func (m *Manager) References(name string) int {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.references[name]
+}
+
+// inUse reports whether the profile `name` is acquired, or being loaded. The caller must hold the mutex.
+func (m *Manager) inUse(name string) bool {
+	_, loading := m.loading[name]
+
+	return m.references[name] > 0 || loading
+}
+
+// Sweep unloads every profile of the root namespace whose name starts with `prefix` and that is not in use by this Manager.
+// It is meant to garbage collect generated profiles left behind, for example, by a crashed process.
+// The names of the unloaded profiles are returned, along with any error encountered.
+func (m *Manager) Sweep(prefix string) ([]string, error) {
+	if prefix == "" {
+		return nil, fmt.Errorf("%w: refusing to sweep with an empty prefix", ErrInvalidProfileName)
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	profiles, err := m.securityFS.Profiles()
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		swept []string
+		errs  []error
+	)
+
+	for _, profile := range profiles {
+		if profile.Namespace != "" || !strings.HasPrefix(profile.Name, prefix) || m.inUse(profile.Name) {
+			continue
+		}
+
+		m.loader.forget(profile.Name)
+
+		err = m.securityFS.UnloadProfile(profile.Name)
+		if err != nil && !errors.Is(err, ErrProfileNotLoaded) {
+			errs = append(errs, err)
+
+			continue
+		}
+
+		swept = append(swept, profile.Name)
+	}
+
+	return swept, errors.Join(errs...)
+}
@@ -0,0 +1,210 @@
+/*
+   Copyright Farcloser.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package apparmor_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+
+	"go.farcloser.world/containers/security/apparmor"
+)
+
+// unloaded returns what was written to the fake .remove file since the last call.
+func unloaded(t *testing.T, securityFS *apparmor.SecurityFS) string {
+	t.Helper()
+
+	removed, err := os.ReadFile(filepath.Join(securityFS.Root, ".remove"))
+	assert.NilError(t, err)
+	assert.NilError(t, os.WriteFile(filepath.Join(securityFS.Root, ".remove"), nil, 0o600))
+
+	return string(removed)
+}
+
+func setupManager(t *testing.T) (*apparmor.Manager, *apparmor.SecurityFS, func() []string) {
+	t.Helper()
+
+	parser, calls := setupParser(t)
+	securityFS := &apparmor.SecurityFS{Root: filepath.Join(filepath.Dir(parser), "securityfs")}
+	manager := apparmor.NewManager(&apparmor.Loader{ParserPath: parser, CacheDir: t.TempDir()}, securityFS)
+
+	return manager, securityFS, calls
+}
+
+func TestUnloadProfile(t *testing.T) {
+	t.Parallel()
+
+	_, securityFS, _ := setupManager(t)
+
+	err := securityFS.UnloadProfile("missing")
+	assert.ErrorIs(t, err, apparmor.ErrProfileNotLoaded)
+	assert.Equal(t, unloaded(t, securityFS), "")
+
+	assert.NilError(t, os.MkdirAll(filepath.Join(securityFS.Root, "policy", "profiles", "present.1"), 0o755))
+	assert.NilError(t, os.WriteFile(
+		filepath.Join(securityFS.Root, "policy", "profiles", "present.1", "name"), []byte("present\n"), 0o600))
+
+	assert.NilError(t, securityFS.UnloadProfile("present"))
+	assert.Equal(t, unloaded(t, securityFS), "present")
+}
+
+func TestUnloadProfileNoSecurityFS(t *testing.T) {
+	t.Parallel()
+
+	securityFS := &apparmor.SecurityFS{Root: filepath.Join(t.TempDir(), "missing")}
+
+	err := securityFS.UnloadProfile("whatever")
+	assert.ErrorIs(t, err, apparmor.ErrCannotUnloadProfile)
+	assert.Assert(t, !errors.Is(err, apparmor.ErrProfileNotLoaded))
+}
+
+func TestUnloadProfilePermissionDenied(t *testing.T) {
+	t.Parallel()
+
+	if os.Geteuid() == 0 {
+		t.Skip("test requires a non-root user")
+	}
+
+	_, securityFS, _ := setupManager(t)
+	assert.NilError(t, os.Chmod(filepath.Join(securityFS.Root, ".remove"), 0o400))
+
+	err := securityFS.UnloadProfile("whatever")
+	assert.ErrorIs(t, err, apparmor.ErrProfileNotLoaded)
+
+	assert.NilError(t, os.MkdirAll(filepath.Join(securityFS.Root, "policy", "profiles", "whatever.1"), 0o755))
+	assert.NilError(t, os.WriteFile(
+		filepath.Join(securityFS.Root, "policy", "profiles", "whatever.1", "name"), []byte("whatever\n"), 0o600))
+
+	err = securityFS.UnloadProfile("whatever")
+	assert.ErrorIs(t, err, apparmor.ErrPermissionDenied)
+}
+
+func TestManagerReferenceCounting(t *testing.T) {
+	t.Parallel()
+
+	manager, securityFS, calls := setupManager(t)
+	ctx := context.Background()
+	text := "profile shared {\n}\n"
+
+	assert.NilError(t, manager.Acquire(ctx, "shared", text))
+	assert.NilError(t, manager.Acquire(ctx, "shared", text))
+	assert.Equal(t, manager.References("shared"), 2)
+	assert.Equal(t, len(calls()), 1)
+
+	assert.NilError(t, manager.Release("shared"))
+	assert.Equal(t, unloaded(t, securityFS), "")
+
+	assert.NilError(t, manager.Release("shared"))
+	assert.Equal(t, unloaded(t, securityFS), "shared")
+	assert.Equal(t, manager.References("shared"), 0)
+
+	assert.ErrorIs(t, manager.Release("shared"), apparmor.ErrProfileNotAcquired)
+}
+
+func TestManagerAcquireFailure(t *testing.T) {
+	t.Parallel()
+
+	manager, _, _ := setupManager(t)
+
+	err := manager.Acquire(context.Background(), "broken", "profile broken {\n  bogus\n}\n")
+	assert.ErrorIs(t, err, apparmor.ErrCannotLoadProfile)
+	assert.Equal(t, manager.References("broken"), 0)
+}
+
+func TestManagerAcquireConcurrent(t *testing.T) {
+	t.Parallel()
+
+	manager, securityFS, calls := setupManager(t)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	defer cancel()
+
+	hung := make(chan error, 1)
+
+	go func() {
+		hung <- manager.Acquire(ctx, "slow", "profile slow {\n  hang\n}\n")
+	}()
+
+	for deadline := time.Now().Add(5 * time.Second); len(calls()) == 0; time.Sleep(10 * time.Millisecond) {
+		assert.Assert(t, time.Now().Before(deadline), "the parser did not run")
+	}
+
+	// Other profiles are not held up by the parser run
+	assert.NilError(t, manager.Acquire(context.Background(), "other", "profile other {\n}\n"))
+	assert.Equal(t, manager.References("other"), 1)
+	assert.Equal(t, manager.References("slow"), 0)
+
+	// Nor is sweeping, which leaves the profile being replaced alone
+	assert.NilError(t, os.MkdirAll(filepath.Join(securityFS.Root, "policy", "profiles", "slow.1"), 0o755))
+	assert.NilError(t, os.WriteFile(
+		filepath.Join(securityFS.Root, "policy", "profiles", "slow.1", "name"), []byte("slow\n"), 0o600))
+
+	swept, err := manager.Sweep("slow")
+	assert.NilError(t, err)
+	assert.Equal(t, len(swept), 0)
+	assert.Equal(t, unloaded(t, securityFS), "")
+
+	cancel()
+	assert.ErrorIs(t, <-hung, apparmor.ErrCannotLoadProfile)
+	assert.Equal(t, manager.References("slow"), 0)
+
+	// Users of the same profile share a single load
+	errs := make(chan error, 8)
+	for range cap(errs) {
+		go func() {
+			errs <- manager.Acquire(context.Background(), "shared", "profile shared {\n}\n")
+		}()
+	}
+
+	for range cap(errs) {
+		assert.NilError(t, <-errs)
+	}
+
+	assert.Equal(t, manager.References("shared"), cap(errs))
+	assert.Equal(t, len(calls()), 3)
+}
+
+func TestManagerSweep(t *testing.T) {
+	t.Parallel()
+
+	manager, securityFS, _ := setupManager(t)
+	ctx := context.Background()
+
+	assert.NilError(t, manager.Acquire(ctx, "generated-in-use", "profile generated-in-use {\n}\n"))
+	assert.NilError(t, manager.Acquire(ctx, "other", "profile other {\n}\n"))
+
+	// Left behind by a previous process
+	assert.NilError(t, os.MkdirAll(filepath.Join(securityFS.Root, "policy", "profiles", "generated-stale.3"), 0o755))
+	assert.NilError(t, os.WriteFile(
+		filepath.Join(securityFS.Root, "policy", "profiles", "generated-stale.3", "name"),
+		[]byte("generated-stale\n"),
+		0o600,
+	))
+
+	swept, err := manager.Sweep("generated-")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, swept, []string{"generated-stale"})
+	assert.Equal(t, unloaded(t, securityFS), "generated-stale")
+
+	_, err = manager.Sweep("")
+	assert.ErrorIs(t, err, apparmor.ErrInvalidProfileName)
+}
@@ -0,0 +1,211 @@
+/*
+   Copyright Farcloser.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package apparmor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.farcloser.world/core/filesystem"
+)
+
+const (
+	parserBinary         = "apparmor_parser"
+	defaultParserTimeout = 30 * time.Second
+	parserWaitDelay      = time.Second
+	sourceSuffix         = ".profile"
+	cacheDirPermissions  = 0o700
+)
+
+// Example outputs:
+// AppArmor parser error for /tmp/foo in profile /tmp/foo at line 3: syntax error, unexpected TOK_ID
+// AppArmor parser error at line 12: Found unexpected character: '%'
+//
+//nolint:gochecknoglobals
+var parserErrorRegexp = regexp.MustCompile(
+	`AppArmor parser error(?: for (\S+))?(?: in profile (\S+))? at line (\d+): (.*)`,
+)
+
+// LoadProfile compiles and loads (or replaces) the profile `name` from `text`.
+// If a profile with the same name and the same source is already loaded, this is a no-op.
+// Errors reported by the parser are returned as *ParseError, joined with ErrCannotLoadProfile.
+func (l *Loader) LoadProfile(ctx context.Context, name string, text string) error {
+	if name == "" || strings.ContainsAny(name, " \t\n") {
+		return fmt.Errorf("%w %q", ErrInvalidProfileName, name)
+	}
+
+	if l.isLoaded(name, text) {
+		return nil
+	}
+
+	parser := l.ParserPath
+	if parser == "" {
+		var err error
+		if parser, err = exec.LookPath(parserBinary); err != nil {
+			return errors.Join(fmt.Errorf("%w %q", ErrCannotLoadProfile, name), err)
+		}
+	}
+
+	source, err := l.writeSource(name, text)
+	if err != nil {
+		return errors.Join(fmt.Errorf("%w %q", ErrCannotLoadProfile, name), err)
+	}
+
+	if err = l.run(ctx, parser, source); err != nil {
+		// Do not leave behind a source that would be mistaken for a loaded profile.
+		_ = os.Remove(source)
+
+		return errors.Join(fmt.Errorf("%w %q", ErrCannotLoadProfile, name), err)
+	}
+
+	if l.CacheDir == "" {
+		_ = os.Remove(source)
+	}
+
+	return nil
+}
+
+// writeSource stores the profile text where apparmor_parser can read it.
+// With a cache directory, the file name is stable, as apparmor_parser names its cache entries after it.
+func (l *Loader) writeSource(name string, text string) (string, error) {
+	if l.CacheDir == "" {
+		source, err := os.CreateTemp("", "apparmor-")
+		if err != nil {
+			return "", err
+		}
+
+		_, err = source.WriteString(text)
+		if err = errors.Join(err, source.Close()); err != nil {
+			_ = os.Remove(source.Name())
+
+			return "", err
+		}
+
+		return source.Name(), nil
+	}
+
+	if err := os.MkdirAll(l.CacheDir, cacheDirPermissions); err != nil {
+		return "", err
+	}
+
+	return l.sourcePath(name), os.WriteFile(l.sourcePath(name), []byte(text), filesystem.FilePermissionsDefault)
+}
+
+func (l *Loader) run(ctx context.Context, parser string, source string) error {
+	timeout := l.Timeout
+	if timeout <= 0 {
+		timeout = defaultParserTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	args := []string{"--replace"}
+	if l.CacheDir != "" {
+		args = append(args, "--cache-loc", l.CacheDir, "--write-cache")
+	} else {
+		args = append(args, "--skip-cache")
+	}
+
+	args = append(args, source)
+
+	var output bytes.Buffer
+
+	cmd := exec.CommandContext(ctx, parser, args...)
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	cmd.WaitDelay = parserWaitDelay
+
+	err := cmd.Run()
+	if err == nil {
+		return nil
+	}
+
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return errors.Join(ErrParserTimeout, err)
+	}
+
+	errs := []error{fmt.Errorf("%w: %s", ErrParserFailed, strings.TrimSpace(output.String()))}
+	for _, parseErr := range parseParserOutput(output.String()) {
+		errs = append(errs, parseErr)
+	}
+
+	return errors.Join(append(errs, err)...)
+}
+
+func (l *Loader) isLoaded(name string, text string) bool {
+	if l.CacheDir == "" {
+		return false
+	}
+
+	previous, err := os.ReadFile(l.sourcePath(name))
+	if err != nil || !bytes.Equal(previous, []byte(text)) {
+		return false
+	}
+
+	list := l.ListProfiles
+	if list == nil {
+		list = Profiles
+	}
+
+	profiles, err := list()
+	if err != nil {
+		return false
+	}
+
+	return containsProfile(profiles, name)
+}
+
+// forget drops the recorded source of `name`, if any.
+func (l *Loader) forget(name string) {
+	if l.CacheDir != "" {
+		_ = os.Remove(l.sourcePath(name))
+	}
+}
+
+func (l *Loader) sourcePath(name string) string {
+	return filepath.Join(l.CacheDir, url.PathEscape(name)+sourceSuffix)
+}
+
+func parseParserOutput(output string) []*ParseError {
+	var res []*ParseError
+
+	for _, match := range parserErrorRegexp.FindAllStringSubmatch(output, -1) {
+		line, err := strconv.Atoi(match[3])
+		if err != nil {
+			continue
+		}
+
+		res = append(res, &ParseError{
+			File:    match[1],
+			Line:    line,
+			Message: strings.TrimSpace(match[4]),
+		})
+	}
+
+	return res
+}
@@ -0,0 +1,142 @@
+/*
+   Copyright Farcloser.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package apparmor_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+
+	"go.farcloser.world/containers/security/apparmor"
+)
+
+// fakeParser mimics apparmor_parser: it records its invocations, fails with a parse error on profiles containing
+// "bogus", and hangs on profiles containing "hang".
+// Successfully parsed profiles are registered in the fake securityfs next to it.
+const fakeParser = `#!/bin/sh
+for last; do :; done
+dir="$(dirname "$0")"
+echo "$@" >> "$dir/calls"
+if grep -q bogus "$last"; then
+  echo "AppArmor parser error for $last in profile $last at line 2: syntax error, unexpected TOK_ID" >&2
+  exit 1
+fi
+if grep -q hang "$last"; then
+  exec sleep 10
+fi
+name="$(sed -n 's/^profile \([^ ]*\).*/\1/p' "$last")"
+mkdir -p "$dir/securityfs/policy/profiles/$name.1"
+echo "$name" > "$dir/securityfs/policy/profiles/$name.1/name"
+echo "enforce" > "$dir/securityfs/policy/profiles/$name.1/mode"
+exit 0
+`
+
+func setupParser(t *testing.T) (string, func() []string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	parser := filepath.Join(dir, "apparmor_parser")
+	assert.NilError(t, os.WriteFile(parser, []byte(fakeParser), 0o700))
+	assert.NilError(t, os.MkdirAll(filepath.Join(dir, "securityfs", "policy", "profiles"), 0o755))
+	assert.NilError(t, os.WriteFile(filepath.Join(dir, "securityfs", ".remove"), nil, 0o600))
+
+	return parser, func() []string {
+		calls, err := os.ReadFile(filepath.Join(dir, "calls"))
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+
+		assert.NilError(t, err)
+
+		return strings.Split(strings.TrimSpace(string(calls)), "\n")
+	}
+}
+
+func TestLoadProfile(t *testing.T) {
+	t.Parallel()
+
+	parser, calls := setupParser(t)
+	cache := t.TempDir()
+	loaded := []*apparmor.Profile{}
+
+	loader := &apparmor.Loader{
+		ParserPath: parser,
+		CacheDir:   cache,
+		ListProfiles: func() ([]*apparmor.Profile, error) {
+			return loaded, nil
+		},
+	}
+
+	text := "profile test-profile flags=(attach_disconnected) {\n}\n"
+
+	assert.NilError(t, loader.LoadProfile(context.Background(), "test-profile", text))
+	assert.Equal(t, len(calls()), 1)
+	assert.Assert(t, strings.Contains(calls()[0], "--cache-loc "+cache))
+
+	// Not reported as loaded by the kernel: must be loaded again
+	assert.NilError(t, loader.LoadProfile(context.Background(), "test-profile", text))
+	assert.Equal(t, len(calls()), 2)
+
+	// Loaded and identical: skipped
+	loaded = append(loaded, &apparmor.Profile{Name: "test-profile", Mode: apparmor.Enforce})
+	assert.NilError(t, loader.LoadProfile(context.Background(), "test-profile", text))
+	assert.Equal(t, len(calls()), 2)
+
+	// Loaded but different: replaced
+	assert.NilError(t, loader.LoadProfile(context.Background(), "test-profile", text+"\n"))
+	assert.Equal(t, len(calls()), 3)
+}
+
+func TestLoadProfileParseError(t *testing.T) {
+	t.Parallel()
+
+	parser, _ := setupParser(t)
+	loader := &apparmor.Loader{ParserPath: parser}
+
+	err := loader.LoadProfile(context.Background(), "test-profile", "profile test-profile {\n  bogus\n}\n")
+	assert.ErrorIs(t, err, apparmor.ErrCannotLoadProfile)
+	assert.ErrorIs(t, err, apparmor.ErrParserFailed)
+
+	var parseErr *apparmor.ParseError
+
+	assert.Assert(t, errors.As(err, &parseErr))
+	assert.Equal(t, parseErr.Line, 2)
+	assert.Equal(t, parseErr.Message, "syntax error, unexpected TOK_ID")
+}
+
+func TestLoadProfileTimeout(t *testing.T) {
+	t.Parallel()
+
+	parser, _ := setupParser(t)
+	loader := &apparmor.Loader{ParserPath: parser, Timeout: 100 * time.Millisecond}
+
+	err := loader.LoadProfile(context.Background(), "test-profile", "profile test-profile {\n  hang\n}\n")
+	assert.ErrorIs(t, err, apparmor.ErrParserTimeout)
+}
+
+func TestLoadProfileInvalidName(t *testing.T) {
+	t.Parallel()
+
+	err := apparmor.LoadProfile("invalid name", "")
+	assert.ErrorIs(t, err, apparmor.ErrInvalidProfileName)
+}
@@ -0,0 +1,42 @@
+/*
+   Copyright Farcloser.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package apparmor
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+)
+
+// ProfileOf returns the confinement of the process `pid`.
+// It reads attr/apparmor/current, and falls back to attr/current on kernels without LSM stacking support.
+func (pfs *ProcFS) ProfileOf(pid int) (*Confinement, error) {
+	attr := filepath.Join(pfs.Root, strconv.Itoa(pid), "attr")
+
+	label, err := os.ReadFile(filepath.Join(attr, "apparmor", "current"))
+	if err != nil && (errors.Is(err, syscall.ENOENT) || errors.Is(err, syscall.ENOTDIR)) {
+		label, err = os.ReadFile(filepath.Join(attr, "current"))
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseLabel(string(label)), nil
+}
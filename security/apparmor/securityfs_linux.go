@@ -0,0 +1,159 @@
+/*
+   Copyright Farcloser.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package apparmor
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+const (
+	removeFile    = ".remove"
+	policyDir     = "policy"
+	profilesDir   = "profiles"
+	namespacesDir = "namespaces"
+	nameFile      = "name"
+	modeFile      = "mode"
+	attachFile    = "attach"
+)
+
+// Profiles return the list of currently loaded profiles, in all namespaces.
+func (sfs *SecurityFS) Profiles() ([]*Profile, error) {
+	return readPolicy(filepath.Join(sfs.Root, policyDir), "")
+}
+
+// readPolicy reads the profiles of a namespace, and recursively of its child namespaces.
+func readPolicy(dir string, namespace string) ([]*Profile, error) {
+	res, err := readProfiles(filepath.Join(dir, profilesDir), namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	// Namespaces may be missing, or not be visible to us
+	entries, _ := os.ReadDir(filepath.Join(dir, namespacesDir))
+	for _, entry := range entries {
+		child := entry.Name()
+		if namespace != "" {
+			child = namespace + hatSeparator + child
+		}
+
+		profiles, err := readPolicy(filepath.Join(dir, namespacesDir, entry.Name()), child)
+		if err == nil {
+			res = append(res, profiles...)
+		}
+	}
+
+	return res, nil
+}
+
+func readProfiles(dir string, namespace string) ([]*Profile, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	res := []*Profile{}
+
+	for _, entry := range entries {
+		if profile, err := readProfile(filepath.Join(dir, entry.Name()), namespace); err == nil {
+			res = append(res, profile)
+		}
+	}
+
+	return res, nil
+}
+
+func readProfile(dir string, namespace string) (*Profile, error) {
+	bytes, err := os.ReadFile(filepath.Join(dir, nameFile))
+	if err != nil {
+		return nil, err
+	}
+
+	profile := &Profile{
+		Name:      strings.TrimSpace(string(bytes)),
+		Namespace: namespace,
+	}
+
+	if bytes, err = os.ReadFile(filepath.Join(dir, modeFile)); err == nil {
+		profile.Mode = strings.TrimSpace(string(bytes))
+	}
+
+	if bytes, err = os.ReadFile(filepath.Join(dir, attachFile)); err == nil {
+		profile.Attach = strings.TrimSpace(string(bytes))
+	}
+
+	// Hats (and other child profiles) are nested in their parent
+	if hats, err := readProfiles(filepath.Join(dir, profilesDir), namespace); err == nil && len(hats) > 0 {
+		profile.Hats = hats
+	}
+
+	return profile, nil
+}
+
+// UnloadProfile removes the profile `name` from the kernel.
+// It returns ErrProfileNotLoaded if there is no such profile, and ErrPermissionDenied if the caller is not allowed
+// to manage policy.
+func (sfs *SecurityFS) UnloadProfile(name string) error {
+	// Listing is advisory - the kernel remains the authority and will return ENOENT if the profile vanished since.
+	if profiles, err := sfs.Profiles(); err == nil && !containsProfile(profiles, name) {
+		return fmt.Errorf("%w %q", ErrProfileNotLoaded, name)
+	}
+
+	// Note: the kernel expects the name in a single write, at offset 0. Do not truncate or append.
+	remover, err := os.OpenFile(filepath.Join(sfs.Root, removeFile), os.O_WRONLY, 0)
+	if err != nil {
+		// ENOENT here means there is no securityfs, not that the profile is missing.
+		if errors.Is(err, syscall.ENOENT) {
+			return errors.Join(fmt.Errorf("%w %q", ErrCannotUnloadProfile, name), err)
+		}
+
+		return classifyUnloadError(name, err)
+	}
+
+	_, err = remover.WriteString(name)
+	if err = errors.Join(err, remover.Close()); err != nil {
+		return classifyUnloadError(name, err)
+	}
+
+	return nil
+}
+
+func classifyUnloadError(name string, err error) error {
+	switch {
+	case errors.Is(err, syscall.ENOENT):
+		return errors.Join(fmt.Errorf("%w %q", ErrProfileNotLoaded, name), err)
+	case errors.Is(err, syscall.EACCES), errors.Is(err, syscall.EPERM):
+		return errors.Join(fmt.Errorf("%w %q", ErrCannotUnloadProfile, name), ErrPermissionDenied, err)
+	default:
+		return errors.Join(fmt.Errorf("%w %q", ErrCannotUnloadProfile, name), err)
+	}
+}
+
+// containsProfile tells whether `name` is loaded in the root namespace.
+func containsProfile(profiles []*Profile, name string) bool {
+	for _, profile := range profiles {
+		if profile.Name == name && profile.Namespace == "" {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,71 @@
+/*
+   Copyright Farcloser.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package apparmor_test
+
+import (
+	"testing"
+
+	"gotest.tools/v3/assert"
+
+	"go.farcloser.world/containers/security/apparmor"
+)
+
+func TestProfiles(t *testing.T) {
+	t.Parallel()
+
+	profiles, err := (&apparmor.SecurityFS{Root: "testdata/securityfs"}).Profiles()
+	assert.NilError(t, err)
+
+	assert.DeepEqual(t, profiles, []*apparmor.Profile{
+		{
+			Name:   "docker-default",
+			Mode:   apparmor.Enforce,
+			Attach: "docker-default",
+		},
+		{
+			Name:   "/usr/sbin/nginx",
+			Mode:   apparmor.Complain,
+			Attach: "/usr/sbin/nginx",
+			Hats: []*apparmor.Profile{
+				{
+					Name:   "/usr/sbin/nginx//reload",
+					Mode:   apparmor.Complain,
+					Attach: "/usr/sbin/nginx//reload",
+				},
+			},
+		},
+		{
+			Name:      "inner",
+			Mode:      apparmor.Enforce,
+			Namespace: "container",
+			Attach:    "/usr/bin/inner",
+		},
+		{
+			Name:      "deep",
+			Mode:      apparmor.Kill,
+			Namespace: "container//nested",
+			Attach:    "deep",
+		},
+	})
+}
+
+func TestProfilesNoSecurityFS(t *testing.T) {
+	t.Parallel()
+
+	_, err := (&apparmor.SecurityFS{Root: "testdata/missing"}).Profiles()
+	assert.Assert(t, err != nil)
+}
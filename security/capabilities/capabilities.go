@@ -0,0 +1,245 @@
+/*
+   Copyright Farcloser.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package capabilities
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"go.farcloser.world/containers/specs"
+)
+
+// All is the special value standing for every capability, as accepted by --cap-add and --cap-drop.
+const All = "ALL"
+
+const prefix = "CAP_"
+
+var (
+	// ErrUnsupported is returned by functions probing the host on platforms without capabilities.
+	ErrUnsupported = errors.New("capabilities are not supported on this platform")
+
+	ErrUnknownCapability = errors.New("unknown capability")
+)
+
+// names lists every capability known to Linux, indexed by their number.
+//
+//nolint:gochecknoglobals
+var names = []string{
+	"CAP_CHOWN",
+	"CAP_DAC_OVERRIDE",
+	"CAP_DAC_READ_SEARCH",
+	"CAP_FOWNER",
+	"CAP_FSETID",
+	"CAP_KILL",
+	"CAP_SETGID",
+	"CAP_SETUID",
+	"CAP_SETPCAP",
+	"CAP_LINUX_IMMUTABLE",
+	"CAP_NET_BIND_SERVICE",
+	"CAP_NET_BROADCAST",
+	"CAP_NET_ADMIN",
+	"CAP_NET_RAW",
+	"CAP_IPC_LOCK",
+	"CAP_IPC_OWNER",
+	"CAP_SYS_MODULE",
+	"CAP_SYS_RAWIO",
+	"CAP_SYS_CHROOT",
+	"CAP_SYS_PTRACE",
+	"CAP_SYS_PACCT",
+	"CAP_SYS_ADMIN",
+	"CAP_SYS_BOOT",
+	"CAP_SYS_NICE",
+	"CAP_SYS_RESOURCE",
+	"CAP_SYS_TIME",
+	"CAP_SYS_TTY_CONFIG",
+	"CAP_MKNOD",
+	"CAP_LEASE",
+	"CAP_AUDIT_WRITE",
+	"CAP_AUDIT_CONTROL",
+	"CAP_SETFCAP",
+	"CAP_MAC_OVERRIDE",
+	"CAP_MAC_ADMIN",
+	"CAP_SYSLOG",
+	"CAP_WAKE_ALARM",
+	"CAP_BLOCK_SUSPEND",
+	"CAP_AUDIT_READ",
+	"CAP_PERFMON",
+	"CAP_BPF",
+	"CAP_CHECKPOINT_RESTORE",
+}
+
+// defaults is the set of capabilities granted to containers by default, same as docker and containerd.
+//
+//nolint:gochecknoglobals
+var defaults = []string{
+	"CAP_CHOWN",
+	"CAP_DAC_OVERRIDE",
+	"CAP_FSETID",
+	"CAP_FOWNER",
+	"CAP_MKNOD",
+	"CAP_NET_RAW",
+	"CAP_SETGID",
+	"CAP_SETUID",
+	"CAP_SETFCAP",
+	"CAP_SETPCAP",
+	"CAP_NET_BIND_SERVICE",
+	"CAP_SYS_CHROOT",
+	"CAP_KILL",
+	"CAP_AUDIT_WRITE",
+}
+
+// Known returns the names of all capabilities known to Linux, ordered by number.
+func Known() []string {
+	return slices.Clone(names)
+}
+
+// Default returns the capabilities granted to containers by default.
+func Default() []string {
+	return slices.Clone(defaults)
+}
+
+// Normalize returns the canonical form of a capability name: "net_admin", "NET_ADMIN" and "cap_net_admin" all
+// become "CAP_NET_ADMIN". "all" becomes All.
+func Normalize(name string) (string, error) {
+	name = strings.ToUpper(strings.TrimSpace(name))
+	if name == All {
+		return All, nil
+	}
+
+	if !strings.HasPrefix(name, prefix) {
+		name = prefix + name
+	}
+
+	if !slices.Contains(names, name) {
+		return "", fmt.Errorf("%w %q", ErrUnknownCapability, name)
+	}
+
+	return name, nil
+}
+
+// NormalizeList normalizes every capability of the list, and removes duplicates.
+func NormalizeList(list []string) ([]string, error) {
+	res := make([]string, 0, len(list))
+
+	for _, name := range list {
+		normalized, err := Normalize(name)
+		if err != nil {
+			return nil, err
+		}
+
+		if !slices.Contains(res, normalized) {
+			res = append(res, normalized)
+		}
+	}
+
+	return res, nil
+}
+
+// Effective computes the capabilities of a container from the base set (usually Default) and the --cap-add and
+// --cap-drop lists, following docker semantics:
+// - if add contains All, every known capability but the dropped ones is granted
+// - if drop contains All, only the added capabilities are granted
+// - otherwise, the dropped capabilities are removed from the base set, then the added ones are granted.
+//
+// Note that All expands to every capability known to this package, some of which may not be supported by an older
+// kernel. See Supported.
+func Effective(base []string, add []string, drop []string) ([]string, error) {
+	base, err := NormalizeList(base)
+	if err != nil {
+		return nil, err
+	}
+
+	add, err = NormalizeList(add)
+	if err != nil {
+		return nil, err
+	}
+
+	drop, err = NormalizeList(drop)
+	if err != nil {
+		return nil, err
+	}
+
+	var res []string
+
+	switch {
+	case slices.Contains(add, All):
+		for _, name := range names {
+			if !slices.Contains(drop, name) {
+				res = append(res, name)
+			}
+		}
+
+		return res, nil
+	case slices.Contains(drop, All):
+		res = []string{}
+	default:
+		for _, name := range base {
+			if name != All && !slices.Contains(drop, name) {
+				res = append(res, name)
+			}
+		}
+	}
+
+	for _, name := range add {
+		if !slices.Contains(res, name) {
+			res = append(res, name)
+		}
+	}
+
+	return res, nil
+}
+
+// ToSpec returns the five capability sets of a process running as `uid`, granted `caps`.
+//
+// Root gets `caps` as its bounding, permitted and effective sets.
+// Other users only get `caps` as their bounding set, as a regular non-root process would: their capabilities are
+// otherwise lost on execve. The capabilities in `ambient` (which must also be in `caps`) are however preserved
+// across execve for non-root users, by raising them in the ambient set, and therefore in the inheritable, permitted
+// and effective sets.
+//
+// The inheritable set of root is always left empty (see CVE-2022-24769).
+func ToSpec(caps []string, uid uint32, ambient []string) *specs.LinuxCapabilities {
+	res := &specs.LinuxCapabilities{
+		Bounding: slices.Clone(caps),
+	}
+
+	if uid == 0 {
+		res.Permitted = slices.Clone(caps)
+		res.Effective = slices.Clone(caps)
+
+		return res
+	}
+
+	var raised []string
+
+	for _, name := range ambient {
+		if slices.Contains(caps, name) && !slices.Contains(raised, name) {
+			raised = append(raised, name)
+		}
+	}
+
+	if len(raised) > 0 {
+		res.Ambient = raised
+		res.Inheritable = slices.Clone(raised)
+		res.Permitted = slices.Clone(raised)
+		res.Effective = slices.Clone(raised)
+	}
+
+	return res
+}
@@ -0,0 +1,103 @@
+/*
+   Copyright Farcloser.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package capabilities
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	lastCapPath    = "/proc/sys/kernel/cap_last_cap"
+	procStatusPath = "/proc/self/status"
+	boundingField  = "CapBnd:"
+	maskBits       = 64
+)
+
+var ErrCannotReadCapabilities = errors.New("cannot read host capabilities")
+
+// LastCap returns the number of the last capability supported by the running kernel.
+func LastCap() (int, error) {
+	content, err := os.ReadFile(lastCapPath)
+	if err != nil {
+		return 0, errors.Join(ErrCannotReadCapabilities, err)
+	}
+
+	last, err := strconv.Atoi(strings.TrimSpace(string(content)))
+	if err != nil {
+		return 0, errors.Join(ErrCannotReadCapabilities, err)
+	}
+
+	return last, nil
+}
+
+// Supported returns the known capabilities that the running kernel supports.
+func Supported() ([]string, error) {
+	last, err := LastCap()
+	if err != nil {
+		return nil, err
+	}
+
+	if last >= len(names) {
+		last = len(names) - 1
+	}
+
+	return Known()[:last+1], nil
+}
+
+// BoundingSet returns the bounding set of the current process, which caps what any child, including containers,
+// can ever be granted.
+func BoundingSet() ([]string, error) {
+	file, err := os.Open(procStatusPath)
+	if err != nil {
+		return nil, errors.Join(ErrCannotReadCapabilities, err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		value, found := strings.CutPrefix(scanner.Text(), boundingField)
+		if !found {
+			continue
+		}
+
+		mask, err := strconv.ParseUint(strings.TrimSpace(value), 16, maskBits)
+		if err != nil {
+			return nil, errors.Join(ErrCannotReadCapabilities, err)
+		}
+
+		return fromMask(mask), nil
+	}
+
+	return nil, fmt.Errorf("%w: no %s in %s", ErrCannotReadCapabilities, boundingField, procStatusPath)
+}
+
+func fromMask(mask uint64) []string {
+	res := []string{}
+
+	for index, name := range names {
+		if mask&(1<<uint(index)) != 0 {
+			res = append(res, name)
+		}
+	}
+
+	return res
+}
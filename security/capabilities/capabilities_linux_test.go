@@ -0,0 +1,51 @@
+/*
+   Copyright Farcloser.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package capabilities_test
+
+import (
+	"slices"
+	"testing"
+
+	"gotest.tools/v3/assert"
+
+	"go.farcloser.world/containers/security/capabilities"
+)
+
+func TestSupported(t *testing.T) {
+	t.Parallel()
+
+	last, err := capabilities.LastCap()
+	assert.NilError(t, err)
+	// CAP_AUDIT_READ was the last capability in kernel 3.16
+	assert.Assert(t, last >= 37)
+
+	supported, err := capabilities.Supported()
+	assert.NilError(t, err)
+	assert.Assert(t, slices.Contains(supported, "CAP_AUDIT_READ"))
+}
+
+func TestBoundingSet(t *testing.T) {
+	t.Parallel()
+
+	bounding, err := capabilities.BoundingSet()
+	assert.NilError(t, err)
+
+	for _, name := range bounding {
+		_, err = capabilities.Normalize(name)
+		assert.NilError(t, err)
+	}
+}
@@ -0,0 +1,101 @@
+/*
+   Copyright Farcloser.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package capabilities_test
+
+import (
+	"slices"
+	"testing"
+
+	"gotest.tools/v3/assert"
+
+	"go.farcloser.world/containers/security/capabilities"
+	"go.farcloser.world/containers/specs"
+)
+
+func TestNormalize(t *testing.T) {
+	t.Parallel()
+
+	for input, expected := range map[string]string{
+		"NET_ADMIN":     "CAP_NET_ADMIN",
+		"net_admin":     "CAP_NET_ADMIN",
+		"cap_net_admin": "CAP_NET_ADMIN",
+		"CAP_NET_ADMIN": "CAP_NET_ADMIN",
+		" sys_admin ":   "CAP_SYS_ADMIN",
+		"all":           capabilities.All,
+		"ALL":           capabilities.All,
+	} {
+		normalized, err := capabilities.Normalize(input)
+		assert.NilError(t, err)
+		assert.Equal(t, normalized, expected)
+	}
+
+	_, err := capabilities.Normalize("CAP_NOPE")
+	assert.ErrorIs(t, err, capabilities.ErrUnknownCapability)
+}
+
+func TestEffective(t *testing.T) {
+	t.Parallel()
+
+	base := []string{"CAP_CHOWN", "CAP_KILL", "CAP_NET_RAW"}
+
+	caps, err := capabilities.Effective(base, []string{"net_admin"}, []string{"NET_RAW"})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, caps, []string{"CAP_CHOWN", "CAP_KILL", "CAP_NET_ADMIN"})
+
+	caps, err = capabilities.Effective(base, []string{"kill"}, []string{"ALL"})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, caps, []string{"CAP_KILL"})
+
+	caps, err = capabilities.Effective(base, []string{"all"}, []string{"sys_admin"})
+	assert.NilError(t, err)
+	assert.Equal(t, len(caps), len(capabilities.Known())-1)
+	assert.Assert(t, !slices.Contains(caps, "CAP_SYS_ADMIN"))
+
+	caps, err = capabilities.Effective(capabilities.Default(), nil, nil)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, caps, capabilities.Default())
+
+	_, err = capabilities.Effective(base, []string{"bogus"}, nil)
+	assert.ErrorIs(t, err, capabilities.ErrUnknownCapability)
+}
+
+func TestToSpec(t *testing.T) {
+	t.Parallel()
+
+	caps := []string{"CAP_CHOWN", "CAP_NET_BIND_SERVICE"}
+
+	assert.DeepEqual(t, capabilities.ToSpec(caps, 0, nil), &specs.LinuxCapabilities{
+		Bounding:  caps,
+		Permitted: caps,
+		Effective: caps,
+	})
+
+	assert.DeepEqual(t, capabilities.ToSpec(caps, 1000, nil), &specs.LinuxCapabilities{
+		Bounding: caps,
+	})
+
+	ambient := []string{"CAP_NET_BIND_SERVICE"}
+
+	assert.DeepEqual(t, capabilities.ToSpec(caps, 1000, []string{"CAP_NET_BIND_SERVICE", "CAP_SYS_ADMIN"}),
+		&specs.LinuxCapabilities{
+			Bounding:    caps,
+			Ambient:     ambient,
+			Inheritable: ambient,
+			Permitted:   ambient,
+			Effective:   ambient,
+		})
+}
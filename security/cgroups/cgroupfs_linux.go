@@ -0,0 +1,384 @@
+/*
+   Copyright Farcloser.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package cgroups
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/unix"
+
+	"go.farcloser.world/containers/specs"
+)
+
+const (
+	cgroupSubtreeControlFile = "cgroup.subtree_control"
+	cgroupProcsFile          = "cgroup.procs"
+	cgroupFreezeFile         = "cgroup.freeze"
+	cgroupEventsFile         = "cgroup.events"
+	cgroupKillFile           = "cgroup.kill"
+
+	frozenEvent = "frozen"
+
+	groupDirPerm = 0o755
+
+	freezePollInterval = 10 * time.Millisecond
+)
+
+// Cgroupfs manages groups directly through the cgroup v2 filesystem.
+type Cgroupfs struct {
+	// Root is where the host filesystem is mounted, usually "/".
+	Root string
+	// Parent is the group containing the managed groups, relative to the cgroup mountpoint (eg: /containers).
+	Parent string
+}
+
+// NewCgroupfs returns a cgroupfs GroupManager for groups under `parent`, on the host filesystem mounted at `root`.
+func NewCgroupfs(root string, parent string) (*Cgroupfs, error) {
+	if VersionAt(root) != Version2 {
+		return nil, ErrUnsupported
+	}
+
+	return &Cgroupfs{Root: root, Parent: parent}, nil
+}
+
+func (cfs *Cgroupfs) Path(name string) string {
+	return filepath.Join("/", cfs.Parent, name)
+}
+
+func (cfs *Cgroupfs) Create(_ context.Context, name string, pid int, resources *specs.LinuxResources) error {
+	group, err := cfs.dir(name)
+	if err != nil {
+		return err
+	}
+
+	if err = enableControllers(filepath.Join(cfs.Root, cgroupRoot), filepath.Dir(group)); err != nil {
+		return errors.Join(ErrCannotCreateGroup, err)
+	}
+
+	if err = os.MkdirAll(group, groupDirPerm); err != nil {
+		return errors.Join(ErrCannotCreateGroup, err)
+	}
+
+	if pid != 0 {
+		if err = writeFile(group, cgroupProcsFile, strconv.Itoa(pid)); err != nil {
+			return errors.Join(ErrCannotCreateGroup, err)
+		}
+	}
+
+	if err = writeResources(group, resources); err != nil {
+		return errors.Join(ErrCannotCreateGroup, err)
+	}
+
+	return nil
+}
+
+func (cfs *Cgroupfs) Update(_ context.Context, name string, resources *specs.LinuxResources) error {
+	group, err := cfs.dir(name)
+	if err != nil {
+		return err
+	}
+
+	if err = writeResources(group, resources); err != nil {
+		return errors.Join(ErrCannotUpdateGroup, err)
+	}
+
+	return nil
+}
+
+func (cfs *Cgroupfs) AddProcess(_ context.Context, name string, pid int) error {
+	group, err := cfs.dir(name)
+	if err != nil {
+		return err
+	}
+
+	return writeFile(group, cgroupProcsFile, strconv.Itoa(pid))
+}
+
+func (cfs *Cgroupfs) Freeze(ctx context.Context, name string) error {
+	group, err := cfs.dir(name)
+	if err != nil {
+		return err
+	}
+
+	if err = freeze(ctx, group, true); err != nil {
+		return errors.Join(ErrCannotFreezeGroup, err)
+	}
+
+	return nil
+}
+
+func (cfs *Cgroupfs) Thaw(ctx context.Context, name string) error {
+	group, err := cfs.dir(name)
+	if err != nil {
+		return err
+	}
+
+	if err = freeze(ctx, group, false); err != nil {
+		return errors.Join(ErrCannotThawGroup, err)
+	}
+
+	return nil
+}
+
+func (cfs *Cgroupfs) Kill(ctx context.Context, name string) error {
+	group, err := cfs.dir(name)
+	if err != nil {
+		return err
+	}
+
+	if err = kill(ctx, group); err != nil {
+		return errors.Join(ErrCannotKillGroup, err)
+	}
+
+	return nil
+}
+
+func (cfs *Cgroupfs) Delete(_ context.Context, name string) error {
+	group, err := cfs.dir(name)
+	if err != nil {
+		return err
+	}
+
+	return removeGroup(group)
+}
+
+// Close is a no-op, as cgroupfs holds no resources.
+func (*Cgroupfs) Close() error {
+	return nil
+}
+
+// dir returns the directory of the group `name`, after making sure it designates a child of the parent group.
+func (cfs *Cgroupfs) dir(name string) (string, error) {
+	if err := validateGroupName(name); err != nil {
+		return "", err
+	}
+
+	return filepath.Join(cfs.Root, cgroupRoot, cfs.Path(name)), nil
+}
+
+// validateGroupName rejects names designating their parent group, or escaping it.
+func validateGroupName(name string) error {
+	if !filepath.IsLocal(name) || filepath.Clean(name) == "." {
+		return fmt.Errorf("%w: %q", ErrInvalidGroupName, name)
+	}
+
+	return nil
+}
+
+// enableControllers enables, in each group from `mountpoint` down to `parent`, all the controllers available to it,
+// so that they are available to the children of `parent`.
+// Controllers that cannot be enabled (eg: because of processes in an intermediate group) are skipped.
+func enableControllers(mountpoint string, parent string) error {
+	rel, err := filepath.Rel(mountpoint, parent)
+	if err != nil {
+		return err
+	}
+
+	dir := mountpoint
+	elements := append([]string{""}, strings.Split(rel, string(filepath.Separator))...)
+
+	for _, element := range elements {
+		if element == "." {
+			continue
+		}
+
+		dir = filepath.Join(dir, element)
+		if err = os.MkdirAll(dir, groupDirPerm); err != nil {
+			return err
+		}
+
+		content, err := os.ReadFile(filepath.Join(dir, cgroupControllersFile))
+		if err != nil {
+			return err
+		}
+
+		for _, controller := range strings.Fields(string(content)) {
+			_ = writeFile(dir, cgroupSubtreeControlFile, "+"+controller)
+		}
+	}
+
+	return nil
+}
+
+func writeResources(group string, resources *specs.LinuxResources) error {
+	translation, _, err := Translate(resources, nil)
+	if err != nil {
+		return err
+	}
+
+	return writeFiles(group, translation.Files)
+}
+
+func writeFiles(group string, writes []FileWrite) error {
+	for _, write := range writes {
+		if err := writeFile(group, write.File, write.Value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeFile writes to an existing interface file.
+// Interface files are created by the kernel, and cgroup2fs refuses to create missing ones (EACCES): never try to.
+func writeFile(dir string, file string, value string) error {
+	fd, err := os.OpenFile(filepath.Join(dir, file), os.O_WRONLY|os.O_TRUNC, 0)
+	if err != nil {
+		return err
+	}
+
+	_, err = fd.WriteString(value)
+
+	return errors.Join(err, fd.Close())
+}
+
+// freeze writes the freezer state, then waits for the kernel to report it in cgroup.events.
+func freeze(ctx context.Context, group string, frozen bool) error {
+	state := "0"
+	if frozen {
+		state = "1"
+	}
+
+	if err := writeFile(group, cgroupFreezeFile, state); err != nil {
+		return err
+	}
+
+	for {
+		current, err := readEvent(group, frozenEvent)
+		if err != nil {
+			return err
+		}
+
+		if current == state {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(freezePollInterval):
+		}
+	}
+}
+
+func readEvent(group string, event string) (string, error) {
+	file, err := os.Open(filepath.Join(group, cgroupEventsFile))
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if value, found := strings.CutPrefix(scanner.Text(), event+" "); found {
+			return strings.TrimSpace(value), nil
+		}
+	}
+
+	return "", scanner.Err()
+}
+
+// kill uses cgroup.kill (kernel 5.14), or falls back to freezing the group and killing each of its members.
+func kill(ctx context.Context, group string) error {
+	err := writeFile(group, cgroupKillFile, "1")
+	if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	if err = freeze(ctx, group, true); err != nil {
+		return err
+	}
+
+	err = filepath.WalkDir(group, func(pth string, entry os.DirEntry, err error) error {
+		if err != nil || !entry.IsDir() {
+			return err
+		}
+
+		pids, err := readProcs(pth)
+		if err != nil {
+			return err
+		}
+
+		for _, pid := range pids {
+			if err = unix.Kill(pid, unix.SIGKILL); err != nil && !errors.Is(err, unix.ESRCH) {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	return errors.Join(err, freeze(ctx, group, false))
+}
+
+func readProcs(group string) ([]int, error) {
+	content, err := os.ReadFile(filepath.Join(group, cgroupProcsFile))
+	if err != nil {
+		return nil, err
+	}
+
+	pids := []int{}
+
+	for _, field := range strings.Fields(string(content)) {
+		pid, err := strconv.Atoi(field)
+		if err != nil {
+			return nil, err
+		}
+
+		pids = append(pids, pid)
+	}
+
+	return pids, nil
+}
+
+// removeGroup removes the group and its descendants, deepest first, as cgroupfs only allows removing empty groups.
+func removeGroup(group string) error {
+	entries, err := os.ReadDir(group)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+
+		return errors.Join(ErrCannotDeleteGroup, err)
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			if err = removeGroup(filepath.Join(group, entry.Name())); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err = unix.Rmdir(group); err != nil {
+		if errors.Is(err, unix.EBUSY) {
+			return fmt.Errorf("%w: %s", ErrGroupNotEmpty, group)
+		}
+
+		return errors.Join(ErrCannotDeleteGroup, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,177 @@
+/*
+   Copyright Farcloser.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package cgroups_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"gotest.tools/v3/assert"
+
+	"go.farcloser.world/containers/security/cgroups"
+	"go.farcloser.world/containers/specs"
+)
+
+// newCgroupfsRoot returns a host root with an (empty) cgroup v2 mountpoint, delegating cpu, memory and pids.
+func newCgroupfsRoot(t *testing.T) string {
+	t.Helper()
+
+	root := t.TempDir()
+	mountpoint := filepath.Join(root, "sys/fs/cgroup")
+	assert.NilError(t, os.MkdirAll(filepath.Join(mountpoint, "containers"), 0o755))
+	assert.NilError(t, os.WriteFile(filepath.Join(mountpoint, "cgroup.controllers"), []byte("cpu memory pids\n"), 0o644))
+	assert.NilError(t, os.WriteFile(filepath.Join(mountpoint, "containers", "cgroup.controllers"),
+		[]byte("cpu memory pids\n"), 0o644))
+
+	return root
+}
+
+// interfaceFiles are the files created by the kernel in a new group, that the managers write to.
+var interfaceFiles = []string{
+	"cgroup.procs", "cgroup.freeze", "cgroup.kill", "cgroup.subtree_control",
+	"cpu.weight", "cpu.max", "memory.max", "memory.swap.max", "pids.max",
+}
+
+// createGroup creates a group the way the kernel would, along with its interface files, minus `missing`.
+func createGroup(dir string, missing ...string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	for _, file := range interfaceFiles {
+		if slices.Contains(missing, file) {
+			continue
+		}
+
+		if err := os.WriteFile(filepath.Join(dir, file), nil, 0o644); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func readGroupFile(t *testing.T, root string, pth string) string {
+	t.Helper()
+
+	content, err := os.ReadFile(filepath.Join(root, "sys/fs/cgroup", pth))
+	assert.NilError(t, err)
+
+	return string(content)
+}
+
+func TestCgroupfs(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	root := newCgroupfsRoot(t)
+
+	manager, err := cgroups.NewGroupManager(ctx, cgroups.CgroupfsManager, root, "/containers")
+	assert.NilError(t, err)
+	assert.Equal(t, manager.Path("foo"), "/containers/foo")
+	assert.NilError(t, createGroup(filepath.Join(root, "sys/fs/cgroup/containers/foo")))
+
+	shares := uint64(1024)
+	quota := int64(50000)
+	limit := int64(1 << 30)
+	swap := int64(2 << 30)
+
+	err = manager.Create(ctx, "foo", 42, &specs.LinuxResources{
+		CPU:    &specs.LinuxCPU{Shares: &shares, Quota: &quota},
+		Memory: &specs.LinuxMemory{Limit: &limit, Swap: &swap},
+		Pids:   &specs.LinuxPids{Limit: 0},
+	})
+	assert.NilError(t, err)
+
+	assert.Equal(t, readGroupFile(t, root, "containers/foo/cgroup.procs"), "42")
+	assert.Equal(t, readGroupFile(t, root, "containers/foo/cpu.weight"), "39")
+	assert.Equal(t, readGroupFile(t, root, "containers/foo/cpu.max"), "50000 100000")
+	assert.Equal(t, readGroupFile(t, root, "containers/foo/memory.max"), "1073741824")
+	assert.Equal(t, readGroupFile(t, root, "containers/foo/memory.swap.max"), "1073741824")
+	// A zero pids limit is unset
+	assert.Equal(t, readGroupFile(t, root, "containers/foo/pids.max"), "")
+
+	assert.NilError(t, manager.AddProcess(ctx, "foo", 43))
+	assert.Equal(t, readGroupFile(t, root, "containers/foo/cgroup.procs"), "43")
+
+	// The kernel reports the freezer state in cgroup.events
+	events := filepath.Join(root, "sys/fs/cgroup/containers/foo/cgroup.events")
+	assert.NilError(t, os.WriteFile(events, []byte("populated 1\nfrozen 1\n"), 0o644))
+	assert.NilError(t, manager.Freeze(ctx, "foo"))
+	assert.Equal(t, readGroupFile(t, root, "containers/foo/cgroup.freeze"), "1")
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	assert.ErrorIs(t, manager.Thaw(canceled, "foo"), context.Canceled)
+
+	assert.NilError(t, manager.Kill(ctx, "foo"))
+	assert.Equal(t, readGroupFile(t, root, "containers/foo/cgroup.kill"), "1")
+
+	// Writes never create interface files: without cgroup.kill, the group is frozen and its members killed
+	bar := filepath.Join(root, "sys/fs/cgroup/containers/bar")
+	assert.NilError(t, createGroup(bar, "cgroup.kill"))
+	assert.NilError(t, os.WriteFile(filepath.Join(bar, "cgroup.events"), []byte("populated 0\nfrozen 1\n"), 0o644))
+	assert.ErrorIs(t, manager.Kill(canceled, "bar"), context.Canceled)
+	assert.Equal(t, readGroupFile(t, root, "containers/bar/cgroup.freeze"), "0")
+	_, err = os.Stat(filepath.Join(bar, "cgroup.kill"))
+	assert.Assert(t, os.IsNotExist(err))
+
+	assert.NilError(t, manager.Create(ctx, "baz", 0, nil))
+	assert.NilError(t, manager.Delete(ctx, "baz"))
+	_, err = os.Stat(filepath.Join(root, "sys/fs/cgroup/containers/baz"))
+	assert.Assert(t, os.IsNotExist(err))
+
+	assert.NilError(t, manager.Close())
+}
+
+func TestCgroupfsInvalidName(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	root := newCgroupfsRoot(t)
+	sibling := filepath.Join(root, "sys/fs/cgroup/sibling")
+	assert.NilError(t, os.MkdirAll(sibling, 0o755))
+
+	manager, err := cgroups.NewGroupManager(ctx, cgroups.CgroupfsManager, root, "/containers")
+	assert.NilError(t, err)
+
+	for _, name := range []string{"", ".", "..", "foo/..", "../sibling", "/sibling"} {
+		assert.ErrorIs(t, manager.Create(ctx, name, 0, nil), cgroups.ErrInvalidGroupName, name)
+		assert.ErrorIs(t, manager.Update(ctx, name, nil), cgroups.ErrInvalidGroupName, name)
+		assert.ErrorIs(t, manager.AddProcess(ctx, name, 42), cgroups.ErrInvalidGroupName, name)
+		assert.ErrorIs(t, manager.Freeze(ctx, name), cgroups.ErrInvalidGroupName, name)
+		assert.ErrorIs(t, manager.Thaw(ctx, name), cgroups.ErrInvalidGroupName, name)
+		assert.ErrorIs(t, manager.Kill(ctx, name), cgroups.ErrInvalidGroupName, name)
+		assert.ErrorIs(t, manager.Delete(ctx, name), cgroups.ErrInvalidGroupName, name)
+	}
+
+	// Neither the parent nor its siblings were touched
+	_, err = os.Stat(sibling)
+	assert.NilError(t, err)
+	_, err = os.Stat(filepath.Join(root, "sys/fs/cgroup/containers"))
+	assert.NilError(t, err)
+}
+
+func TestNewGroupManager(t *testing.T) {
+	t.Parallel()
+
+	_, err := cgroups.NewGroupManager(context.Background(), cgroups.NoneManager, "/", "")
+	assert.ErrorIs(t, err, cgroups.ErrInvalidManager)
+}
@@ -32,9 +32,10 @@ const (
 	Version1  SystemVersion = 1
 	Version2  SystemVersion = 2
 
-	NoManager      Manager = ""
-	NoneManager    Manager = "none"
-	SystemdManager Manager = "systemd"
+	NoManager       Manager = ""
+	NoneManager     Manager = "none"
+	CgroupfsManager Manager = "cgroupfs"
+	SystemdManager  Manager = "systemd"
 
 	NoNsMode      Mode = ""
 	HostNsMode    Mode = "host"
@@ -83,6 +84,12 @@ type memInfo struct {
 	// Whether kernel memory TCP limit is supported or not. Kernel memory TCP
 	// limit (`memory.kmem.tcp.limit_in_bytes`) is not supported on cgroups v2.
 	KernelMemoryTCP bool
+
+	// Whether memory high (throttling) limit is supported or not (cgroups v2 only)
+	MemoryHigh bool
+
+	// Whether killing the whole group on OOM is supported or not (cgroups v2 only)
+	MemoryOomGroup bool
 }
 
 type cpuInfo struct {
@@ -94,12 +101,22 @@ type cpuInfo struct {
 
 	// Whether CPU real-time scheduler is supported
 	CPURealtime bool
+
+	// Whether SCHED_IDLE for the group is supported or not (cgroups v2 only, kernel 5.15)
+	CPUIdle bool
+
+	// Whether CPU utilization clamping is supported or not (cgroups v2 only, CONFIG_UCLAMP_TASK_GROUP)
+	CPUUclamp bool
 }
 
 type blkioInfo struct {
 	// Whether Block IO weight is supported or not
 	BlkioWeight bool
 
+	// Whether Block IO weight is handled by the BFQ scheduler (`io.bfq.weight`) instead of `io.weight`
+	// (cgroups v2 only)
+	BlkioWeightBFQ bool
+
 	// Whether Block IO weight_device is supported or not
 	BlkioWeightDevice bool
 
@@ -17,13 +17,14 @@
 package cgroups
 
 import (
+	"bufio"
+	"fmt"
 	"os"
-	"path"
+	"path/filepath"
+	"slices"
 	"strings"
 
 	"github.com/containerd/cgroups/v3"
-	"github.com/containerd/cgroups/v3/cgroup2"
-	"github.com/moby/sys/userns"
 )
 
 type Controller string
@@ -38,15 +39,21 @@ const (
 	cgroupRoot         = "/sys/fs/cgroup"
 	procSelfCGroupPath = "/proc/self/cgroup"
 	cgroupNSPath       = "/proc/self/ns/cgroup"
+	procSelfUIDMapPath = "/proc/self/uid_map"
 	systemdPath        = "/run/systemd/system"
 
-	memorySwapMaxFile      = "memory.swap.max"
-	cpuSetCPUEffectiveFile = "cpuset.cpus.effective"
-	cpuSetMemEffectiveFile = "cpuset.mems.effective"
+	fullUIDRange = 4294967295
 )
 
+// Version returns the cgroup version of the host.
 func Version() SystemVersion {
-	if cgroups.Mode() == cgroups.Unified {
+	return VersionAt("/")
+}
+
+// VersionAt returns the cgroup version of the host filesystem mounted at `root`.
+// Hybrid hosts, where cgroup v2 is only mounted at /sys/fs/cgroup/unified, are reported as Version1.
+func VersionAt(root string) SystemVersion {
+	if exists(filepath.Join(root, cgroupRoot, cgroupControllersFile)) {
 		return Version2
 	}
 
@@ -54,7 +61,11 @@ func Version() SystemVersion {
 }
 
 func DefaultManager() Manager {
-	if Version() == Version2 && isSystemdAvalailable() {
+	return DefaultManagerAt("/")
+}
+
+func DefaultManagerAt(root string) Manager {
+	if slices.Contains(AvailableManagersAt(root), SystemdManager) {
 		return SystemdManager
 	}
 
@@ -62,7 +73,11 @@ func DefaultManager() Manager {
 }
 
 func DefaultMode() Mode {
-	if Version() == Version2 && isSystemdAvalailable() {
+	return DefaultModeAt("/")
+}
+
+func DefaultModeAt(root string) Mode {
+	if slices.Contains(AvailableManagersAt(root), SystemdManager) {
 		return PrivateNsMode
 	}
 
@@ -70,8 +85,31 @@ func DefaultMode() Mode {
 }
 
 func AvailableManagers() []Manager {
+	return AvailableManagersAt("/")
+}
+
+// AvailableManagersAt returns the managers usable on the host filesystem mounted at `root`.
+// In a user namespace (rootless), only delegated cgroups can be managed (see DetectDelegationAt), and systemd
+// requires a user manager (user@<uid>.service).
+func AvailableManagersAt(root string) []Manager {
 	candidates := []Manager{NoneManager}
-	if Version() == Version2 && isSystemdAvalailable() {
+	if VersionAt(root) != Version2 {
+		return candidates
+	}
+
+	systemd := isSystemdAvalailable(root)
+
+	if runningInUserNS(root) {
+		delegation, _, err := DetectDelegationAt(root)
+		if err != nil || delegation.Nested || len(delegation.Controllers) == 0 {
+			return candidates
+		}
+
+		systemd = systemd && delegation.Unit != ""
+	}
+
+	candidates = append(candidates, CgroupfsManager)
+	if systemd {
 		candidates = append(candidates, SystemdManager)
 	}
 
@@ -79,44 +117,76 @@ func AvailableManagers() []Manager {
 }
 
 func AvailableModes() []Mode {
+	return AvailableModesAt("/")
+}
+
+func AvailableModesAt(root string) []Mode {
 	candidates := []Mode{HostNsMode}
-	if Version() == Version2 && isSystemdAvalailable() {
+	if slices.Contains(AvailableManagersAt(root), SystemdManager) {
 		candidates = append(candidates, PrivateNsMode)
 	}
 
 	return candidates
 }
 
+// New probes the host cgroup at `pth` (relative to the cgroup mountpoint) for supported features.
 func New(pth string) (*Info, []error, error) {
-	var warnings []error
+	return NewAt("/", pth)
+}
+
+// NewAt probes the cgroup at `pth` of the host filesystem mounted at `root`.
+// Both cgroup v2 and v1 (including hybrid mode) hierarchies are supported.
+func NewAt(root string, pth string) (*Info, []error, error) {
+	if root == "" {
+		root = "/"
+	}
 
 	if pth == "" {
 		pth = "/"
 	}
 
-	m, err := cgroup2.Load(pth)
-	if err != nil {
-		return nil, warnings, err
+	if VersionAt(root) == Version1 {
+		return newV1(root, pth)
 	}
 
-	controllers, err := m.Controllers()
+	return newV2(root, pth)
+}
+
+func newV2(root string, pth string) (*Info, []error, error) {
+	var warnings []error
+
+	content, err := os.ReadFile(filepath.Join(root, cgroupRoot, pth, cgroupControllersFile))
 	if err != nil {
 		return nil, warnings, err
 	}
 
-	ctrls := make(map[string]struct{}, len(controllers))
-	for _, c := range controllers {
+	ctrls := make(map[string]struct{})
+	for _, c := range strings.Fields(string(content)) {
 		ctrls[c] = struct{}{}
 	}
 
+	group, probed := interfaceGroup(root, pth)
+
+	// Interface files are looked up in the group when their controller is enabled there. Otherwise, the ones that
+	// always come with their controller are assumed, while those depending on the kernel version, its configuration
+	// or the io scheduler are not.
+	standard := func(ctrl Controller, files ...string) bool {
+		return !slices.Contains(probed, string(ctrl)) || allExist(group, files...)
+	}
+	optional := func(ctrl Controller, files ...string) bool {
+		return slices.Contains(probed, string(ctrl)) && allExist(group, files...)
+	}
+
 	info := &Info{}
 
 	if _, ok := ctrls[string(memoryController)]; !ok {
 		warnings = append(warnings, ErrNoMemoryController)
 	} else {
-		info.MemoryLimit = true
-		info.SwapLimit = getSwapLimit()
-		info.MemoryReservation = true
+		info.MemoryLimit = standard(memoryController, memoryMaxFile)
+		info.SwapLimit = optional(memoryController, memorySwapMaxFile)
+		info.MemoryReservation = standard(memoryController, memoryLowFile)
+		info.MemoryHigh = standard(memoryController, memoryHighFile)
+		info.MemoryOomGroup = optional(memoryController, memoryOomGroupFile)
 		info.OomKillDisable = false
 		info.MemorySwappiness = false
 		info.KernelMemory = false
@@ -126,81 +196,130 @@ func New(pth string) (*Info, []error, error) {
 	if _, ok := ctrls[string(cpuController)]; !ok {
 		warnings = append(warnings, ErrNoCPUController)
 	} else {
-		info.CPUShares = true
-		info.CPUCfs = true
+		info.CPUShares = standard(cpuController, cpuWeightFile)
+		info.CPUCfs = standard(cpuController, cpuMaxFile)
 		info.CPURealtime = false
+		info.CPUIdle = optional(cpuController, cpuIdleFile)
+		info.CPUUclamp = optional(cpuController, cpuUclampMinFile, cpuUclampMaxFile)
 	}
 
 	if _, ok := ctrls[string(ioController)]; !ok {
 		warnings = append(warnings, ErrNoIoController)
 	} else {
-		info.BlkioWeight = true
-		info.BlkioWeightDevice = true
-		info.BlkioReadBpsDevice = true
-		info.BlkioWriteBpsDevice = true
-		info.BlkioReadIOpsDevice = true
-		info.BlkioWriteIOpsDevice = true
+		info.BlkioWeightBFQ = optional(ioController, ioBFQWeightFile) && !optional(ioController, ioWeightFile)
+		info.BlkioWeight = info.BlkioWeightBFQ || optional(ioController, ioWeightFile)
+		info.BlkioWeightDevice = info.BlkioWeight
+		info.BlkioReadBpsDevice = standard(ioController, ioMaxFile)
+		info.BlkioWriteBpsDevice = info.BlkioReadBpsDevice
+		info.BlkioReadIOpsDevice = info.BlkioReadBpsDevice
+		info.BlkioWriteIOpsDevice = info.BlkioReadBpsDevice
 	}
 
 	if _, ok := ctrls[string(cpuSetController)]; !ok {
 		warnings = append(warnings, ErrNoCPUSetController)
 	} else {
 		info.Cpuset = true
-		info.Cpus, info.Mems = getCPUMemInfo(pth)
+		info.Cpus, info.Mems = getCPUMemInfo(filepath.Join(root, cgroupRoot, pth),
+			cpuSetCPUEffectiveFile, cpuSetMemEffectiveFile)
 	}
 
 	if _, ok := ctrls[string(pidsController)]; !ok {
 		warnings = append(warnings, ErrNoPidsController)
 	} else {
-		info.PidsLimit = true
+		info.PidsLimit = standard(pidsController, pidsMaxFile)
 	}
 
-	info.CgroupDevicesEnabled = !userns.RunningInUserNS()
-
-	if _, err = os.Stat(cgroupNSPath); !os.IsNotExist(err) {
-		info.CgroupNamespaces = true
-	}
+	info.CgroupDevicesEnabled = !runningInUserNS(root)
+	info.CgroupNamespaces = hasCgroupNamespaces(root)
 
 	return info, warnings, nil
 }
 
-func isSystemdAvalailable() bool {
-	fi, err := os.Lstat(systemdPath)
-	if err != nil {
-		return false
+// interfaceGroup returns the group to look up interface files (eg: cpu.max) into, along with the controllers enabled
+// there.
+// The root cgroup does not have any, so when probing it, the group of the current process is used instead.
+// No controller is returned if that is the root cgroup as well.
+func interfaceGroup(root string, pth string) (string, []string) {
+	if filepath.Clean(pth) == "/" {
+		if _, unified, err := cgroups.ParseCgroupFileUnified(filepath.Join(root, procSelfCGroupPath)); err == nil &&
+			unified != "" {
+			pth = unified
+		}
 	}
 
-	return fi.IsDir()
+	group := filepath.Join(root, cgroupRoot, pth)
+
+	// Only the root cgroup has no cgroup.type
+	if !exists(filepath.Join(group, cgroupTypeFile)) {
+		return group, nil
+	}
+
+	ctrls, _ := readControllers(group, cgroupControllersFile)
+
+	return group, ctrls
 }
 
-func getSwapLimit() bool {
-	_, unified, err := cgroups.ParseCgroupFileUnified(procSelfCGroupPath)
+func hasCgroupNamespaces(root string) bool {
+	_, err := os.Stat(filepath.Join(root, cgroupNSPath))
+
+	return !os.IsNotExist(err)
+}
+
+// runningInUserNS mirrors userns.RunningInUserNS, reading the uid map of the host filesystem mounted at `root`.
+func runningInUserNS(root string) bool {
+	file, err := os.Open(filepath.Join(root, procSelfUIDMapPath))
 	if err != nil {
 		return false
 	}
+	defer file.Close()
 
-	if unified == "" {
+	line, _, err := bufio.NewReader(file).ReadLine()
+	if err != nil {
 		return false
 	}
 
-	cGroupPath := path.Join(cgroupRoot, unified, memorySwapMaxFile)
-	if _, err = os.Stat(cGroupPath); os.IsNotExist(err) {
+	var inside, outside, length int64
+
+	_, _ = fmt.Sscanf(string(line), "%d %d %d", &inside, &outside, &length)
+
+	return inside != 0 || outside != 0 || length != fullUIDRange
+}
+
+func isSystemdAvalailable(root string) bool {
+	fi, err := os.Lstat(filepath.Join(root, systemdPath))
+	if err != nil {
 		return false
 	}
 
-	return true
+	return fi.IsDir()
 }
 
-func getCPUMemInfo(groupPath string) (string, string) {
-	cpus, err := os.ReadFile(path.Join(cgroupRoot, groupPath, cpuSetCPUEffectiveFile))
+func getCPUMemInfo(groupPath string, cpusFile string, memsFile string) (string, string) {
+	cpus, err := os.ReadFile(filepath.Join(groupPath, cpusFile))
 	if err != nil {
 		return "", ""
 	}
 
-	mems, err := os.ReadFile(path.Join(cgroupRoot, groupPath, cpuSetMemEffectiveFile))
+	mems, err := os.ReadFile(filepath.Join(groupPath, memsFile))
 	if err != nil {
 		return "", ""
 	}
 
 	return strings.TrimSpace(string(cpus)), strings.TrimSpace(string(mems))
 }
+
+func allExist(dir string, files ...string) bool {
+	for _, file := range files {
+		if !exists(filepath.Join(dir, file)) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func exists(pth string) bool {
+	_, err := os.Stat(pth)
+
+	return err == nil
+}
@@ -0,0 +1,277 @@
+/*
+   Copyright Farcloser.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package cgroups_test
+
+import (
+	"os"
+	"testing"
+
+	"gotest.tools/v3/assert"
+
+	"go.farcloser.world/containers/internal/fixtures"
+	"go.farcloser.world/containers/security/cgroups"
+)
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+
+	info, _, err := cgroups.New("/")
+	assert.NilError(t, err)
+	assert.Assert(t, info != nil)
+}
+
+func TestNewAtV1Legacy(t *testing.T) {
+	t.Parallel()
+
+	info, warnings, err := cgroups.NewAt(fixtures.Host(fixtures.CgroupV1Legacy), "/")
+	assert.NilError(t, err)
+	assert.Equal(t, len(warnings), 0)
+
+	assert.Assert(t, info.MemoryLimit)
+	assert.Assert(t, info.SwapLimit)
+	assert.Assert(t, info.MemoryReservation)
+	assert.Assert(t, info.OomKillDisable)
+	assert.Assert(t, info.MemorySwappiness)
+	assert.Assert(t, info.KernelMemory)
+	assert.Assert(t, info.KernelMemoryTCP)
+
+	// Found through mountinfo, as cpu is co-mounted with cpuacct
+	assert.Assert(t, info.CPUShares)
+	assert.Assert(t, info.CPUCfs)
+	assert.Assert(t, info.CPURealtime)
+
+	assert.Assert(t, info.BlkioWeight)
+	assert.Assert(t, info.BlkioWeightDevice)
+	assert.Assert(t, info.BlkioReadBpsDevice)
+	assert.Assert(t, info.BlkioWriteBpsDevice)
+	assert.Assert(t, info.BlkioReadIOpsDevice)
+	assert.Assert(t, info.BlkioWriteIOpsDevice)
+
+	assert.Assert(t, info.Cpuset)
+	assert.Equal(t, info.Cpus, "0-3")
+	assert.Equal(t, info.Mems, "0")
+
+	assert.Assert(t, info.PidsLimit)
+	assert.Assert(t, info.CgroupDevicesEnabled)
+	assert.Assert(t, !info.CgroupNamespaces)
+}
+
+func TestNewAtV1Group(t *testing.T) {
+	t.Parallel()
+
+	root := fixtures.Host(fixtures.CgroupV1Legacy)
+
+	info, _, err := cgroups.NewAt(root, "/user.slice")
+	assert.NilError(t, err)
+	assert.Assert(t, info.MemoryLimit)
+	assert.Assert(t, info.PidsLimit)
+
+	// Interface files are probed in the group, not assumed from the controller being mounted
+	info, _, err = cgroups.NewAt(root, "/system.slice")
+	assert.NilError(t, err)
+	assert.Assert(t, info.CPUShares)
+	assert.Assert(t, !info.MemoryLimit)
+	assert.Assert(t, !info.PidsLimit)
+
+	// The group must exist in at least one hierarchy
+
+	_, _, err = cgroups.NewAt(root, "/missing.slice")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestNewAtV1Hybrid(t *testing.T) {
+	t.Parallel()
+
+	info, warnings, err := cgroups.NewAt(fixtures.Host(fixtures.CgroupV1Hybrid), "/")
+	assert.NilError(t, err)
+	assert.Equal(t, len(warnings), 1)
+	assert.ErrorIs(t, warnings[0], cgroups.ErrNoPidsController)
+
+	assert.Assert(t, info.MemoryLimit)
+	assert.Assert(t, !info.SwapLimit)
+	assert.Assert(t, info.MemoryReservation)
+	assert.Assert(t, info.OomKillDisable)
+	assert.Assert(t, info.MemorySwappiness)
+	assert.Assert(t, !info.KernelMemory)
+	assert.Assert(t, !info.KernelMemoryTCP)
+
+	assert.Assert(t, info.CPUShares)
+	assert.Assert(t, info.CPUCfs)
+	assert.Assert(t, !info.CPURealtime)
+
+	assert.Assert(t, !info.BlkioWeight)
+	assert.Assert(t, !info.BlkioWeightDevice)
+	assert.Assert(t, info.BlkioReadBpsDevice)
+
+	assert.Equal(t, info.Cpus, "0-7")
+	assert.Assert(t, !info.PidsLimit)
+	assert.Assert(t, !info.CgroupDevicesEnabled)
+	assert.Assert(t, info.CgroupNamespaces)
+}
+
+func TestNewAtV2Systemd(t *testing.T) {
+	t.Parallel()
+
+	root := fixtures.Host(fixtures.CgroupV2Systemd)
+
+	info, warnings, err := cgroups.NewAt(root, "/")
+	assert.NilError(t, err)
+	assert.Equal(t, len(warnings), 0)
+
+	assert.Assert(t, info.MemoryLimit)
+	// memory.swap.max is looked up in the group of the process, from /proc/self/cgroup
+	assert.Assert(t, info.SwapLimit)
+	assert.Assert(t, info.MemoryHigh)
+	assert.Assert(t, info.MemoryOomGroup)
+	assert.Assert(t, info.CPUShares)
+	assert.Assert(t, info.CPUCfs)
+	assert.Assert(t, info.CPUIdle)
+	assert.Assert(t, !info.CPUUclamp)
+	// Only io.bfq.weight is present
+	assert.Assert(t, info.BlkioWeight)
+	assert.Assert(t, info.BlkioWeightBFQ)
+	assert.Assert(t, info.BlkioWriteIOpsDevice)
+	assert.Equal(t, info.Cpus, "0-7")
+	assert.Equal(t, info.Mems, "0")
+	assert.Assert(t, info.PidsLimit)
+	assert.Assert(t, info.CgroupDevicesEnabled)
+	assert.Assert(t, info.CgroupNamespaces)
+}
+
+func TestNewAtV2NoSystemd(t *testing.T) {
+	t.Parallel()
+
+	info, warnings, err := cgroups.NewAt(fixtures.Host(fixtures.CgroupV2NoSystemd), "")
+	assert.NilError(t, err)
+	assert.Equal(t, len(warnings), 0)
+
+	// The process is in the root cgroup, which has no interface files: features that always come with their
+	// controller are assumed, the others are not
+	assert.Assert(t, info.MemoryLimit)
+	assert.Assert(t, info.MemoryHigh)
+	assert.Assert(t, !info.SwapLimit)
+	assert.Assert(t, !info.MemoryOomGroup)
+	assert.Assert(t, info.CPUCfs)
+	assert.Assert(t, !info.CPUIdle)
+	// io.weight depends on the io scheduler
+	assert.Assert(t, !info.BlkioWeight)
+	assert.Assert(t, !info.BlkioWeightBFQ)
+	assert.Assert(t, info.BlkioReadBpsDevice)
+	assert.Equal(t, info.Cpus, "0-3")
+	assert.Assert(t, info.CgroupDevicesEnabled)
+}
+
+func TestNewAtV2Rootless(t *testing.T) {
+	t.Parallel()
+
+	info, warnings, err := cgroups.NewAt(fixtures.Host(fixtures.CgroupV2Rootless),
+		"/user.slice/user-1000.slice/user@1000.service/app.slice/rootlesskit.scope")
+	assert.NilError(t, err)
+	assert.Equal(t, len(warnings), 3)
+	assert.ErrorIs(t, warnings[0], cgroups.ErrNoCPUController)
+	assert.ErrorIs(t, warnings[1], cgroups.ErrNoIoController)
+	assert.ErrorIs(t, warnings[2], cgroups.ErrNoCPUSetController)
+
+	assert.Assert(t, info.MemoryLimit)
+	assert.Assert(t, info.SwapLimit)
+	assert.Assert(t, info.MemoryReservation)
+	assert.Assert(t, info.MemoryHigh)
+	assert.Assert(t, info.MemoryOomGroup)
+	assert.Assert(t, info.PidsLimit)
+	assert.Assert(t, !info.CPUShares)
+	// Devices cannot be controlled from a user namespace
+	assert.Assert(t, !info.CgroupDevicesEnabled)
+}
+
+func TestNewAtV2RootlessRoot(t *testing.T) {
+	t.Parallel()
+
+	info, warnings, err := cgroups.NewAt(fixtures.Host(fixtures.CgroupV2Rootless), "/")
+	assert.NilError(t, err)
+	assert.Equal(t, len(warnings), 0)
+
+	// Interface files are looked up in the group of the process, where only memory and pids are enabled
+	assert.Assert(t, info.MemoryLimit)
+	assert.Assert(t, info.MemoryOomGroup)
+	assert.Assert(t, info.PidsLimit)
+	// Other controllers are available from the root cgroup, and their interface files are assumed or not
+	assert.Assert(t, info.CPUShares)
+	assert.Assert(t, info.CPUCfs)
+	assert.Assert(t, !info.CPUIdle)
+	assert.Assert(t, !info.BlkioWeight)
+	assert.Assert(t, info.BlkioReadBpsDevice)
+}
+
+func TestNewAtWSL(t *testing.T) {
+	t.Parallel()
+
+	info, warnings, err := cgroups.NewAt(fixtures.Host(fixtures.WSL), "/")
+	assert.NilError(t, err)
+	assert.Equal(t, len(warnings), 0)
+
+	assert.Assert(t, info.SwapLimit)
+	assert.Assert(t, !info.KernelMemory)
+	assert.Assert(t, info.CPUCfs)
+	assert.Assert(t, !info.CPURealtime)
+	assert.Assert(t, !info.BlkioWeight)
+	assert.Assert(t, info.BlkioWriteIOpsDevice)
+	assert.Equal(t, info.Cpus, "0-11")
+	assert.Assert(t, info.PidsLimit)
+	assert.Assert(t, info.CgroupDevicesEnabled)
+}
+
+func TestManagersAndModes(t *testing.T) {
+	t.Parallel()
+
+	for name, expected := range map[string]struct {
+		version  cgroups.SystemVersion
+		manager  cgroups.Manager
+		mode     cgroups.Mode
+		managers []cgroups.Manager
+		modes    []cgroups.Mode
+	}{
+		fixtures.CgroupV1Legacy: {
+			cgroups.Version1, cgroups.NoneManager, cgroups.NoNsMode,
+			[]cgroups.Manager{cgroups.NoneManager}, []cgroups.Mode{cgroups.HostNsMode},
+		},
+		fixtures.WSL: {
+			cgroups.Version1, cgroups.NoneManager, cgroups.NoNsMode,
+			[]cgroups.Manager{cgroups.NoneManager}, []cgroups.Mode{cgroups.HostNsMode},
+		},
+		fixtures.CgroupV2NoSystemd: {
+			cgroups.Version2, cgroups.NoneManager, cgroups.NoNsMode,
+			[]cgroups.Manager{cgroups.NoneManager, cgroups.CgroupfsManager}, []cgroups.Mode{cgroups.HostNsMode},
+		},
+		fixtures.CgroupV2Rootless: {
+			cgroups.Version2, cgroups.SystemdManager, cgroups.PrivateNsMode,
+			[]cgroups.Manager{cgroups.NoneManager, cgroups.CgroupfsManager, cgroups.SystemdManager},
+			[]cgroups.Mode{cgroups.HostNsMode, cgroups.PrivateNsMode},
+		},
+		fixtures.CgroupV2Systemd: {
+			cgroups.Version2, cgroups.SystemdManager, cgroups.PrivateNsMode,
+			[]cgroups.Manager{cgroups.NoneManager, cgroups.CgroupfsManager, cgroups.SystemdManager},
+			[]cgroups.Mode{cgroups.HostNsMode, cgroups.PrivateNsMode},
+		},
+	} {
+		root := fixtures.Host(name)
+		assert.Equal(t, cgroups.VersionAt(root), expected.version, name)
+		assert.Equal(t, cgroups.DefaultManagerAt(root), expected.manager, name)
+		assert.Equal(t, cgroups.DefaultModeAt(root), expected.mode, name)
+		assert.DeepEqual(t, cgroups.AvailableManagersAt(root), expected.managers)
+		assert.DeepEqual(t, cgroups.AvailableModesAt(root), expected.modes)
+	}
+}
@@ -22,18 +22,38 @@ func Version() SystemVersion {
 	return NoVersion
 }
 
+func VersionAt(_ string) SystemVersion {
+	return NoVersion
+}
+
 func DefaultManager() Manager {
 	return NoManager
 }
 
+func DefaultManagerAt(_ string) Manager {
+	return NoManager
+}
+
 func DefaultMode() Mode {
 	return NoNsMode
 }
 
+func DefaultModeAt(_ string) Mode {
+	return NoNsMode
+}
+
 func AvailableManagers() []Manager {
 	return []Manager{}
 }
 
+func AvailableManagersAt(_ string) []Manager {
+	return []Manager{}
+}
+
 func AvailableModes() []Mode {
 	return []Mode{}
 }
+
+func AvailableModesAt(_ string) []Mode {
+	return []Mode{}
+}
@@ -0,0 +1,207 @@
+/*
+   Copyright Farcloser.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+/*
+   Portions from
+	https://github.com/moby/moby/blob/cff4f20c44a3a7c882ed73934dec6a77246c6323/pkg/sysinfo/sysinfo_linux.go
+   Copyright (C) Docker/Moby authors.
+   Licensed under the Apache License, Version 2.0
+   NOTICE: https://github.com/moby/moby/blob/cff4f20c44a3a7c882ed73934dec6a77246c6323/NOTICE
+*/
+
+package cgroups
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+const (
+	blkioController   Controller = "blkio"
+	devicesController Controller = "devices"
+
+	procSelfMountInfoPath = "/proc/self/mountinfo"
+	cgroupV1FSType        = "cgroup"
+
+	memoryMemswLimitFile    = "memory.memsw.limit_in_bytes"
+	memorySoftLimitFile     = "memory.soft_limit_in_bytes"
+	memoryOomControlFile    = "memory.oom_control"
+	memorySwappinessFile    = "memory.swappiness"
+	memoryKmemLimitFile     = "memory.kmem.limit_in_bytes"
+	memoryKmemTCPLimitFile  = "memory.kmem.tcp.limit_in_bytes"
+	cpuSharesFile           = "cpu.shares"
+	cpuCfsPeriodFile        = "cpu.cfs_period_us"
+	cpuCfsQuotaFile         = "cpu.cfs_quota_us"
+	cpuRtPeriodFile         = "cpu.rt_period_us"
+	cpuRtRuntimeFile        = "cpu.rt_runtime_us"
+	blkioWeightFile         = "blkio.weight"
+	blkioWeightDeviceFile   = "blkio.weight_device"
+	blkioReadBpsFile        = "blkio.throttle.read_bps_device"
+	blkioWriteBpsFile       = "blkio.throttle.write_bps_device"
+	blkioReadIOpsFile       = "blkio.throttle.read_iops_device"
+	blkioWriteIOpsFile      = "blkio.throttle.write_iops_device"
+	cpuSetCPUsFile          = "cpuset.cpus"
+	cpuSetMemsFile          = "cpuset.mems"
+	mountInfoSeparator      = " - "
+	mountInfoMountPointIdx  = 4
+	mountInfoMinFields      = 5
+	mountInfoSuperOptionIdx = 2
+)
+
+// newV1 probes each cgroup v1 hierarchy, on legacy or hybrid hosts.
+func newV1(root string, pth string) (*Info, []error, error) {
+	var warnings []error
+
+	mounts := findV1MountPoints(root)
+
+	// As with cgroup v2, the group must exist, in at least one of the hierarchies
+	var err error
+	for _, dir := range mounts {
+		if _, err = os.Stat(filepath.Join(dir, pth)); err == nil {
+			break
+		}
+	}
+
+	if err != nil {
+		return nil, warnings, err
+	}
+
+	info := &Info{}
+
+	if dir, ok := mounts[memoryController]; !ok {
+		warnings = append(warnings, ErrNoMemoryController)
+	} else {
+		dir = filepath.Join(dir, pth)
+		info.MemoryLimit = exists(filepath.Join(dir, memoryLimitFile))
+		info.SwapLimit = exists(filepath.Join(dir, memoryMemswLimitFile))
+		info.MemoryReservation = exists(filepath.Join(dir, memorySoftLimitFile))
+		info.OomKillDisable = exists(filepath.Join(dir, memoryOomControlFile))
+		info.MemorySwappiness = exists(filepath.Join(dir, memorySwappinessFile))
+		info.KernelMemory = exists(filepath.Join(dir, memoryKmemLimitFile))
+		info.KernelMemoryTCP = exists(filepath.Join(dir, memoryKmemTCPLimitFile))
+	}
+
+	if dir, ok := mounts[cpuController]; !ok {
+		warnings = append(warnings, ErrNoCPUController)
+	} else {
+		dir = filepath.Join(dir, pth)
+		info.CPUShares = exists(filepath.Join(dir, cpuSharesFile))
+		info.CPUCfs = exists(filepath.Join(dir, cpuCfsPeriodFile)) && exists(filepath.Join(dir, cpuCfsQuotaFile))
+		info.CPURealtime = exists(filepath.Join(dir, cpuRtPeriodFile)) && exists(filepath.Join(dir, cpuRtRuntimeFile))
+	}
+
+	if dir, ok := mounts[blkioController]; !ok {
+		warnings = append(warnings, ErrNoIoController)
+	} else {
+		dir = filepath.Join(dir, pth)
+		info.BlkioWeight = exists(filepath.Join(dir, blkioWeightFile))
+		info.BlkioWeightDevice = exists(filepath.Join(dir, blkioWeightDeviceFile))
+		info.BlkioReadBpsDevice = exists(filepath.Join(dir, blkioReadBpsFile))
+		info.BlkioWriteBpsDevice = exists(filepath.Join(dir, blkioWriteBpsFile))
+		info.BlkioReadIOpsDevice = exists(filepath.Join(dir, blkioReadIOpsFile))
+		info.BlkioWriteIOpsDevice = exists(filepath.Join(dir, blkioWriteIOpsFile))
+	}
+
+	if dir, ok := mounts[cpuSetController]; !ok {
+		warnings = append(warnings, ErrNoCPUSetController)
+	} else {
+		info.Cpuset = true
+		info.Cpus, info.Mems = getCPUMemInfo(filepath.Join(dir, pth), cpuSetCPUsFile, cpuSetMemsFile)
+	}
+
+	if dir, ok := mounts[pidsController]; !ok {
+		warnings = append(warnings, ErrNoPidsController)
+	} else {
+		// The kernel does not create pids.max in the root group, which cannot be limited
+		info.PidsLimit = filepath.Clean(pth) == "/" || exists(filepath.Join(dir, pth, pidsMaxFile))
+	}
+
+	_, info.CgroupDevicesEnabled = mounts[devicesController]
+	info.CgroupNamespaces = hasCgroupNamespaces(root)
+
+	return info, warnings, nil
+}
+
+// findV1MountPoints returns where each v1 controller is mounted.
+// Mount points are read from mountinfo, as controllers may be co-mounted (eg: `cpu,cpuacct`). If that fails,
+// the conventional layout (/sys/fs/cgroup/<controller>) is assumed.
+func findV1MountPoints(root string) map[Controller]string {
+	mounts := map[Controller]string{}
+	controllers := []Controller{
+		memoryController,
+		cpuController,
+		blkioController,
+		cpuSetController,
+		pidsController,
+		devicesController,
+	}
+
+	if file, err := os.Open(filepath.Join(root, procSelfMountInfoPath)); err == nil {
+		defer file.Close()
+
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			mountPoint, options, ok := parseV1MountInfo(scanner.Text())
+			if !ok {
+				continue
+			}
+
+			for _, option := range options {
+				if !slices.Contains(controllers, Controller(option)) {
+					continue
+				}
+
+				if _, found := mounts[Controller(option)]; !found {
+					mounts[Controller(option)] = filepath.Join(root, mountPoint)
+				}
+			}
+		}
+	}
+
+	for _, controller := range controllers {
+		if _, ok := mounts[controller]; ok {
+			continue
+		}
+
+		if dir := filepath.Join(root, cgroupRoot, string(controller)); exists(dir) {
+			mounts[controller] = dir
+		}
+	}
+
+	return mounts
+}
+
+// parseV1MountInfo returns the mount point and the super options of a cgroup v1 mountinfo line.
+// Example:
+// 35 25 0:30 / /sys/fs/cgroup/cpu,cpuacct rw,nosuid shared:15 - cgroup cgroup rw,cpu,cpuacct
+func parseV1MountInfo(line string) (string, []string, bool) {
+	before, after, found := strings.Cut(line, mountInfoSeparator)
+	if !found {
+		return "", nil, false
+	}
+
+	fields := strings.Fields(before)
+	post := strings.Fields(after)
+
+	if len(fields) < mountInfoMinFields || len(post) <= mountInfoSuperOptionIdx || post[0] != cgroupV1FSType {
+		return "", nil, false
+	}
+
+	return fields[mountInfoMountPointIdx], strings.Split(post[mountInfoSuperOptionIdx], ","), true
+}
@@ -0,0 +1,58 @@
+/*
+   Copyright Farcloser.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package cgroups
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// maxSetID bounds the ids of a list, so that huge ranges cannot exhaust memory: 8192 is the largest number of CPUs
+// the kernel can be built for.
+const maxSetID = 8191
+
+var errInvalidList = errors.New("invalid list element")
+
+// ParseSet parses a cpuset list (eg: 0-3,8) into the ids it contains, which cannot be above 8191.
+func ParseSet(list string) ([]int, error) {
+	ids := []int{}
+
+	for _, part := range strings.Split(strings.TrimSpace(list), ",") {
+		first, last, isRange := strings.Cut(part, "-")
+
+		start, err := strconv.Atoi(first)
+		if err != nil || start < 0 || start > maxSetID {
+			return nil, fmt.Errorf("%w %q", errInvalidList, part)
+		}
+
+		end := start
+		if isRange {
+			end, err = strconv.Atoi(last)
+			if err != nil || end < start || end > maxSetID {
+				return nil, fmt.Errorf("%w %q", errInvalidList, part)
+			}
+		}
+
+		for id := start; id <= end; id++ {
+			ids = append(ids, id)
+		}
+	}
+
+	return ids, nil
+}
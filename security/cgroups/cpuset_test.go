@@ -0,0 +1,42 @@
+/*
+   Copyright Farcloser.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package cgroups_test
+
+import (
+	"testing"
+
+	"gotest.tools/v3/assert"
+
+	"go.farcloser.world/containers/security/cgroups"
+)
+
+func TestParseSet(t *testing.T) {
+	t.Parallel()
+
+	ids, err := cgroups.ParseSet("0-3,8\n")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, ids, []int{0, 1, 2, 3, 8})
+
+	ids, err = cgroups.ParseSet("8190-8191")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, ids, []int{8190, 8191})
+
+	for _, list := range []string{"", "-1", "3-1", "a", "8192", "0-8192", "0-2147483647"} {
+		_, err = cgroups.ParseSet(list)
+		assert.Assert(t, err != nil, list)
+	}
+}
@@ -0,0 +1,43 @@
+/*
+   Copyright Farcloser.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package cgroups
+
+import "errors"
+
+var (
+	// ErrControllerNotDelegated is the warning for controllers enabled on the host, but not usable by rootless
+	// containers.
+	ErrControllerNotDelegated = errors.New("controller not delegated")
+	// ErrNoCgroupNamespace is the warning for processes running in a container that has no cgroup namespace.
+	ErrNoCgroupNamespace = errors.New("running in a container without a cgroup namespace")
+)
+
+// Delegation describes which controllers are delegated to the cgroup of the current process, which is what
+// rootless containers can use.
+type Delegation struct {
+	// Path is the cgroup of the process, relative to the cgroup mountpoint.
+	Path string
+	// Unit is the systemd user manager unit (user@<uid>.service) the cgroup belongs to, if any.
+	Unit string
+	// Controllers are the controllers delegated to the unit (or to the cgroup of the process, if there is no unit).
+	Controllers []string
+	// Missing are the controllers available on the host that are not delegated.
+	Missing []string
+	// Nested is true when running in a container that has no cgroup namespace, where Path is the host cgroup path
+	// of the container.
+	Nested bool
+}
@@ -0,0 +1,176 @@
+/*
+   Copyright Farcloser.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package cgroups
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/containerd/cgroups/v3"
+)
+
+const (
+	cgroupV2FSType   = "cgroup2"
+	mountInfoRootIdx = 3
+)
+
+//nolint:gochecknoglobals
+var userManagerUnit = regexp.MustCompile(`^user@\d+\.service$`)
+
+// DetectDelegation reports the controllers delegated to the cgroup of the current process.
+func DetectDelegation() (*Delegation, []error, error) {
+	return DetectDelegationAt("/")
+}
+
+// DetectDelegationAt reports the controllers delegated to the cgroup of the current process, according to the host
+// filesystem mounted at `root`. Only cgroup v2 supports delegation.
+// Warnings tell which controllers are not delegated and how to fix it, and whether the process runs in a container
+// without a cgroup namespace.
+func DetectDelegationAt(root string) (*Delegation, []error, error) {
+	var warnings []error
+
+	if VersionAt(root) != Version2 {
+		return nil, warnings, ErrUnsupported
+	}
+
+	_, own, err := cgroups.ParseCgroupFileUnified(filepath.Join(root, procSelfCGroupPath))
+	if err != nil {
+		return nil, warnings, err
+	}
+
+	mountpoint := filepath.Join(root, cgroupRoot)
+	delegation := &Delegation{Path: filepath.Join("/", own)}
+
+	// Without a cgroup namespace, the container cgroup path is the host one: runtimes then mount the group of the
+	// container instead of the root of the hierarchy, or do not mount it at all
+	if delegation.Path != "/" &&
+		(mountRoot(root) != "/" || !exists(filepath.Join(mountpoint, delegation.Path))) {
+		delegation.Nested = true
+		warnings = append(warnings, fmt.Errorf("%w: its cgroup (%s) cannot be delegated, run it with a private "+
+			"cgroup namespace (eg: --cgroupns=private)", ErrNoCgroupNamespace, delegation.Path))
+	}
+
+	available, err := readControllers(mountpoint, cgroupControllersFile)
+	if err != nil {
+		return nil, warnings, err
+	}
+
+	if delegation.Nested {
+		delegation.Missing = available
+
+		return delegation, warnings, nil
+	}
+
+	// Walk down the path to find the user manager unit, and where each controller stopped being delegated
+	dir := mountpoint
+	delegated := available
+	lostAt := map[string]string{}
+
+	for _, element := range strings.Split(strings.Trim(delegation.Path, "/"), "/") {
+		if element == "" {
+			break
+		}
+
+		enabled, err := readControllers(dir, cgroupSubtreeControlFile)
+		if err != nil {
+			return nil, warnings, err
+		}
+
+		for _, controller := range delegated {
+			if !slices.Contains(enabled, controller) {
+				lostAt[controller] = filepath.Join("/", strings.TrimPrefix(dir, mountpoint))
+			}
+		}
+
+		delegated = enabled
+		dir = filepath.Join(dir, element)
+
+		if userManagerUnit.MatchString(element) {
+			delegation.Unit = element
+
+			break
+		}
+	}
+
+	delegation.Controllers, err = readControllers(dir, cgroupControllersFile)
+	if err != nil {
+		return nil, warnings, err
+	}
+
+	for _, controller := range available {
+		if slices.Contains(delegation.Controllers, controller) {
+			continue
+		}
+
+		delegation.Missing = append(delegation.Missing, controller)
+
+		if delegation.Unit != "" {
+			warnings = append(warnings, fmt.Errorf("%w: %s not delegated to %s (disabled in %s); add Delegate=%s",
+				ErrControllerNotDelegated, controller, delegation.Unit, lostAt[controller], controller))
+		} else {
+			warnings = append(warnings, fmt.Errorf("%w: %s not enabled for %s",
+				ErrControllerNotDelegated, controller, delegation.Path))
+		}
+	}
+
+	return delegation, warnings, nil
+}
+
+func readControllers(dir string, file string) ([]string, error) {
+	content, err := os.ReadFile(filepath.Join(dir, file))
+	if err != nil {
+		return nil, err
+	}
+
+	return strings.Fields(string(content)), nil
+}
+
+// mountRoot returns the group mounted at /sys/fs/cgroup according to mountinfo, defaulting to the root of the
+// hierarchy. Within a cgroup namespace, the root of the namespace is the root of the hierarchy.
+// Example:
+// 626 625 0:29 /system.slice/docker-6b3f.scope /sys/fs/cgroup ro,nosuid - cgroup2 cgroup rw,nsdelegate
+func mountRoot(root string) string {
+	group := "/"
+
+	file, err := os.Open(filepath.Join(root, procSelfMountInfoPath))
+	if err != nil {
+		return group
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		before, after, found := strings.Cut(scanner.Text(), mountInfoSeparator)
+		fields := strings.Fields(before)
+		post := strings.Fields(after)
+
+		if !found || len(fields) < mountInfoMinFields || len(post) == 0 || post[0] != cgroupV2FSType ||
+			fields[mountInfoMountPointIdx] != cgroupRoot {
+			continue
+		}
+
+		// The last mount hides the previous ones
+		group = fields[mountInfoRootIdx]
+	}
+
+	return group
+}
@@ -0,0 +1,85 @@
+/*
+   Copyright Farcloser.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package cgroups_test
+
+import (
+	"testing"
+
+	"gotest.tools/v3/assert"
+
+	"go.farcloser.world/containers/internal/fixtures"
+	"go.farcloser.world/containers/security/cgroups"
+)
+
+func TestDetectDelegationRootless(t *testing.T) {
+	t.Parallel()
+
+	delegation, warnings, err := cgroups.DetectDelegationAt(fixtures.Host(fixtures.CgroupV2Rootless))
+	assert.NilError(t, err)
+
+	assert.Equal(t, delegation.Path, "/user.slice/user-1000.slice/user@1000.service/app.slice/rootlesskit.scope")
+	assert.Equal(t, delegation.Unit, "user@1000.service")
+	assert.DeepEqual(t, delegation.Controllers, []string{"memory", "pids"})
+	assert.DeepEqual(t, delegation.Missing, []string{"cpuset", "cpu", "io", "hugetlb", "misc"})
+	assert.Assert(t, !delegation.Nested)
+
+	assert.Equal(t, len(warnings), 5)
+	assert.ErrorIs(t, warnings[1], cgroups.ErrControllerNotDelegated)
+	assert.ErrorContains(t, warnings[1],
+		"cpu not delegated to user@1000.service (disabled in /user.slice/user-1000.slice); add Delegate=cpu")
+	assert.ErrorContains(t, warnings[3], "hugetlb not delegated to user@1000.service (disabled in /)")
+}
+
+func TestDetectDelegationRootful(t *testing.T) {
+	t.Parallel()
+
+	delegation, warnings, err := cgroups.DetectDelegationAt(fixtures.Host(fixtures.CgroupV2NoSystemd))
+	assert.NilError(t, err)
+	assert.Equal(t, len(warnings), 0)
+
+	assert.Equal(t, delegation.Path, "/")
+	assert.Equal(t, delegation.Unit, "")
+	assert.DeepEqual(t, delegation.Controllers, []string{"cpuset", "cpu", "io", "memory", "hugetlb", "pids"})
+}
+
+func TestDetectDelegationNested(t *testing.T) {
+	t.Parallel()
+
+	delegation, warnings, err := cgroups.DetectDelegationAt(fixtures.Host(fixtures.NestedNoCgroupNS))
+	assert.NilError(t, err)
+	assert.Assert(t, delegation.Nested)
+	assert.Equal(t, len(delegation.Controllers), 0)
+
+	assert.Equal(t, len(warnings), 1)
+	assert.ErrorIs(t, warnings[0], cgroups.ErrNoCgroupNamespace)
+
+	_, _, err = cgroups.DetectDelegationAt(fixtures.Host(fixtures.WSL))
+	assert.ErrorIs(t, err, cgroups.ErrUnsupported)
+}
+
+func TestDetectDelegationNestedCgroupNS(t *testing.T) {
+	t.Parallel()
+
+	// Being in a container does not matter, as long as it has its own cgroup namespace
+	delegation, warnings, err := cgroups.DetectDelegationAt(fixtures.Host(fixtures.NestedCgroupNS))
+	assert.NilError(t, err)
+	assert.Equal(t, len(warnings), 0)
+
+	assert.Assert(t, !delegation.Nested)
+	assert.Equal(t, delegation.Path, "/init.scope")
+	assert.DeepEqual(t, delegation.Controllers, []string{"cpuset", "cpu", "io", "memory", "pids"})
+}
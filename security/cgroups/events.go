@@ -0,0 +1,96 @@
+/*
+   Copyright Farcloser.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package cgroups
+
+import (
+	"errors"
+	"time"
+)
+
+type (
+	EventType string
+	Resource  string
+)
+
+const (
+	// EventOOM is sent when the group hit its memory limit, and the OOM killer was invoked.
+	EventOOM EventType = "oom"
+	// EventOOMKill is sent when a process of the group was killed by the OOM killer.
+	EventOOMKill EventType = "oom_kill"
+	// EventHigh is sent when the group was throttled, as its memory usage went over memory.high.
+	EventHigh EventType = "high"
+	// EventMax is sent when the memory usage of the group was about to go over memory.max.
+	EventMax EventType = "max"
+	// EventPressure is sent when a PSI trigger fires.
+	EventPressure EventType = "pressure"
+
+	CPUResource    Resource = "cpu"
+	MemoryResource Resource = "memory"
+	IOResource     Resource = "io"
+)
+
+var (
+	ErrInvalidTrigger = errors.New("invalid pressure trigger")
+	ErrCannotWatch    = errors.New("cannot watch group")
+)
+
+// Event is a notification about a group.
+type Event struct {
+	Type EventType
+	// Count is the number of occurrences since the group was created, as reported by memory.events.
+	Count uint64
+	// Trigger is the trigger that fired, for EventPressure.
+	Trigger *Trigger
+}
+
+// Trigger is a PSI trigger: it fires when the tasks of a group were stalled on Resource for more than Threshold,
+// over any Window.
+type Trigger struct {
+	Resource Resource
+	// Full selects the time all tasks were stalled at once, instead of the time at least one was (some).
+	Full bool
+	// Threshold must be lower than Window.
+	Threshold time.Duration
+	// Window must be between 500ms and 10s.
+	Window time.Duration
+}
+
+// PressureData is a line of a PSI file: the percentage of time tasks were stalled over the last 10, 60 and 300
+// seconds, and the total stall time.
+type PressureData struct {
+	Avg10  float64
+	Avg60  float64
+	Avg300 float64
+	Total  time.Duration
+}
+
+// Pressure is the content of a PSI file (eg: memory.pressure).
+type Pressure struct {
+	Some *PressureData
+	// Full is nil for cpu on kernels older than 5.13.
+	Full *PressureData
+}
+
+// Watcher watches a group for memory events and pressure.
+type Watcher struct {
+	// Root is where the host filesystem is mounted, usually "/".
+	Root string
+	// Path is the group, relative to the cgroup mountpoint.
+	Path string
+	// Triggers are the PSI triggers to register.
+	Triggers []Trigger
+}
@@ -0,0 +1,371 @@
+/*
+   Copyright Farcloser.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package cgroups
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	memoryEventsFile = "memory.events"
+	pressureSuffix   = ".pressure"
+
+	minTriggerWindow = 500 * time.Millisecond
+	maxTriggerWindow = 10 * time.Second
+
+	inotifyBufferSize = 4096
+)
+
+// Watch sends the memory events (oom, oom_kill, high, max) of the group, as they happen, and an EventPressure each
+// time a trigger fires.
+// Both channels are closed when `ctx` is done, when the group is removed, or after an error is sent.
+func (w *Watcher) Watch(ctx context.Context) (<-chan Event, <-chan error, error) {
+	group := filepath.Join(w.Root, cgroupRoot, w.Path)
+
+	counts, err := readMemoryEvents(group)
+	if err != nil {
+		return nil, nil, errors.Join(ErrCannotWatch, err)
+	}
+
+	watch := &watch{group: group, counts: counts, fds: []int{}}
+
+	if err = watch.setup(w.Triggers); err != nil {
+		watch.close()
+
+		return nil, nil, errors.Join(ErrCannotWatch, err)
+	}
+
+	events := make(chan Event)
+	errs := make(chan error, 1)
+
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+
+	// Wake up poll on cancellation. File descriptors are only closed once this is done.
+	go func() {
+		defer close(stopped)
+
+		select {
+		case <-ctx.Done():
+			_, _ = unix.Write(watch.cancel, []byte{1, 0, 0, 0, 0, 0, 0, 0})
+		case <-stop:
+		}
+	}()
+
+	go func() {
+		defer close(errs)
+		defer close(events)
+
+		err := watch.loop(ctx, events)
+
+		close(stop)
+		<-stopped
+		watch.close()
+
+		if err != nil && ctx.Err() == nil {
+			errs <- err
+		}
+	}()
+
+	return events, errs, nil
+}
+
+type watch struct {
+	group    string
+	counts   map[EventType]uint64
+	inotify  int
+	cancel   int
+	triggers []*Trigger
+	// fds are the file descriptors to poll: inotify, cancel, then one per trigger
+	fds []int
+}
+
+func (wa *watch) setup(triggers []Trigger) error {
+	var err error
+
+	wa.inotify, err = unix.InotifyInit1(unix.IN_CLOEXEC)
+	if err != nil {
+		return err
+	}
+
+	wa.fds = append(wa.fds, wa.inotify)
+
+	if _, err = unix.InotifyAddWatch(wa.inotify, filepath.Join(wa.group, memoryEventsFile), unix.IN_MODIFY); err != nil {
+		return err
+	}
+
+	wa.cancel, err = unix.Eventfd(0, unix.EFD_CLOEXEC)
+	if err != nil {
+		return err
+	}
+
+	wa.fds = append(wa.fds, wa.cancel)
+
+	for index := range triggers {
+		trigger := triggers[index]
+
+		fd, err := registerTrigger(wa.group, trigger)
+		if err != nil {
+			return err
+		}
+
+		wa.fds = append(wa.fds, fd)
+		wa.triggers = append(wa.triggers, &trigger)
+	}
+
+	return nil
+}
+
+func (wa *watch) close() {
+	for _, fd := range wa.fds {
+		_ = unix.Close(fd)
+	}
+}
+
+func (wa *watch) loop(ctx context.Context, events chan<- Event) error {
+	pollFds := make([]unix.PollFd, len(wa.fds))
+	for index, fd := range wa.fds {
+		pollFds[index] = unix.PollFd{Fd: int32(fd), Events: unix.POLLIN | unix.POLLPRI} //nolint:gosec
+	}
+
+	buffer := make([]byte, inotifyBufferSize)
+
+	for {
+		if _, err := unix.Poll(pollFds, -1); err != nil {
+			if errors.Is(err, unix.EINTR) {
+				continue
+			}
+
+			return err
+		}
+
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		if pollFds[0].Revents&unix.POLLIN != 0 {
+			removed, err := wa.readInotify(buffer)
+			if err != nil || removed {
+				return err
+			}
+
+			if err = wa.sendMemoryEvents(ctx, events); err != nil {
+				return err
+			}
+		}
+
+		for index, trigger := range wa.triggers {
+			revents := pollFds[index+2].Revents
+			if revents&unix.POLLERR != 0 {
+				// The group was removed
+				return nil
+			}
+
+			if revents&unix.POLLPRI != 0 {
+				select {
+				case events <- Event{Type: EventPressure, Trigger: trigger}:
+				case <-ctx.Done():
+					return nil
+				}
+			}
+		}
+	}
+}
+
+// readInotify consumes pending inotify events, and reports whether the watched file is gone.
+func (wa *watch) readInotify(buffer []byte) (bool, error) {
+	count, err := unix.Read(wa.inotify, buffer)
+	if err != nil {
+		return false, err
+	}
+
+	for offset := 0; offset+unix.SizeofInotifyEvent <= count; {
+		event := (*unix.InotifyEvent)(unsafe.Pointer(&buffer[offset])) //nolint:gosec
+		if event.Mask&unix.IN_IGNORED != 0 {
+			return true, nil
+		}
+
+		offset += unix.SizeofInotifyEvent + int(event.Len)
+	}
+
+	return false, nil
+}
+
+func (wa *watch) sendMemoryEvents(ctx context.Context, events chan<- Event) error {
+	counts, err := readMemoryEvents(wa.group)
+	if err != nil {
+		// The group was removed in the meantime
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+
+		return err
+	}
+
+	for _, eventType := range []EventType{EventHigh, EventMax, EventOOM, EventOOMKill} {
+		if counts[eventType] <= wa.counts[eventType] {
+			continue
+		}
+
+		select {
+		case events <- Event{Type: eventType, Count: counts[eventType]}:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+
+	wa.counts = counts
+
+	return nil
+}
+
+func readMemoryEvents(group string) (map[EventType]uint64, error) {
+	file, err := os.Open(filepath.Join(group, memoryEventsFile))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	counts := map[EventType]uint64{}
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		key, value, found := strings.Cut(scanner.Text(), " ")
+		if !found {
+			continue
+		}
+
+		count, err := strconv.ParseUint(strings.TrimSpace(value), 10, 64)
+		if err != nil {
+			return nil, err
+		}
+
+		counts[EventType(key)] = count
+	}
+
+	return counts, scanner.Err()
+}
+
+// registerTrigger opens the PSI file of the trigger resource, and writes the trigger into it.
+// The returned file descriptor gets POLLPRI when the trigger fires.
+func registerTrigger(group string, trigger Trigger) (int, error) {
+	if trigger.Window < minTriggerWindow || trigger.Window > maxTriggerWindow ||
+		trigger.Threshold <= 0 || trigger.Threshold >= trigger.Window {
+		return -1, fmt.Errorf("%w: threshold %s, window %s", ErrInvalidTrigger, trigger.Threshold, trigger.Window)
+	}
+
+	kind := "some"
+	if trigger.Full {
+		kind = "full"
+	}
+
+	fd, err := unix.Open(filepath.Join(group, string(trigger.Resource)+pressureSuffix),
+		unix.O_RDWR|unix.O_NONBLOCK|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return -1, err
+	}
+
+	// The trigger must be written in a single write, and the terminating NUL byte is required
+	value := fmt.Sprintf("%s %d %d\x00", kind, trigger.Threshold.Microseconds(), trigger.Window.Microseconds())
+	if _, err = unix.Write(fd, []byte(value)); err != nil {
+		_ = unix.Close(fd)
+
+		return -1, err
+	}
+
+	return fd, nil
+}
+
+// ReadPressure reads the PSI file of `resource` for the group at `pth`, relative to the cgroup mountpoint.
+func ReadPressure(pth string, resource Resource) (*Pressure, error) {
+	return ReadPressureAt("/", pth, resource)
+}
+
+// ReadPressureAt reads the PSI file of `resource` for the group at `pth`, on the host filesystem mounted at `root`.
+func ReadPressureAt(root string, pth string, resource Resource) (*Pressure, error) {
+	file, err := os.Open(filepath.Join(root, cgroupRoot, pth, string(resource)+pressureSuffix))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	pressure := &Pressure{}
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		data, err := parsePressureData(fields[1:])
+		if err != nil {
+			return nil, err
+		}
+
+		switch fields[0] {
+		case "some":
+			pressure.Some = data
+		case "full":
+			pressure.Full = data
+		}
+	}
+
+	return pressure, scanner.Err()
+}
+
+// parsePressureData parses the fields of a PSI line, eg: avg10=0.12 avg60=0.05 avg300=0.01 total=12345.
+func parsePressureData(fields []string) (*PressureData, error) {
+	data := &PressureData{}
+
+	for _, field := range fields {
+		key, value, _ := strings.Cut(field, "=")
+
+		var err error
+
+		switch key {
+		case "avg10":
+			data.Avg10, err = strconv.ParseFloat(value, 64)
+		case "avg60":
+			data.Avg60, err = strconv.ParseFloat(value, 64)
+		case "avg300":
+			data.Avg300, err = strconv.ParseFloat(value, 64)
+		case "total":
+			var total uint64
+
+			total, err = strconv.ParseUint(value, 10, 64)
+			data.Total = time.Duration(total) * time.Microsecond //nolint:gosec
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return data, nil
+}
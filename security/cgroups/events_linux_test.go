@@ -0,0 +1,106 @@
+/*
+   Copyright Farcloser.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package cgroups_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+
+	"go.farcloser.world/containers/internal/fixtures"
+	"go.farcloser.world/containers/security/cgroups"
+)
+
+const sessionScope = "/user.slice/user-1000.slice/session-2.scope"
+
+func TestReadPressure(t *testing.T) {
+	t.Parallel()
+
+	root := fixtures.Host(fixtures.CgroupV2Systemd)
+
+	pressure, err := cgroups.ReadPressureAt(root, sessionScope, cgroups.MemoryResource)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, pressure, &cgroups.Pressure{
+		Some: &cgroups.PressureData{Avg10: 1.53, Avg60: 0.87, Avg300: 0.25, Total: 4206911 * time.Microsecond},
+		Full: &cgroups.PressureData{Avg10: 0.98, Avg60: 0.45, Avg300: 0.11, Total: 2045512 * time.Microsecond},
+	})
+
+	// No full line for cpu before kernel 5.13
+	pressure, err = cgroups.ReadPressureAt(root, sessionScope, cgroups.CPUResource)
+	assert.NilError(t, err)
+	assert.Equal(t, pressure.Some.Avg60, 0.12)
+	assert.Assert(t, pressure.Full == nil)
+}
+
+func TestWatch(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	group := filepath.Join(root, "sys/fs/cgroup/foo")
+	events := filepath.Join(group, "memory.events")
+
+	assert.NilError(t, os.MkdirAll(group, 0o755))
+	assert.NilError(t, os.WriteFile(events, []byte("low 0\nhigh 2\nmax 0\noom 0\noom_kill 0\n"), 0o644))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	watcher := &cgroups.Watcher{Root: root, Path: "/foo"}
+	eventChan, errChan, err := watcher.Watch(ctx)
+	assert.NilError(t, err)
+
+	// Only new occurrences are sent
+	assert.NilError(t, os.WriteFile(events, []byte("low 0\nhigh 2\nmax 1\noom 1\noom_kill 1\n"), 0o644))
+
+	for _, expected := range []cgroups.Event{
+		{Type: cgroups.EventMax, Count: 1},
+		{Type: cgroups.EventOOM, Count: 1},
+		{Type: cgroups.EventOOMKill, Count: 1},
+	} {
+		select {
+		case event := <-eventChan:
+			assert.DeepEqual(t, event, expected)
+		case err = <-errChan:
+			t.Fatal(err)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for %s", expected.Type)
+		}
+	}
+
+	cancel()
+
+	_, open := <-eventChan
+	assert.Assert(t, !open)
+	assert.NilError(t, <-errChan)
+}
+
+func TestWatchInvalidTrigger(t *testing.T) {
+	t.Parallel()
+
+	root := fixtures.Host(fixtures.CgroupV2Systemd)
+	watcher := &cgroups.Watcher{Root: root, Path: sessionScope, Triggers: []cgroups.Trigger{
+		{Resource: cgroups.MemoryResource, Threshold: 2 * time.Second, Window: time.Second},
+	}}
+
+	_, _, err := watcher.Watch(context.Background())
+	assert.ErrorIs(t, err, cgroups.ErrCannotWatch)
+	assert.ErrorIs(t, err, cgroups.ErrInvalidTrigger)
+}
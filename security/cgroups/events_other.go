@@ -0,0 +1,33 @@
+//go:build !linux
+
+/*
+   Copyright Farcloser.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package cgroups
+
+import "context"
+
+func (*Watcher) Watch(_ context.Context) (<-chan Event, <-chan error, error) {
+	return nil, nil, ErrUnsupported
+}
+
+func ReadPressure(_ string, _ Resource) (*Pressure, error) {
+	return nil, ErrUnsupported
+}
+
+func ReadPressureAt(_ string, _ string, _ Resource) (*Pressure, error) {
+	return nil, ErrUnsupported
+}
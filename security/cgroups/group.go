@@ -0,0 +1,64 @@
+/*
+   Copyright Farcloser.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package cgroups
+
+import (
+	"context"
+	"errors"
+
+	"go.farcloser.world/containers/specs"
+)
+
+var (
+	// ErrUnsupported is returned when creating groups is not supported on the host (non-linux, or cgroup v1).
+	ErrUnsupported = errors.New("cgroup management is not supported on this host")
+
+	ErrInvalidManager    = errors.New("invalid cgroup manager")
+	ErrInvalidGroupName  = errors.New("invalid group name")
+	ErrGroupNotEmpty     = errors.New("group still has members")
+	ErrPIDRequired       = errors.New("a process is required to create a systemd scope")
+	ErrCannotCreateGroup = errors.New("cannot create group")
+	ErrCannotUpdateGroup = errors.New("cannot update group")
+	ErrCannotDeleteGroup = errors.New("cannot delete group")
+	ErrCannotFreezeGroup = errors.New("cannot freeze group")
+	ErrCannotThawGroup   = errors.New("cannot thaw group")
+	ErrCannotKillGroup   = errors.New("cannot kill group")
+)
+
+// GroupManager creates and controls the cgroups of containers.
+// Groups are identified by name: a path relative to the parent group for cgroupfs, and a unit name (ending with
+// .scope or .slice) for systemd.
+type GroupManager interface {
+	// Create creates the group `name`, moves the process `pid` into it if not 0, and applies `resources`.
+	Create(ctx context.Context, name string, pid int, resources *specs.LinuxResources) error
+	// Update applies `resources` to the existing group `name`.
+	Update(ctx context.Context, name string, resources *specs.LinuxResources) error
+	// AddProcess moves the process `pid` into the group `name`.
+	AddProcess(ctx context.Context, name string, pid int) error
+	// Freeze stops all the members of the group `name`, until Thaw is called.
+	Freeze(ctx context.Context, name string) error
+	// Thaw resumes all the members of the group `name`.
+	Thaw(ctx context.Context, name string) error
+	// Kill sends SIGKILL to all the members of the group `name`.
+	Kill(ctx context.Context, name string) error
+	// Delete removes the group `name`, which must have no member left. Deleting a missing group is not an error.
+	Delete(ctx context.Context, name string) error
+	// Path returns the path of the group `name`, relative to the cgroup mountpoint.
+	Path(name string) string
+	// Close releases the resources held by the manager.
+	Close() error
+}
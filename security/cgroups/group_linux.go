@@ -0,0 +1,66 @@
+/*
+   Copyright Farcloser.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package cgroups
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/coreos/go-systemd/v22/dbus"
+)
+
+// NewGroupManager returns the GroupManager implementing `manager` on the host filesystem mounted at `root`.
+// `parent` is the parent group for cgroupfs, and the parent slice for systemd. The systemd manager talks to the
+// system instance of systemd, or to the user instance when not running as root.
+func NewGroupManager(ctx context.Context, manager Manager, root string, parent string) (GroupManager, error) {
+	switch manager {
+	case CgroupfsManager:
+		cfs, err := NewCgroupfs(root, parent)
+		if err != nil {
+			return nil, err
+		}
+
+		return cfs, nil
+	case SystemdManager:
+		if VersionAt(root) != Version2 {
+			return nil, ErrUnsupported
+		}
+
+		connect := dbus.NewSystemConnectionContext
+		if os.Geteuid() != 0 {
+			connect = dbus.NewUserConnectionContext
+		}
+
+		conn, err := connect(ctx)
+		if err != nil {
+			return nil, errors.Join(ErrUnsupported, err)
+		}
+
+		sd, err := NewSystemd(conn, root, parent)
+		if err != nil {
+			conn.Close()
+
+			return nil, errors.Join(ErrUnsupported, err)
+		}
+
+		return sd, nil
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrInvalidManager, manager)
+	}
+}
@@ -0,0 +1,42 @@
+/*
+   Copyright Farcloser.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package cgroups
+
+import "errors"
+
+var ErrCannotReadMemory = errors.New("cannot read the memory of the host")
+
+// MemoryLimit is the memory a group may use, given the limits of its ancestors and the memory of the host.
+type MemoryLimit struct {
+	// Max is the tightest hard limit (memory.max, or memory.limit_in_bytes on cgroup v1), or the memory of the host.
+	Max uint64
+	// High is the tightest throttling threshold (memory.high), or Max if lower. It is Max on cgroup v1.
+	High uint64
+	// Swap is the tightest swap limit (memory.swap.max, or memory.memsw.limit_in_bytes minus the memory limit on
+	// cgroup v1), or the swap of the host.
+	Swap uint64
+	// Group is the group Max is set on, relative to the cgroup mountpoint. It is empty if no group is limited.
+	Group string
+	// HostMemory and HostSwap are the total memory and swap of the host, from /proc/meminfo.
+	HostMemory uint64
+	HostSwap   uint64
+}
+
+// Limited returns whether a group limits the memory below that of the host.
+func (limit *MemoryLimit) Limited() bool {
+	return limit.Group != ""
+}
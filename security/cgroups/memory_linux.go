@@ -0,0 +1,202 @@
+/*
+   Copyright Farcloser.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package cgroups
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/containerd/cgroups/v3"
+)
+
+const (
+	procMemInfoPath     = "/proc/meminfo"
+	memTotalField       = "MemTotal:"
+	swapTotalField      = "SwapTotal:"
+	bytesPerKilo        = 1024
+	memoryLimitFile     = "memory.limit_in_bytes"
+	procPIDCGroupFormat = "/proc/%d/cgroup"
+
+	unlimitedMemory = math.MaxUint64
+	// unlimitedV1Memory is above any actual limit: cgroup v1 reports no limit as PAGE_COUNTER_MAX pages, which is
+	// close to math.MaxInt64 bytes.
+	unlimitedV1Memory = math.MaxInt64 / 2
+)
+
+// EffectiveMemoryLimit returns the memory available to the group at `pth` (relative to the cgroup mountpoint).
+// As with New, "/" stands for the group of the current process.
+func EffectiveMemoryLimit(pth string) (*MemoryLimit, error) {
+	return EffectiveMemoryLimitAt("/", pth)
+}
+
+// EffectiveMemoryLimitForPID returns the memory available to the group of process `pid` (eg: a container).
+func EffectiveMemoryLimitForPID(pid int) (*MemoryLimit, error) {
+	return effectiveMemoryLimit("/", "", fmt.Sprintf(procPIDCGroupFormat, pid))
+}
+
+// EffectiveMemoryLimitAt returns the memory available to the group at `pth`, on the host filesystem mounted at
+// `root`.
+func EffectiveMemoryLimitAt(root string, pth string) (*MemoryLimit, error) {
+	if root == "" {
+		root = "/"
+	}
+
+	if filepath.Clean("/"+pth) == "/" {
+		pth = ""
+	}
+
+	return effectiveMemoryLimit(root, pth, procSelfCGroupPath)
+}
+
+// effectiveMemoryLimit walks up from the group at `pth`, or from the group in the cgroup file `procFile` if empty,
+// and keeps the tightest limits.
+func effectiveMemoryLimit(root string, pth string, procFile string) (*MemoryLimit, error) {
+	hostMemory, hostSwap, err := readMemInfo(root)
+	if err != nil {
+		return nil, err
+	}
+
+	limit := &MemoryLimit{
+		Max:        hostMemory,
+		High:       hostMemory,
+		Swap:       hostSwap,
+		HostMemory: hostMemory,
+		HostSwap:   hostSwap,
+	}
+
+	mountPoint := filepath.Join(root, cgroupRoot)
+	v1 := VersionAt(root) == Version1
+
+	if v1 {
+		if mountPoint = findV1MountPoints(root)[memoryController]; mountPoint == "" {
+			return limit, nil
+		}
+	}
+
+	if pth == "" {
+		pth = groupOf(filepath.Join(root, procFile), v1)
+	}
+
+	// The root group of the host has no limit files, but that of a cgroup namespace does
+	for group := filepath.Clean("/" + pth); ; group = filepath.Dir(group) {
+		dir := filepath.Join(mountPoint, group)
+
+		if v1 {
+			memory, memsw := readLimit(dir, memoryLimitFile), readLimit(dir, memoryMemswLimitFile)
+			limit.tighten(group, memory, unlimitedMemory, unlimitedMemory)
+
+			if memory != unlimitedMemory && memsw != unlimitedMemory && memsw >= memory {
+				limit.tighten(group, unlimitedMemory, unlimitedMemory, memsw-memory)
+			}
+		} else {
+			limit.tighten(group, readLimit(dir, memoryMaxFile), readLimit(dir, memoryHighFile),
+				readLimit(dir, memorySwapMaxFile))
+		}
+
+		if group == "/" {
+			break
+		}
+	}
+
+	limit.High = min(limit.High, limit.Max)
+
+	return limit, nil
+}
+
+// tighten lowers the limits to the given ones. `group` is recorded if Max is lowered.
+func (limit *MemoryLimit) tighten(group string, maximum uint64, high uint64, swap uint64) {
+	if maximum < limit.Max {
+		limit.Max = maximum
+		limit.Group = group
+	}
+
+	limit.High = min(limit.High, high)
+	limit.Swap = min(limit.Swap, swap)
+}
+
+// groupOf returns the memory group from a /proc/<pid>/cgroup file, or "/" if it cannot be read.
+func groupOf(procFile string, v1 bool) string {
+	groups, unified, err := cgroups.ParseCgroupFileUnified(procFile)
+
+	switch {
+	case err != nil:
+		return "/"
+	case v1 && groups[string(memoryController)] != "":
+		return groups[string(memoryController)]
+	case !v1 && unified != "":
+		return unified
+	}
+
+	return "/"
+}
+
+// readLimit reads a limit file, returning unlimitedMemory if it does not exist or is unlimited.
+func readLimit(dir string, file string) uint64 {
+	content, err := os.ReadFile(filepath.Join(dir, file))
+	if err != nil {
+		return unlimitedMemory
+	}
+
+	// "max" on cgroup v2
+	value, err := strconv.ParseUint(strings.TrimSpace(string(content)), 10, 64)
+	if err != nil || value >= unlimitedV1Memory {
+		return unlimitedMemory
+	}
+
+	return value
+}
+
+func readMemInfo(root string) (uint64, uint64, error) {
+	file, err := os.Open(filepath.Join(root, procMemInfoPath))
+	if err != nil {
+		return 0, 0, errors.Join(ErrCannotReadMemory, err)
+	}
+	defer file.Close()
+
+	var memory, swap uint64
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || (fields[0] != memTotalField && fields[0] != swapTotalField) {
+			continue
+		}
+
+		value, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			return 0, 0, errors.Join(ErrCannotReadMemory, err)
+		}
+
+		if fields[0] == memTotalField {
+			memory = value * bytesPerKilo
+		} else {
+			swap = value * bytesPerKilo
+		}
+	}
+
+	if memory == 0 {
+		return 0, 0, fmt.Errorf("%w: no %s in %s", ErrCannotReadMemory, memTotalField, procMemInfoPath)
+	}
+
+	return memory, swap, nil
+}
@@ -0,0 +1,87 @@
+/*
+   Copyright Farcloser.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package cgroups_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gotest.tools/v3/assert"
+
+	"go.farcloser.world/containers/internal/fixtures"
+	"go.farcloser.world/containers/security/cgroups"
+)
+
+func TestEffectiveMemoryLimit(t *testing.T) {
+	t.Parallel()
+
+	// user-1000.slice limits the session, which only sets memory.high
+	limit, err := cgroups.EffectiveMemoryLimitAt(fixtures.Host(fixtures.CgroupV2Systemd), "/")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, limit, &cgroups.MemoryLimit{
+		Max:        8 << 30,
+		High:       6 << 30,
+		Swap:       1 << 30,
+		Group:      "/user.slice/user-1000.slice",
+		HostMemory: 16 << 30,
+		HostSwap:   2 << 30,
+	})
+	assert.Assert(t, limit.Limited())
+
+	// Unlimited on cgroup v1 is reported as PAGE_COUNTER_MAX pages
+	limit, err = cgroups.EffectiveMemoryLimitAt(fixtures.Host(fixtures.CgroupV1Legacy), "/")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, limit, &cgroups.MemoryLimit{
+		Max:        16 << 30,
+		High:       16 << 30,
+		Swap:       2 << 30,
+		HostMemory: 16 << 30,
+		HostSwap:   2 << 30,
+	})
+	assert.Assert(t, !limit.Limited())
+
+	_, err = cgroups.EffectiveMemoryLimitAt(fixtures.Host(fixtures.CgroupV2NoSystemd), "/")
+	assert.ErrorIs(t, err, cgroups.ErrCannotReadMemory)
+}
+
+func TestEffectiveMemoryLimitCgroupNS(t *testing.T) {
+	t.Parallel()
+
+	// In a cgroup namespace, the limits of the container are on the root group
+	root := t.TempDir()
+	write := func(pth string, content string) {
+		assert.NilError(t, os.MkdirAll(filepath.Dir(filepath.Join(root, pth)), 0o755))
+		assert.NilError(t, os.WriteFile(filepath.Join(root, pth), []byte(content), 0o644))
+	}
+
+	write("proc/meminfo", "MemTotal: 4194304 kB\nSwapTotal: 0 kB\n")
+	write("proc/self/cgroup", "0::/\n")
+	write("sys/fs/cgroup/cgroup.controllers", "memory pids\n")
+	write("sys/fs/cgroup/memory.max", "536870912\n")
+	write("sys/fs/cgroup/memory.high", "max\n")
+	write("sys/fs/cgroup/memory.swap.max", "max\n")
+
+	limit, err := cgroups.EffectiveMemoryLimitAt(root, "")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, limit, &cgroups.MemoryLimit{
+		Max:        512 << 20,
+		High:       512 << 20,
+		Group:      "/",
+		HostMemory: 4 << 30,
+	})
+}
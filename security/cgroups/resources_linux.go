@@ -0,0 +1,518 @@
+/*
+   Copyright Farcloser.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package cgroups
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/coreos/go-systemd/v22/dbus"
+	godbus "github.com/godbus/dbus/v5"
+
+	"go.farcloser.world/containers/specs"
+)
+
+const (
+	cgroupControllersFile  = "cgroup.controllers"
+	cgroupTypeFile         = "cgroup.type"
+	memoryMaxFile          = "memory.max"
+	memorySwapMaxFile      = "memory.swap.max"
+	memoryLowFile          = "memory.low"
+	memoryHighFile         = "memory.high"
+	memoryOomGroupFile     = "memory.oom.group"
+	cpuWeightFile          = "cpu.weight"
+	cpuMaxFile             = "cpu.max"
+	cpuMaxBurstFile        = "cpu.max.burst"
+	cpuIdleFile            = "cpu.idle"
+	cpuUclampMinFile       = "cpu.uclamp.min"
+	cpuUclampMaxFile       = "cpu.uclamp.max"
+	cpuSetCPUsFileV2       = "cpuset.cpus"
+	cpuSetMemsFileV2       = "cpuset.mems"
+	cpuSetCPUEffectiveFile = "cpuset.cpus.effective"
+	cpuSetMemEffectiveFile = "cpuset.mems.effective"
+	ioWeightFile           = "io.weight"
+	ioBFQWeightFile        = "io.bfq.weight"
+	ioMaxFile              = "io.max"
+	pidsMaxFile            = "pids.max"
+	rdmaMaxFile            = "rdma.max"
+
+	unlimited     = "max"
+	defaultWeight = "default"
+	devicePath    = "/dev/block"
+
+	defaultCPUPeriod = 100000
+	// systemd rounds CPUQuotaPerSecUSec up to 10ms (1% of a CPU)
+	cpuQuotaGranularity = 10000
+	usecPerSec          = 1000000
+
+	minCPUShares   = 2
+	maxCPUShares   = 262144
+	minBlkioWeight = 10
+	maxBlkioWeight = 1000
+	bitsPerByte    = 8
+)
+
+var (
+	// ErrUnsupportedResource is the warning for resources that cannot be applied on the host, and are ignored.
+	ErrUnsupportedResource = errors.New("resource is not supported and was ignored")
+	// ErrInvalidResources is returned for resources that are not valid, or not available on the host.
+	ErrInvalidResources = errors.New("invalid resources")
+
+	errSwapWithoutLimit = errors.New("swap cannot be limited without a memory limit")
+	errSwapBelowLimit   = errors.New("memory+swap limit must be greater than the memory limit")
+)
+
+// FileWrite is a value to write into a cgroup v2 interface file.
+type FileWrite struct {
+	File  string
+	Value string
+}
+
+// Translation is the cgroup v2 equivalent of specs.LinuxResources.
+type Translation struct {
+	// Files are the interface files to write, in order, to apply the resources to a group.
+	Files []FileWrite
+	// Properties are the systemd unit properties equivalent to the resources systemd manages.
+	Properties []dbus.Property
+	// Delegated are the interface files that have no systemd equivalent, and must be written into the (delegated)
+	// group of the unit, in addition to setting Properties.
+	Delegated []FileWrite
+}
+
+// deviceValue is the dbus a(st) pair used by systemd for per device properties (eg: IOReadBandwidthMax).
+type deviceValue struct {
+	Path  string
+	Value uint64
+}
+
+type translator struct {
+	Translation
+
+	info     Info
+	validate bool
+	warnings []error
+	errs     []error
+}
+
+// Translate converts `resources` into cgroup v2 interface file writes, and their systemd unit properties equivalent.
+// Resources that `info` (as returned by New) reports as unsupported are ignored, with a warning, the same way New
+// reports missing controllers. If `info` is nil, nothing is validated against the host.
+// Resources specific to cgroup v1 (eg: kernel memory, realtime scheduling) are always ignored, with a warning.
+// Device rules are not translated, as device access is controlled with eBPF on cgroup v2.
+func Translate(resources *specs.LinuxResources, info *Info) (*Translation, []error, error) {
+	tr := &translator{}
+	if info != nil {
+		tr.info = *info
+		tr.validate = true
+	}
+
+	if resources != nil {
+		tr.cpu(resources.CPU)
+		tr.memory(resources.Memory)
+		tr.pids(resources.Pids)
+		tr.blockIO(resources.BlockIO)
+		tr.hugepages(resources.HugepageLimits)
+		tr.rdma(resources.Rdma)
+		tr.unified(resources.Unified)
+
+		if resources.Network != nil && (resources.Network.ClassID != nil || len(resources.Network.Priorities) > 0) {
+			tr.ignore("network class and priorities (cgroup v1 only)")
+		}
+	}
+
+	if len(tr.errs) > 0 {
+		return nil, tr.warnings, errors.Join(append([]error{ErrInvalidResources}, tr.errs...)...)
+	}
+
+	return &tr.Translation, tr.warnings, nil
+}
+
+// add records the write of `value` into `file`, and the equivalent systemd properties, if any.
+func (tr *translator) add(file string, value string, properties ...dbus.Property) {
+	write := FileWrite{File: file, Value: value}
+
+	tr.Files = append(tr.Files, write)
+	if len(properties) == 0 {
+		tr.Delegated = append(tr.Delegated, write)
+	} else {
+		tr.Properties = append(tr.Properties, properties...)
+	}
+}
+
+// supported reports whether a feature is supported, warning about it otherwise.
+func (tr *translator) supported(ok bool, feature string) bool {
+	if !tr.validate || ok {
+		return true
+	}
+
+	tr.ignore(feature)
+
+	return false
+}
+
+func (tr *translator) ignore(feature string) {
+	tr.warnings = append(tr.warnings, fmt.Errorf("%w: %s", ErrUnsupportedResource, feature))
+}
+
+func (tr *translator) invalid(format string, args ...any) {
+	tr.errs = append(tr.errs, fmt.Errorf(format, args...))
+}
+
+func (tr *translator) cpu(cpu *specs.LinuxCPU) {
+	if cpu == nil {
+		return
+	}
+
+	if cpu.Shares != nil && *cpu.Shares != 0 && tr.supported(tr.info.CPUShares, "cpu shares") {
+		weight := sharesToWeight(*cpu.Shares)
+		tr.add(cpuWeightFile, strconv.FormatUint(weight, 10), newProperty("CPUWeight", weight))
+	}
+
+	if (cpu.Quota != nil || cpu.Period != nil) && tr.supported(tr.info.CPUCfs, "cpu quota and period") {
+		tr.cpuMax(cpu.Quota, cpu.Period)
+	}
+
+	if cpu.Burst != nil && tr.supported(tr.info.CPUCfs, "cpu burst") {
+		tr.add(cpuMaxBurstFile, strconv.FormatUint(*cpu.Burst, 10))
+	}
+
+	if cpu.Idle != nil && tr.supported(tr.info.CPUIdle, "cpu idle") {
+		tr.add(cpuIdleFile, strconv.FormatInt(*cpu.Idle, 10))
+	}
+
+	if cpu.RealtimeRuntime != nil || cpu.RealtimePeriod != nil {
+		tr.ignore("cpu realtime runtime and period (cgroup v1 only)")
+	}
+
+	if cpu.Cpus != "" && tr.supported(tr.info.Cpuset, "cpuset cpus") {
+		if mask, ok := tr.cpuSet("cpus", cpu.Cpus, tr.info.Cpus); ok {
+			tr.add(cpuSetCPUsFileV2, cpu.Cpus, newProperty("AllowedCPUs", mask))
+		}
+	}
+
+	if cpu.Mems != "" && tr.supported(tr.info.Cpuset, "cpuset mems") {
+		if mask, ok := tr.cpuSet("mems", cpu.Mems, tr.info.Mems); ok {
+			tr.add(cpuSetMemsFileV2, cpu.Mems, newProperty("AllowedMemoryNodes", mask))
+		}
+	}
+}
+
+func (tr *translator) cpuMax(quota *int64, period *uint64) {
+	value := unlimited
+	// USEC_INFINITY: no quota
+	perSec := uint64(math.MaxUint64)
+
+	usecs := uint64(defaultCPUPeriod)
+	if period != nil && *period != 0 {
+		usecs = *period
+	}
+
+	if quota != nil && *quota > 0 {
+		value = strconv.FormatInt(*quota, 10)
+
+		perSec = uint64(*quota) * usecPerSec / usecs
+		if perSec%cpuQuotaGranularity != 0 {
+			perSec = (perSec/cpuQuotaGranularity + 1) * cpuQuotaGranularity
+		}
+	}
+
+	properties := []dbus.Property{newProperty("CPUQuotaPerSecUSec", perSec)}
+	if period != nil && *period != 0 {
+		properties = append(properties, newProperty("CPUQuotaPeriodUSec", usecs))
+	}
+
+	tr.add(cpuMaxFile, value+" "+strconv.FormatUint(usecs, 10), properties...)
+}
+
+// cpuSet validates that `requested` is a subset of `available` (if known), and returns its systemd bitmask.
+func (tr *translator) cpuSet(kind string, requested string, available string) ([]byte, bool) {
+	ids, err := ParseSet(requested)
+	if err != nil {
+		tr.invalid("cpuset %s %q: %w", kind, requested, err)
+
+		return nil, false
+	}
+
+	if tr.validate && available != "" {
+		availableIDs, err := ParseSet(available)
+		if err == nil {
+			for _, id := range ids {
+				if !slices.Contains(availableIDs, id) {
+					tr.invalid("cpuset %s %q: not available (%s)", kind, requested, available)
+
+					return nil, false
+				}
+			}
+		}
+	}
+
+	mask := make([]byte, slices.Max(ids)/bitsPerByte+1)
+	for _, id := range ids {
+		mask[id/bitsPerByte] |= 1 << (id % bitsPerByte)
+	}
+
+	return mask, true
+}
+
+func (tr *translator) memory(memory *specs.LinuxMemory) {
+	if memory == nil {
+		return
+	}
+
+	// Zero means unset for both the limit and the reservation
+	if memory.Limit != nil && *memory.Limit != 0 && tr.supported(tr.info.MemoryLimit, "memory limit") {
+		tr.add(memoryMaxFile, limitValue(*memory.Limit), newProperty("MemoryMax", limitProperty(*memory.Limit)))
+	}
+
+	if memory.Reservation != nil && *memory.Reservation != 0 && tr.supported(tr.info.MemoryReservation, "memory reservation") {
+		tr.add(memoryLowFile, limitValue(*memory.Reservation),
+			newProperty("MemoryLow", limitProperty(*memory.Reservation)))
+	}
+
+	if memory.Swap != nil {
+		var limit int64
+		if memory.Limit != nil {
+			limit = *memory.Limit
+		}
+
+		swap, ok, err := swapToV2(*memory.Swap, limit)
+		if err != nil {
+			tr.invalid("memory swap: %w", err)
+		} else if ok && tr.supported(tr.info.SwapLimit, "memory swap limit") {
+			tr.add(memorySwapMaxFile, limitValue(swap), newProperty("MemorySwapMax", limitProperty(swap)))
+		}
+	}
+
+	if memory.Kernel != nil || memory.KernelTCP != nil {
+		tr.ignore("kernel memory limits (cgroup v1 only)")
+	}
+
+	if memory.Swappiness != nil {
+		tr.ignore("memory swappiness (cgroup v1 only)")
+	}
+
+	if memory.DisableOOMKiller != nil && *memory.DisableOOMKiller {
+		tr.ignore("disabling the OOM killer (cgroup v1 only)")
+	}
+}
+
+func (tr *translator) pids(pids *specs.LinuxPids) {
+	// Zero means unset, while negative values mean no limit
+	if pids == nil || pids.Limit == 0 || !tr.supported(tr.info.PidsLimit, "pids limit") {
+		return
+	}
+
+	tr.add(pidsMaxFile, limitValue(pids.Limit),
+		newProperty("TasksAccounting", true),
+		newProperty("TasksMax", limitProperty(pids.Limit)))
+}
+
+func (tr *translator) blockIO(blockIO *specs.LinuxBlockIO) {
+	if blockIO == nil {
+		return
+	}
+
+	// io.bfq.weight takes cgroup v1 weights (1-1000) as is
+	weightFile := ioWeightFile
+	if tr.info.BlkioWeightBFQ {
+		weightFile = ioBFQWeightFile
+	}
+
+	if blockIO.Weight != nil && tr.supported(tr.info.BlkioWeight, "block io weight") {
+		if weight, ok := tr.blkioWeight(*blockIO.Weight); ok {
+			value := weight
+			if weightFile == ioBFQWeightFile {
+				value = uint64(*blockIO.Weight)
+			}
+
+			tr.add(weightFile, defaultWeight+" "+strconv.FormatUint(value, 10), newProperty("IOWeight", weight))
+		}
+	}
+
+	if blockIO.LeafWeight != nil {
+		tr.ignore("block io leaf weight (cgroup v1 only)")
+	}
+
+	for _, device := range blockIO.WeightDevice {
+		if device.LeafWeight != nil {
+			tr.ignore("block io leaf weight (cgroup v1 only)")
+		}
+
+		if device.Weight == nil || !tr.supported(tr.info.BlkioWeightDevice, "block io weight device") {
+			continue
+		}
+
+		if weight, ok := tr.blkioWeight(*device.Weight); ok {
+			value := weight
+			if weightFile == ioBFQWeightFile {
+				value = uint64(*device.Weight)
+			}
+
+			tr.add(weightFile, deviceID(device.Major, device.Minor)+" "+strconv.FormatUint(value, 10),
+				newProperty("IODeviceWeight", []deviceValue{{devicePathOf(device.Major, device.Minor), weight}}))
+		}
+	}
+
+	for _, throttle := range []struct {
+		devices   []specs.LinuxThrottleDevice
+		supported bool
+		feature   string
+		key       string
+		property  string
+	}{
+		{blockIO.ThrottleReadBpsDevice, tr.info.BlkioReadBpsDevice, "block io read bps", "rbps", "IOReadBandwidthMax"},
+		{blockIO.ThrottleWriteBpsDevice, tr.info.BlkioWriteBpsDevice, "block io write bps", "wbps", "IOWriteBandwidthMax"},
+		{blockIO.ThrottleReadIOPSDevice, tr.info.BlkioReadIOpsDevice, "block io read iops", "riops", "IOReadIOPSMax"},
+		{blockIO.ThrottleWriteIOPSDevice, tr.info.BlkioWriteIOpsDevice, "block io write iops", "wiops", "IOWriteIOPSMax"},
+	} {
+		if len(throttle.devices) == 0 || !tr.supported(throttle.supported, throttle.feature) {
+			continue
+		}
+
+		for _, device := range throttle.devices {
+			tr.add(ioMaxFile,
+				deviceID(device.Major, device.Minor)+" "+throttle.key+"="+strconv.FormatUint(device.Rate, 10),
+				newProperty(throttle.property, []deviceValue{{devicePathOf(device.Major, device.Minor), device.Rate}}))
+		}
+	}
+}
+
+// blkioWeight converts a cgroup v1 blkio weight (10-1000) to a cgroup v2 io weight (1-10000).
+func (tr *translator) blkioWeight(weight uint16) (uint64, bool) {
+	if weight < minBlkioWeight || weight > maxBlkioWeight {
+		tr.invalid("block io weight %d: out of range (%d-%d)", weight, minBlkioWeight, maxBlkioWeight)
+
+		return 0, false
+	}
+
+	return 1 + (uint64(weight)-minBlkioWeight)*9999/990, true //nolint:mnd
+}
+
+func (tr *translator) hugepages(limits []specs.LinuxHugepageLimit) {
+	for _, limit := range limits {
+		tr.add("hugetlb."+limit.Pagesize+".max", strconv.FormatUint(limit.Limit, 10))
+	}
+}
+
+func (tr *translator) rdma(rdma map[string]specs.LinuxRdma) {
+	devices := make([]string, 0, len(rdma))
+	for device := range rdma {
+		devices = append(devices, device)
+	}
+
+	slices.Sort(devices)
+
+	for _, device := range devices {
+		limits := rdma[device]
+		value := device + " hca_handle=" + optionalLimit(limits.HcaHandles) + " hca_object=" +
+			optionalLimit(limits.HcaObjects)
+		tr.add(rdmaMaxFile, value)
+	}
+}
+
+func (tr *translator) unified(unified map[string]string) {
+	files := make([]string, 0, len(unified))
+	for file := range unified {
+		files = append(files, file)
+	}
+
+	slices.Sort(files)
+
+	for _, file := range files {
+		if file != filepath.Base(file) || !strings.Contains(file, ".") {
+			tr.invalid("unified %q: not an interface file", file)
+
+			continue
+		}
+
+		tr.add(file, unified[file])
+	}
+}
+
+// sharesToWeight converts cgroup v1 cpu.shares (2-262144) to cgroup v2 cpu.weight (1-10000).
+// Out of range shares are clamped first, as the kernel does on cgroup v1.
+func sharesToWeight(shares uint64) uint64 {
+	shares = min(max(shares, minCPUShares), maxCPUShares)
+
+	return 1 + ((shares-minCPUShares)*9999)/(maxCPUShares-minCPUShares) //nolint:mnd
+}
+
+// swapToV2 converts a cgroup v1 memory+swap limit into a cgroup v2 swap limit, returning false if swap is unset.
+func swapToV2(swap int64, limit int64) (int64, bool, error) {
+	switch {
+	case limit == -1 && swap == 0:
+		// memory was explicitly set to unlimited, with swap unset: leave swap unlimited as well
+		return -1, true, nil
+	case swap == 0:
+		return 0, false, nil
+	case swap == -1:
+		return -1, true, nil
+	case limit <= 0:
+		return 0, false, errSwapWithoutLimit
+	case swap < limit:
+		return 0, false, fmt.Errorf("%w (%d < %d)", errSwapBelowLimit, swap, limit)
+	}
+
+	// swap == limit means no swap at all
+	return swap - limit, true, nil
+}
+
+func deviceID(major int64, minor int64) string {
+	return strconv.FormatInt(major, 10) + ":" + strconv.FormatInt(minor, 10)
+}
+
+func devicePathOf(major int64, minor int64) string {
+	return devicePath + "/" + deviceID(major, minor)
+}
+
+func optionalLimit(limit *uint32) string {
+	if limit == nil {
+		return unlimited
+	}
+
+	return strconv.FormatUint(uint64(*limit), 10)
+}
+
+// limitValue formats a limit for an interface file, where negative values mean no limit.
+func limitValue(limit int64) string {
+	if limit < 0 {
+		return unlimited
+	}
+
+	return strconv.FormatInt(limit, 10)
+}
+
+// limitProperty converts a limit for a systemd property, where negative values mean no limit (infinity).
+func limitProperty(limit int64) uint64 {
+	if limit < 0 {
+		return math.MaxUint64
+	}
+
+	return uint64(limit)
+}
+
+func newProperty(name string, value any) dbus.Property {
+	return dbus.Property{
+		Name:  name,
+		Value: godbus.MakeVariant(value),
+	}
+}
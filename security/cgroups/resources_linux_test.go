@@ -0,0 +1,231 @@
+/*
+   Copyright Farcloser.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package cgroups_test
+
+import (
+	"math"
+	"testing"
+
+	"gotest.tools/v3/assert"
+
+	"go.farcloser.world/containers/security/cgroups"
+	"go.farcloser.world/containers/specs"
+)
+
+func properties(translation *cgroups.Translation) map[string]any {
+	res := map[string]any{}
+	for _, property := range translation.Properties {
+		res[property.Name] = property.Value.Value()
+	}
+
+	return res
+}
+
+func TestTranslate(t *testing.T) {
+	t.Parallel()
+
+	shares := uint64(2048)
+	quota := int64(150000)
+	period := uint64(100000)
+	limit := int64(512 << 20)
+	swap := int64(-1)
+	weight := uint16(500)
+	handles := uint32(10)
+
+	translation, warnings, err := cgroups.Translate(&specs.LinuxResources{
+		CPU:    &specs.LinuxCPU{Shares: &shares, Quota: &quota, Period: &period, Cpus: "0-2,9", Mems: "0"},
+		Memory: &specs.LinuxMemory{Limit: &limit, Swap: &swap},
+		Pids:   &specs.LinuxPids{Limit: 64},
+		BlockIO: &specs.LinuxBlockIO{
+			Weight: &weight,
+			ThrottleReadBpsDevice: []specs.LinuxThrottleDevice{
+				{LinuxBlockIODevice: specs.LinuxBlockIODevice{Major: 8, Minor: 0}, Rate: 1048576},
+			},
+		},
+		HugepageLimits: []specs.LinuxHugepageLimit{{Pagesize: "2MB", Limit: 4194304}},
+		Rdma:           map[string]specs.LinuxRdma{"mlx4_0": {HcaHandles: &handles}},
+		Unified:        map[string]string{"memory.high": "268435456"},
+	}, nil)
+	assert.NilError(t, err)
+	assert.Equal(t, len(warnings), 0)
+
+	assert.DeepEqual(t, translation.Files, []cgroups.FileWrite{
+		{File: "cpu.weight", Value: "79"},
+		{File: "cpu.max", Value: "150000 100000"},
+		{File: "cpuset.cpus", Value: "0-2,9"},
+		{File: "cpuset.mems", Value: "0"},
+		{File: "memory.max", Value: "536870912"},
+		{File: "memory.swap.max", Value: "max"},
+		{File: "pids.max", Value: "64"},
+		{File: "io.weight", Value: "default 4950"},
+		{File: "io.max", Value: "8:0 rbps=1048576"},
+		{File: "hugetlb.2MB.max", Value: "4194304"},
+		{File: "rdma.max", Value: "mlx4_0 hca_handle=10 hca_object=max"},
+		{File: "memory.high", Value: "268435456"},
+	})
+
+	assert.DeepEqual(t, translation.Delegated, []cgroups.FileWrite{
+		{File: "hugetlb.2MB.max", Value: "4194304"},
+		{File: "rdma.max", Value: "mlx4_0 hca_handle=10 hca_object=max"},
+		{File: "memory.high", Value: "268435456"},
+	})
+
+	props := properties(translation)
+	assert.Equal(t, props["CPUWeight"], uint64(79))
+	assert.Equal(t, props["CPUQuotaPerSecUSec"], uint64(1500000))
+	assert.Equal(t, props["CPUQuotaPeriodUSec"], uint64(100000))
+	// CPUs 0, 1, 2 and 9
+	assert.DeepEqual(t, props["AllowedCPUs"], []byte{0x07, 0x02})
+	assert.Equal(t, props["MemoryMax"], uint64(512<<20))
+	assert.Equal(t, props["MemorySwapMax"], uint64(math.MaxUint64))
+	assert.Equal(t, props["TasksMax"], uint64(64))
+	assert.Equal(t, props["IOWeight"], uint64(4950))
+}
+
+func TestTranslateSwap(t *testing.T) {
+	t.Parallel()
+
+	limit := int64(1 << 30)
+	swap := int64(3 << 30)
+
+	translation, _, err := cgroups.Translate(&specs.LinuxResources{
+		Memory: &specs.LinuxMemory{Limit: &limit, Swap: &swap},
+	}, nil)
+	assert.NilError(t, err)
+	// cgroup v1 swap is memory+swap
+	assert.DeepEqual(t, translation.Files[1], cgroups.FileWrite{File: "memory.swap.max", Value: "2147483648"})
+
+	swap = int64(1 << 20)
+	_, _, err = cgroups.Translate(&specs.LinuxResources{Memory: &specs.LinuxMemory{Limit: &limit, Swap: &swap}}, nil)
+	assert.ErrorIs(t, err, cgroups.ErrInvalidResources)
+
+	_, _, err = cgroups.Translate(&specs.LinuxResources{Memory: &specs.LinuxMemory{Swap: &swap}}, nil)
+	assert.ErrorIs(t, err, cgroups.ErrInvalidResources)
+
+	// memory+swap equal to the memory limit disables swap
+	swap = limit
+	translation, _, err = cgroups.Translate(&specs.LinuxResources{
+		Memory: &specs.LinuxMemory{Limit: &limit, Swap: &swap},
+	}, nil)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, translation.Files[1], cgroups.FileWrite{File: "memory.swap.max", Value: "0"})
+	assert.Equal(t, properties(translation)["MemorySwapMax"], uint64(0))
+}
+
+func TestTranslateMemoryZero(t *testing.T) {
+	t.Parallel()
+
+	zero := int64(0)
+
+	for _, memory := range []*specs.LinuxMemory{
+		{Limit: &zero},
+		{Reservation: &zero},
+		{Limit: &zero, Reservation: &zero},
+	} {
+		translation, _, err := cgroups.Translate(&specs.LinuxResources{Memory: memory}, nil)
+		assert.NilError(t, err)
+		assert.Equal(t, len(translation.Files), 0)
+		assert.Equal(t, len(translation.Properties), 0)
+	}
+}
+
+func TestTranslateShares(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range []struct {
+		shares uint64
+		weight string
+	}{
+		// Zero is unset
+		{shares: 0, weight: ""},
+		// Out of range shares are clamped to 2-262144
+		{shares: 1, weight: "1"},
+		{shares: 2, weight: "1"},
+		{shares: 1024, weight: "39"},
+		{shares: 262144, weight: "10000"},
+		{shares: 1 << 20, weight: "10000"},
+	} {
+		translation, _, err := cgroups.Translate(&specs.LinuxResources{
+			CPU: &specs.LinuxCPU{Shares: &test.shares},
+		}, nil)
+		assert.NilError(t, err)
+
+		if test.weight == "" {
+			assert.Equal(t, len(translation.Files), 0, "shares %d", test.shares)
+
+			continue
+		}
+
+		assert.DeepEqual(t, translation.Files, []cgroups.FileWrite{{File: "cpu.weight", Value: test.weight}})
+	}
+}
+
+func TestTranslatePids(t *testing.T) {
+	t.Parallel()
+
+	// Zero is unset
+	translation, _, err := cgroups.Translate(&specs.LinuxResources{Pids: &specs.LinuxPids{Limit: 0}}, nil)
+	assert.NilError(t, err)
+	assert.Equal(t, len(translation.Files), 0)
+	assert.Equal(t, len(translation.Properties), 0)
+
+	translation, _, err = cgroups.Translate(&specs.LinuxResources{Pids: &specs.LinuxPids{Limit: -1}}, nil)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, translation.Files, []cgroups.FileWrite{{File: "pids.max", Value: "max"}})
+	assert.Equal(t, properties(translation)["TasksMax"], uint64(math.MaxUint64))
+}
+
+func TestTranslateWarnings(t *testing.T) {
+	t.Parallel()
+
+	limit := int64(1 << 30)
+	swap := int64(2 << 30)
+	swappiness := uint64(60)
+	weight := uint16(100)
+	realtime := int64(950000)
+
+	info := &cgroups.Info{}
+	info.MemoryLimit = true
+	info.Cpuset = true
+	info.Cpus = "0-3"
+	info.BlkioWeight = true
+	info.BlkioWeightBFQ = true
+
+	translation, warnings, err := cgroups.Translate(&specs.LinuxResources{
+		CPU:     &specs.LinuxCPU{RealtimeRuntime: &realtime, Cpus: "1"},
+		Memory:  &specs.LinuxMemory{Limit: &limit, Swap: &swap, Swappiness: &swappiness},
+		BlockIO: &specs.LinuxBlockIO{Weight: &weight},
+	}, info)
+	assert.NilError(t, err)
+
+	// No swap accounting, and cgroup v1 only resources
+	assert.Equal(t, len(warnings), 3)
+
+	for _, warning := range warnings {
+		assert.ErrorIs(t, warning, cgroups.ErrUnsupportedResource)
+	}
+
+	assert.DeepEqual(t, translation.Files, []cgroups.FileWrite{
+		{File: "cpuset.cpus", Value: "1"},
+		{File: "memory.max", Value: "1073741824"},
+		// BFQ takes v1 weights as is
+		{File: "io.bfq.weight", Value: "default 100"},
+	})
+
+	_, _, err = cgroups.Translate(&specs.LinuxResources{CPU: &specs.LinuxCPU{Cpus: "4-5"}}, info)
+	assert.ErrorIs(t, err, cgroups.ErrInvalidResources)
+}
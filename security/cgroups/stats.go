@@ -16,9 +16,43 @@
 
 package cgroups
 
-import "github.com/containerd/cgroups/v3/cgroup2/stats"
+import (
+	"time"
 
-type Metrics = stats.Metrics
+	v1stats "github.com/containerd/cgroups/v3/cgroup1/stats"
+	"github.com/containerd/cgroups/v3/cgroup2/stats"
+)
+
+type (
+	Metrics   = stats.Metrics
+	MetricsV1 = v1stats.Metrics
+	PSIStats  = stats.PSIStats
+)
+
+// PressureFromPSI converts the PSI metrics of a group, returning nil if there are none.
+func PressureFromPSI(psi *stats.PSIStats) *Pressure {
+	if psi == nil {
+		return nil
+	}
+
+	return &Pressure{
+		Some: pressureDataFromPSI(psi.GetSome()),
+		Full: pressureDataFromPSI(psi.GetFull()),
+	}
+}
+
+func pressureDataFromPSI(data *stats.PSIData) *PressureData {
+	if data == nil {
+		return nil
+	}
+
+	return &PressureData{
+		Avg10:  data.GetAvg10(),
+		Avg60:  data.GetAvg60(),
+		Avg300: data.GetAvg300(),
+		Total:  time.Duration(data.GetTotal()) * time.Microsecond,
+	}
+}
 
 func CalculateMemUsage(metrics *stats.Metrics) float64 {
 	usage := metrics.GetMemory().GetUsage()
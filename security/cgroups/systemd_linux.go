@@ -0,0 +1,346 @@
+/*
+   Copyright Farcloser.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package cgroups
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/coreos/go-systemd/v22/dbus"
+	godbus "github.com/godbus/dbus/v5"
+	"golang.org/x/sys/unix"
+
+	"go.farcloser.world/containers/specs"
+)
+
+const (
+	defaultSlice = "system.slice"
+	rootSlice    = "-.slice"
+	scopeSuffix  = ".scope"
+	sliceSuffix  = ".slice"
+
+	jobModeReplace = "replace"
+	jobDone        = "done"
+
+	errUnitExists = "org.freedesktop.systemd1.UnitExists"
+	errNoSuchUnit = "org.freedesktop.systemd1.NoSuchUnit"
+
+	managerControlGroup = "ControlGroup"
+)
+
+var ErrSystemdJobFailed = errors.New("systemd job failed")
+
+// SystemdConnection is the part of the systemd D-Bus API used by Systemd.
+// It is implemented by *dbus.Conn, from github.com/coreos/go-systemd/v22/dbus.
+type SystemdConnection interface {
+	StartTransientUnitContext(
+		ctx context.Context, name string, mode string, properties []dbus.Property, ch chan<- string,
+	) (int, error)
+	StopUnitContext(ctx context.Context, name string, mode string, ch chan<- string) (int, error)
+	SetUnitPropertiesContext(ctx context.Context, name string, runtime bool, properties ...dbus.Property) error
+	KillUnitWithTarget(ctx context.Context, name string, target dbus.Who, signal int32) error
+	ResetFailedUnitContext(ctx context.Context, name string) error
+	FreezeUnit(ctx context.Context, unit string) error
+	ThawUnit(ctx context.Context, unit string) error
+	GetManagerProperty(prop string) (string, error)
+	Close()
+}
+
+// Systemd manages groups as transient systemd units (scopes or slices), through D-Bus.
+// Groups are delegated to their owner, so that processes can be added through the filesystem.
+type Systemd struct {
+	// Root is where the host filesystem is mounted, usually "/".
+	Root string
+	// Base is the group of the systemd instance, which slices are relative to: empty for the system instance, and
+	// eg: /user.slice/user-1000.slice/user@1000.service for a user instance.
+	Base string
+	// Slice is the parent slice of the managed units.
+	Slice string
+
+	conn SystemdConnection
+}
+
+// NewSystemd returns a systemd GroupManager for units under `slice` (default to system.slice), talking to systemd
+// over `conn`, on the host filesystem mounted at `root`.
+func NewSystemd(conn SystemdConnection, root string, slice string) (*Systemd, error) {
+	if slice == "" {
+		slice = defaultSlice
+	}
+
+	// Properties come in the GVariant text format, where strings are quoted
+	value, err := conn.GetManagerProperty(managerControlGroup)
+	if err != nil {
+		return nil, err
+	}
+
+	base, err := strconv.Unquote(value)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", err, value)
+	}
+
+	return &Systemd{
+		Root:  root,
+		Base:  base,
+		Slice: slice,
+		conn:  conn,
+	}, nil
+}
+
+// Path returns the group of the unit `name`: scopes are in the parent slice, while slices are placed according to
+// their name (see sliceToPath).
+func (sd *Systemd) Path(name string) string {
+	if strings.HasSuffix(name, sliceSuffix) {
+		return filepath.Join("/", sd.Base, sliceToPath(name))
+	}
+
+	return filepath.Join("/", sd.Base, sliceToPath(sd.Slice), name)
+}
+
+func (sd *Systemd) Create(ctx context.Context, name string, pid int, resources *specs.LinuxResources) error {
+	if err := validateUnitName(name); err != nil {
+		return err
+	}
+
+	translation, _, err := Translate(resources, nil)
+	if err != nil {
+		return errors.Join(ErrCannotCreateGroup, err)
+	}
+
+	properties := []dbus.Property{
+		dbus.PropDescription("cgroup " + name),
+		newProperty("DefaultDependencies", false),
+		newProperty("MemoryAccounting", true),
+		newProperty("CPUAccounting", true),
+		newProperty("IOAccounting", true),
+	}
+
+	// Slices cannot be delegated, and are nested according to their name: Wants= only pulls the parent slice in
+	if strings.HasSuffix(name, sliceSuffix) {
+		if !isChildSlice(sd.Slice, name) {
+			return fmt.Errorf("%w: %q, expected a slice prefixed with %q", ErrInvalidGroupName, name,
+				strings.TrimSuffix(sd.Slice, sliceSuffix)+"-")
+		}
+
+		properties = append(properties, dbus.PropWants(sd.Slice))
+	} else {
+		if pid == 0 {
+			return ErrPIDRequired
+		}
+
+		properties = append(properties, dbus.PropSlice(sd.Slice), newProperty("Delegate", true))
+	}
+
+	if pid != 0 {
+		properties = append(properties, dbus.PropPids(uint32(pid))) //nolint:gosec
+	}
+
+	properties = append(properties, translation.Properties...)
+
+	if err = sd.start(ctx, name, properties); err != nil {
+		return errors.Join(ErrCannotCreateGroup, err)
+	}
+
+	if err = writeFiles(sd.dir(name), translation.Delegated); err != nil {
+		return errors.Join(ErrCannotCreateGroup, err)
+	}
+
+	return nil
+}
+
+func (sd *Systemd) Update(ctx context.Context, name string, resources *specs.LinuxResources) error {
+	if err := validateUnitName(name); err != nil {
+		return err
+	}
+
+	translation, _, err := Translate(resources, nil)
+	if err != nil {
+		return errors.Join(ErrCannotUpdateGroup, err)
+	}
+
+	if err = sd.conn.SetUnitPropertiesContext(ctx, name, true, translation.Properties...); err != nil {
+		return errors.Join(ErrCannotUpdateGroup, err)
+	}
+
+	if err = writeFiles(sd.dir(name), translation.Delegated); err != nil {
+		return errors.Join(ErrCannotUpdateGroup, err)
+	}
+
+	return nil
+}
+
+func (sd *Systemd) AddProcess(_ context.Context, name string, pid int) error {
+	if err := validateUnitName(name); err != nil {
+		return err
+	}
+
+	return writeFile(sd.dir(name), cgroupProcsFile, strconv.Itoa(pid))
+}
+
+func (sd *Systemd) Freeze(ctx context.Context, name string) error {
+	if err := validateUnitName(name); err != nil {
+		return err
+	}
+
+	if err := sd.conn.FreezeUnit(ctx, name); err != nil {
+		return errors.Join(ErrCannotFreezeGroup, err)
+	}
+
+	return nil
+}
+
+func (sd *Systemd) Thaw(ctx context.Context, name string) error {
+	if err := validateUnitName(name); err != nil {
+		return err
+	}
+
+	if err := sd.conn.ThawUnit(ctx, name); err != nil {
+		return errors.Join(ErrCannotThawGroup, err)
+	}
+
+	return nil
+}
+
+func (sd *Systemd) Kill(ctx context.Context, name string) error {
+	if err := validateUnitName(name); err != nil {
+		return err
+	}
+
+	if err := sd.conn.KillUnitWithTarget(ctx, name, dbus.All, int32(unix.SIGKILL)); err != nil {
+		return errors.Join(ErrCannotKillGroup, err)
+	}
+
+	return nil
+}
+
+func (sd *Systemd) Delete(ctx context.Context, name string) error {
+	if err := validateUnitName(name); err != nil {
+		return err
+	}
+
+	statusChan := make(chan string, 1)
+
+	if _, err := sd.conn.StopUnitContext(ctx, name, jobModeReplace, statusChan); err != nil {
+		// Deleting a group that does not exist is not an error, as with cgroupfs
+		if isDBusError(err, errNoSuchUnit) {
+			return nil
+		}
+
+		return errors.Join(ErrCannotDeleteGroup, err)
+	}
+
+	if err := waitJob(ctx, name, statusChan); err != nil {
+		return errors.Join(ErrCannotDeleteGroup, err)
+	}
+
+	// Leftover failed units would prevent reusing the name
+	_ = sd.conn.ResetFailedUnitContext(ctx, name)
+
+	return nil
+}
+
+// Close closes the D-Bus connection.
+func (sd *Systemd) Close() error {
+	sd.conn.Close()
+
+	return nil
+}
+
+func (sd *Systemd) dir(name string) string {
+	return filepath.Join(sd.Root, cgroupRoot, sd.Path(name))
+}
+
+// start starts the transient unit, retrying once if a failed unit with the same name is still around.
+func (sd *Systemd) start(ctx context.Context, name string, properties []dbus.Property) error {
+	statusChan := make(chan string, 1)
+
+	_, err := sd.conn.StartTransientUnitContext(ctx, name, jobModeReplace, properties, statusChan)
+	if err != nil && isDBusError(err, errUnitExists) {
+		_ = sd.conn.ResetFailedUnitContext(ctx, name)
+		_, err = sd.conn.StartTransientUnitContext(ctx, name, jobModeReplace, properties, statusChan)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if err = waitJob(ctx, name, statusChan); err != nil {
+		_ = sd.conn.ResetFailedUnitContext(ctx, name)
+
+		return err
+	}
+
+	return nil
+}
+
+func waitJob(ctx context.Context, name string, statusChan <-chan string) error {
+	select {
+	case status := <-statusChan:
+		if status != jobDone {
+			return fmt.Errorf("%w for unit %q: %s", ErrSystemdJobFailed, name, status)
+		}
+
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func isDBusError(err error, name string) bool {
+	var dbusErr godbus.Error
+
+	return errors.As(err, &dbusErr) && dbusErr.Name == name
+}
+
+func validateUnitName(name string) error {
+	if strings.Contains(name, "/") ||
+		(!strings.HasSuffix(name, scopeSuffix) && !strings.HasSuffix(name, sliceSuffix)) ||
+		name == scopeSuffix || name == sliceSuffix {
+		return fmt.Errorf("%w: %q, expected a .scope or .slice unit", ErrInvalidGroupName, name)
+	}
+
+	return nil
+}
+
+// isChildSlice tells whether the slice `name` is nested in the slice `parent`, according to their names.
+func isChildSlice(parent string, name string) bool {
+	return parent == rootSlice || strings.HasPrefix(name, strings.TrimSuffix(parent, sliceSuffix)+"-")
+}
+
+// sliceToPath converts a slice name to its path: slices are nested according to dashes in their name
+// (eg: a-b.slice is a.slice/a-b.slice).
+func sliceToPath(slice string) string {
+	if slice == rootSlice {
+		return ""
+	}
+
+	if !strings.HasSuffix(slice, sliceSuffix) {
+		return slice
+	}
+
+	pth := ""
+	parts := strings.Split(strings.TrimSuffix(slice, sliceSuffix), "-")
+
+	for i := range parts {
+		pth = filepath.Join(pth, strings.Join(parts[:i+1], "-")+sliceSuffix)
+	}
+
+	return pth
+}
@@ -0,0 +1,400 @@
+/*
+   Copyright Farcloser.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package cgroups_test
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/coreos/go-systemd/v22/dbus"
+	godbus "github.com/godbus/dbus/v5"
+	"gotest.tools/v3/assert"
+
+	"go.farcloser.world/containers/security/cgroups"
+	"go.farcloser.world/containers/specs"
+)
+
+const (
+	systemdName      = "org.freedesktop.systemd1"
+	systemdPath      = godbus.ObjectPath("/org/freedesktop/systemd1")
+	systemdInterface = "org.freedesktop.systemd1.Manager"
+	userManagerGroup = "/user.slice/user-1000.slice/user@1000.service"
+)
+
+// startBus starts a private bus, and returns a function dialing it.
+func startBus(t *testing.T) func() (*godbus.Conn, error) {
+	t.Helper()
+
+	daemon, err := exec.LookPath("dbus-daemon")
+	if err != nil {
+		t.Skip("dbus-daemon is not available")
+	}
+
+	cmd := exec.Command(daemon, "--session", "--nofork", "--print-address",
+		"--address=unix:path="+filepath.Join(t.TempDir(), "bus"))
+	stdout, err := cmd.StdoutPipe()
+	assert.NilError(t, err)
+	assert.NilError(t, cmd.Start())
+
+	t.Cleanup(func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	})
+
+	address, err := bufio.NewReader(stdout).ReadString('\n')
+	assert.NilError(t, err)
+
+	return func() (*godbus.Conn, error) {
+		conn, err := godbus.Dial(strings.TrimSpace(address))
+		if err != nil {
+			return nil, err
+		}
+
+		if err = conn.Auth(nil); err == nil {
+			err = conn.Hello()
+		}
+
+		if err != nil {
+			conn.Close()
+
+			return nil, err
+		}
+
+		return conn, nil
+	}
+}
+
+type unitProperty struct {
+	Name  string
+	Value godbus.Variant
+}
+
+type unitAuxiliary struct {
+	Name       string
+	Properties []unitProperty
+}
+
+// fakeSystemd implements the part of the systemd D-Bus API used by Systemd, as a service on a private bus.
+// It creates the group of the units it starts on a host root, slices being placed according to their name.
+type fakeSystemd struct {
+	mu         sync.Mutex
+	conn       *godbus.Conn
+	mountpoint string
+	jobs       uint32
+	slices     map[string]string
+	units      map[string][]unitProperty
+	failed     map[string]bool
+	frozen     map[string]bool
+	killed     map[string]int32
+}
+
+// newFakeSystemd registers a fake user instance of systemd on the bus, knowing about the slices in `slices` (by
+// name, with their path relative to the group of the instance).
+func newFakeSystemd(t *testing.T, dial func() (*godbus.Conn, error), slices map[string]string) *fakeSystemd {
+	t.Helper()
+
+	conn, err := dial()
+	assert.NilError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	fake := &fakeSystemd{
+		conn:       conn,
+		mountpoint: filepath.Join(t.TempDir(), "sys/fs/cgroup"),
+		slices:     slices,
+		units:      map[string][]unitProperty{},
+		failed:     map[string]bool{},
+		frozen:     map[string]bool{},
+		killed:     map[string]int32{},
+	}
+
+	assert.NilError(t, conn.Export(fake, systemdPath, systemdInterface))
+	assert.NilError(t, conn.ExportMethodTable(map[string]any{
+		"Get": func(iface string, property string) (godbus.Variant, *godbus.Error) {
+			if iface != systemdInterface || property != "ControlGroup" {
+				return godbus.Variant{}, godbus.MakeFailedError(fmt.Errorf("unknown property %s.%s", iface, property))
+			}
+
+			return godbus.MakeVariant(userManagerGroup), nil
+		},
+	}, systemdPath, "org.freedesktop.DBus.Properties"))
+
+	reply, err := conn.RequestName(systemdName, godbus.NameFlagDoNotQueue)
+	assert.NilError(t, err)
+	assert.Equal(t, reply, godbus.RequestNameReplyPrimaryOwner)
+
+	return fake
+}
+
+// dir returns the group of a unit: slices are nested in the slice named after them, minus their last dash.
+func (fake *fakeSystemd) dir(name string, properties []unitProperty) (string, bool) {
+	parent := ""
+
+	if strings.HasSuffix(name, ".slice") {
+		if index := strings.LastIndex(name, "-"); index > 0 {
+			parent = name[:index] + ".slice"
+		}
+	} else {
+		for _, property := range properties {
+			if property.Name == "Slice" {
+				parent, _ = property.Value.Value().(string)
+			}
+		}
+	}
+
+	pth, ok := fake.slices[parent]
+	if !ok {
+		return "", false
+	}
+
+	fake.slices[name] = filepath.Join(pth, name)
+
+	return filepath.Join(fake.mountpoint, userManagerGroup, pth, name), true
+}
+
+// job emits the completion of a new job for `unit`.
+func (fake *fakeSystemd) job(unit string) (godbus.ObjectPath, *godbus.Error) {
+	fake.jobs++
+	job := godbus.ObjectPath(fmt.Sprintf("%s/job/%d", systemdPath, fake.jobs))
+
+	if err := fake.conn.Emit(systemdPath, systemdInterface+".JobRemoved", fake.jobs, job, unit, "done"); err != nil {
+		return "", godbus.MakeFailedError(err)
+	}
+
+	return job, nil
+}
+
+func (fake *fakeSystemd) StartTransientUnit(
+	name string, _ string, properties []unitProperty, _ []unitAuxiliary,
+) (godbus.ObjectPath, *godbus.Error) {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	if _, ok := fake.units[name]; ok || fake.failed[name] {
+		return "", godbus.NewError("org.freedesktop.systemd1.UnitExists", []any{name})
+	}
+
+	dir, ok := fake.dir(name, properties)
+	if !ok {
+		return "", godbus.NewError("org.freedesktop.systemd1.NoSuchUnit", []any{name})
+	}
+
+	if err := createGroup(dir); err != nil {
+		return "", godbus.MakeFailedError(err)
+	}
+
+	fake.units[name] = properties
+
+	return fake.job(name)
+}
+
+func (fake *fakeSystemd) StopUnit(name string, _ string) (godbus.ObjectPath, *godbus.Error) {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	if _, ok := fake.units[name]; !ok {
+		return "", godbus.NewError("org.freedesktop.systemd1.NoSuchUnit", []any{name})
+	}
+
+	delete(fake.units, name)
+
+	if err := os.RemoveAll(filepath.Join(fake.mountpoint, userManagerGroup, fake.slices["-.slice"], name)); err != nil {
+		return "", godbus.MakeFailedError(err)
+	}
+
+	return fake.job(name)
+}
+
+func (fake *fakeSystemd) SetUnitProperties(name string, _ bool, properties []unitProperty) *godbus.Error {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	fake.units[name] = append(fake.units[name], properties...)
+
+	return nil
+}
+
+func (fake *fakeSystemd) KillUnit(name string, _ string, signal int32) *godbus.Error {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	if _, ok := fake.units[name]; !ok {
+		return godbus.NewError("org.freedesktop.systemd1.NoSuchUnit", []any{name})
+	}
+
+	fake.killed[name] = signal
+
+	return nil
+}
+
+func (fake *fakeSystemd) ResetFailedUnit(name string) *godbus.Error {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	delete(fake.failed, name)
+
+	return nil
+}
+
+func (fake *fakeSystemd) FreezeUnit(name string) *godbus.Error {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	if _, ok := fake.units[name]; !ok {
+		return godbus.NewError("org.freedesktop.systemd1.NoSuchUnit", []any{name})
+	}
+
+	fake.frozen[name] = true
+
+	return nil
+}
+
+func (fake *fakeSystemd) ThawUnit(name string) *godbus.Error {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	if _, ok := fake.units[name]; !ok {
+		return godbus.NewError("org.freedesktop.systemd1.NoSuchUnit", []any{name})
+	}
+
+	fake.frozen[name] = false
+
+	return nil
+}
+
+func (fake *fakeSystemd) isFrozen(unit string) bool {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	return fake.frozen[unit]
+}
+
+func (fake *fakeSystemd) killedWith(unit string) int32 {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	return fake.killed[unit]
+}
+
+func (fake *fakeSystemd) property(unit string, name string) any {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	var value any
+
+	for _, property := range fake.units[unit] {
+		if property.Name == name {
+			value = property.Value.Value()
+		}
+	}
+
+	return value
+}
+
+func TestSystemd(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	dial := startBus(t)
+	fake := newFakeSystemd(t, dial, map[string]string{
+		"-.slice":                  "",
+		"machine.slice":            "machine.slice",
+		"machine-containers.slice": "machine.slice/machine-containers.slice",
+	})
+	root := filepath.Dir(filepath.Dir(filepath.Dir(fake.mountpoint)))
+
+	conn, err := dbus.NewConnection(dial)
+	assert.NilError(t, err)
+
+	manager, err := cgroups.NewSystemd(conn, root, "machine-containers.slice")
+	assert.NilError(t, err)
+	// Groups are relative to the group of the user instance
+	assert.Equal(t, manager.Base, userManagerGroup)
+	assert.Equal(t, manager.Path("foo.scope"), userManagerGroup+"/machine.slice/machine-containers.slice/foo.scope")
+	assert.Equal(t, manager.Path("machine-containers-pod.slice"),
+		userManagerGroup+"/machine.slice/machine-containers.slice/machine-containers-pod.slice")
+
+	limit := int64(1 << 30)
+	// A failed unit with the same name is reset
+	fake.mu.Lock()
+	fake.failed["foo.scope"] = true
+	fake.mu.Unlock()
+
+	err = manager.Create(ctx, "foo.scope", 42, &specs.LinuxResources{
+		Memory: &specs.LinuxMemory{Limit: &limit},
+		Pids:   &specs.LinuxPids{Limit: 100},
+	})
+	assert.NilError(t, err)
+	assert.Equal(t, fake.property("foo.scope", "Slice"), "machine-containers.slice")
+	assert.Equal(t, fake.property("foo.scope", "Delegate"), true)
+	assert.DeepEqual(t, fake.property("foo.scope", "PIDs"), []uint32{42})
+	assert.Equal(t, fake.property("foo.scope", "MemoryMax"), uint64(1<<30))
+	assert.Equal(t, fake.property("foo.scope", "TasksMax"), uint64(100))
+
+	assert.ErrorIs(t, manager.Create(ctx, "foo.scope", 42, nil), cgroups.ErrCannotCreateGroup)
+	assert.ErrorIs(t, manager.Create(ctx, "bar.scope", 0, nil), cgroups.ErrPIDRequired)
+	assert.ErrorIs(t, manager.Create(ctx, "bar", 42, nil), cgroups.ErrInvalidGroupName)
+
+	shares := uint64(512)
+	assert.NilError(t, manager.Update(ctx, "foo.scope", &specs.LinuxResources{CPU: &specs.LinuxCPU{Shares: &shares}}))
+	assert.Equal(t, fake.property("foo.scope", "CPUWeight"), uint64(20))
+
+	// Delegated groups are joined through the filesystem
+	assert.NilError(t, manager.AddProcess(ctx, "foo.scope", 43))
+	content, err := os.ReadFile(filepath.Join(root, "sys/fs/cgroup", manager.Path("foo.scope"), "cgroup.procs"))
+	assert.NilError(t, err)
+	assert.Equal(t, string(content), "43")
+	assert.ErrorIs(t, manager.AddProcess(ctx, "../foo.scope", 43), cgroups.ErrInvalidGroupName)
+
+	assert.NilError(t, manager.Freeze(ctx, "foo.scope"))
+	assert.Assert(t, fake.isFrozen("foo.scope"))
+	assert.NilError(t, manager.Thaw(ctx, "foo.scope"))
+	assert.Assert(t, !fake.isFrozen("foo.scope"))
+
+	assert.NilError(t, manager.Kill(ctx, "foo.scope"))
+	assert.Equal(t, fake.killedWith("foo.scope"), int32(9))
+
+	// Unknown units cannot be controlled, while deleting them is a no-op
+	assert.ErrorIs(t, manager.Freeze(ctx, "bar.scope"), cgroups.ErrCannotFreezeGroup)
+	assert.ErrorIs(t, manager.Thaw(ctx, "bar.scope"), cgroups.ErrCannotThawGroup)
+	assert.ErrorIs(t, manager.Kill(ctx, "bar.scope"), cgroups.ErrCannotKillGroup)
+
+	for _, name := range []string{"../foo.scope", "foo", ".scope"} {
+		assert.ErrorIs(t, manager.Freeze(ctx, name), cgroups.ErrInvalidGroupName)
+		assert.ErrorIs(t, manager.Thaw(ctx, name), cgroups.ErrInvalidGroupName)
+		assert.ErrorIs(t, manager.Kill(ctx, name), cgroups.ErrInvalidGroupName)
+		assert.ErrorIs(t, manager.Delete(ctx, name), cgroups.ErrInvalidGroupName)
+	}
+
+	assert.NilError(t, manager.Delete(ctx, "foo.scope"))
+	assert.NilError(t, manager.Delete(ctx, "foo.scope"))
+
+	// Slices need no process, and are named after their parent
+	assert.NilError(t, manager.Create(ctx, "machine-containers-pod.slice", 0, nil))
+	assert.DeepEqual(t, fake.property("machine-containers-pod.slice", "Wants"), []string{"machine-containers.slice"})
+	_, err = os.Stat(filepath.Join(root, "sys/fs/cgroup", manager.Path("machine-containers-pod.slice")))
+	assert.NilError(t, err)
+	assert.ErrorIs(t, manager.Create(ctx, "pod.slice", 0, nil), cgroups.ErrInvalidGroupName)
+
+	assert.NilError(t, manager.Close())
+}
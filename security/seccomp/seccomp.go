@@ -15,3 +15,92 @@
 */
 
 package seccomp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/containerd/containerd/v2/core/containers"
+	"github.com/containerd/containerd/v2/pkg/oci"
+	"github.com/opencontainers/runtime-spec/specs-go"
+)
+
+var (
+	ErrCannotLoadProfile   = errors.New("cannot load seccomp profile")
+	ErrCannotDecodeProfile = errors.New("cannot decode seccomp profile")
+	ErrUnsupported         = errors.New("the default seccomp profile is only available on linux")
+)
+
+// LoadProfile reads the seccomp profile at path `profile` into the spec.
+func LoadProfile(spec *specs.Spec, profile string) error {
+	if spec.Linux == nil {
+		spec.Linux = &specs.Linux{}
+	}
+
+	spec.Linux.Seccomp = &specs.LinuxSeccomp{}
+
+	f, err := os.ReadFile(profile)
+	if err != nil {
+		return errors.Join(fmt.Errorf("%w %q", ErrCannotLoadProfile, profile), err)
+	}
+
+	if err = json.Unmarshal(f, spec.Linux.Seccomp); err != nil {
+		return errors.Join(fmt.Errorf("%w %q", ErrCannotDecodeProfile, profile), err)
+	}
+
+	return nil
+}
+
+// WithProfile returns a SpecOpts that loads the seccomp profile at path `profile` into the spec.
+func WithProfile(profile string) oci.SpecOpts {
+	return func(_ context.Context, _ oci.Client, _ *containers.Container, s *specs.Spec) error {
+		return LoadProfile(s, profile)
+	}
+}
+
+// Merge returns a new profile made of `base`, amended by `overlay`.
+// Default action, errno and listener settings of `overlay` take precedence when set, architectures and flags
+// are combined, and the syscalls rules of `overlay` are appended after the ones of `base`.
+func Merge(base *specs.LinuxSeccomp, overlay *specs.LinuxSeccomp) *specs.LinuxSeccomp {
+	res := &specs.LinuxSeccomp{}
+	if base != nil {
+		*res = *base
+		res.Architectures = slices.Clone(base.Architectures)
+		res.Flags = slices.Clone(base.Flags)
+		res.Syscalls = slices.Clone(base.Syscalls)
+	}
+
+	if overlay == nil {
+		return res
+	}
+
+	if overlay.DefaultAction != "" {
+		res.DefaultAction = overlay.DefaultAction
+		res.DefaultErrnoRet = overlay.DefaultErrnoRet
+	}
+
+	if overlay.ListenerPath != "" {
+		res.ListenerPath = overlay.ListenerPath
+		res.ListenerMetadata = overlay.ListenerMetadata
+	}
+
+	for _, arch := range overlay.Architectures {
+		if !slices.Contains(res.Architectures, arch) {
+			res.Architectures = append(res.Architectures, arch)
+		}
+	}
+
+	for _, flag := range overlay.Flags {
+		if !slices.Contains(res.Flags, flag) {
+			res.Flags = append(res.Flags, flag)
+		}
+	}
+
+	res.Syscalls = append(res.Syscalls, overlay.Syscalls...)
+
+	return res
+}
@@ -17,35 +17,67 @@
 package seccomp
 
 import (
-	"encoding/json"
-	"errors"
-	"fmt"
+	"bufio"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/containerd/containerd/v2/contrib/seccomp"
+	kernel "github.com/containerd/containerd/v2/pkg/seccomp"
 	"github.com/opencontainers/runtime-spec/specs-go"
 )
 
-var (
-	ErrCannotLoadProfile   = errors.New("cannot load seccomp profile")
-	ErrCannotDecodeProfile = errors.New("cannot decode seccomp profile")
+const (
+	procStatusPath   = "/proc/self/status"
+	actionsAvailPath = "/proc/sys/kernel/seccomp/actions_avail"
+	seccompField     = "Seccomp:"
 )
 
-func LoadProfile(spec *specs.Spec, profile string) error {
-	spec.Linux.Seccomp = &specs.LinuxSeccomp{}
+// Supported checks whether the running kernel supports seccomp filters.
+func Supported() bool {
+	return kernel.IsEnabled()
+}
+
+// SupportedAt checks whether seccomp filters are supported, according to the host filesystem mounted at `root`.
+// For the running host ("/"), this is the same as Supported, which asks the kernel directly.
+// Otherwise, procfs must report seccomp (CONFIG_SECCOMP) and the available filter actions (CONFIG_SECCOMP_FILTER,
+// kernel 4.14 and later).
+func SupportedAt(root string) bool {
+	if root == "" || filepath.Clean(root) == "/" {
+		return Supported()
+	}
+
+	if _, err := os.Stat(filepath.Join(root, actionsAvailPath)); err != nil {
+		return false
+	}
 
-	f, err := os.ReadFile(profile)
+	file, err := os.Open(filepath.Join(root, procStatusPath))
 	if err != nil {
-		return errors.Join(fmt.Errorf("%w %q", ErrCannotLoadProfile, profile), err)
+		return false
 	}
+	defer file.Close()
 
-	if err = json.Unmarshal(f, spec.Linux.Seccomp); err != nil {
-		return errors.Join(fmt.Errorf("%w %q", ErrCannotDecodeProfile, profile), err)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if strings.HasPrefix(scanner.Text(), seccompField) {
+			return true
+		}
 	}
 
-	return nil
+	return false
 }
 
+// DefaultProfileFor returns the default seccomp profile, computed for the spec capabilities.
+// It never fails on Linux.
+func DefaultProfileFor(s *specs.Spec) (*specs.LinuxSeccomp, error) {
+	return seccomp.DefaultProfile(s), nil
+}
+
+// LoadDefaultProfile sets the default seccomp profile, computed for the spec capabilities, into the spec.
 func LoadDefaultProfile(s *specs.Spec) {
+	if s.Linux == nil {
+		s.Linux = &specs.Linux{}
+	}
+
 	s.Linux.Seccomp = seccomp.DefaultProfile(s)
 }
@@ -0,0 +1,47 @@
+/*
+   Copyright Farcloser.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package seccomp_test
+
+import (
+	"testing"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"gotest.tools/v3/assert"
+
+	"go.farcloser.world/containers/internal/fixtures"
+	"go.farcloser.world/containers/security/seccomp"
+)
+
+func TestSupportedAt(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, seccomp.SupportedAt("/"), seccomp.Supported())
+	assert.Assert(t, seccomp.SupportedAt(fixtures.Host(fixtures.CgroupV2Rootless)))
+	// Neither the actions_avail sysctl nor Seccomp in /proc/self/status (pre 4.14 kernel)
+	assert.Assert(t, !seccomp.SupportedAt(fixtures.Host(fixtures.CgroupV1Legacy)))
+}
+
+func TestDefaultProfileFor(t *testing.T) {
+	t.Parallel()
+
+	profile, err := seccomp.DefaultProfileFor(&specs.Spec{
+		Process: &specs.Process{Capabilities: &specs.LinuxCapabilities{}},
+	})
+	assert.NilError(t, err)
+	assert.Assert(t, profile != nil)
+	assert.Assert(t, len(profile.Syscalls) > 0)
+}
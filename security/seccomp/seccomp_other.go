@@ -0,0 +1,35 @@
+//go:build !linux
+
+/*
+   Copyright Farcloser.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package seccomp
+
+import "github.com/opencontainers/runtime-spec/specs-go"
+
+func Supported() bool {
+	return false
+}
+
+func SupportedAt(_ string) bool {
+	return false
+}
+
+// DefaultProfileFor returns ErrUnsupported, as the default profile depends on the host architecture, and is only
+// available on Linux. Use LoadProfile with an explicit profile instead.
+func DefaultProfileFor(_ *specs.Spec) (*specs.LinuxSeccomp, error) {
+	return nil, ErrUnsupported
+}
@@ -0,0 +1,75 @@
+/*
+   Copyright Farcloser.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package seccomp_test
+
+import (
+	"testing"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"gotest.tools/v3/assert"
+
+	"go.farcloser.world/containers/security/seccomp"
+)
+
+func TestLoadProfile(t *testing.T) {
+	t.Parallel()
+
+	spec := &specs.Spec{}
+	assert.NilError(t, seccomp.LoadProfile(spec, "testdata/profile.json"))
+	assert.Equal(t, spec.Linux.Seccomp.DefaultAction, specs.ActErrno)
+	assert.Equal(t, len(spec.Linux.Seccomp.Syscalls), 1)
+
+	assert.ErrorIs(t, seccomp.LoadProfile(spec, "testdata/missing.json"), seccomp.ErrCannotLoadProfile)
+	assert.ErrorIs(t, seccomp.LoadProfile(spec, "seccomp_test.go"), seccomp.ErrCannotDecodeProfile)
+}
+
+func TestMerge(t *testing.T) {
+	t.Parallel()
+
+	base := &specs.LinuxSeccomp{
+		DefaultAction: specs.ActErrno,
+		Architectures: []specs.Arch{specs.ArchX86_64},
+		Syscalls: []specs.LinuxSyscall{
+			{Names: []string{"read"}, Action: specs.ActAllow},
+		},
+	}
+
+	overlay := &specs.LinuxSeccomp{
+		Architectures: []specs.Arch{specs.ArchX86_64, specs.ArchAARCH64},
+		Flags:         []specs.LinuxSeccompFlag{specs.LinuxSeccompFlagLog},
+		Syscalls: []specs.LinuxSyscall{
+			{Names: []string{"ptrace"}, Action: specs.ActAllow},
+		},
+	}
+
+	merged := seccomp.Merge(base, overlay)
+	assert.Equal(t, merged.DefaultAction, specs.ActErrno)
+	assert.DeepEqual(t, merged.Architectures, []specs.Arch{specs.ArchX86_64, specs.ArchAARCH64})
+	assert.DeepEqual(t, merged.Flags, []specs.LinuxSeccompFlag{specs.LinuxSeccompFlagLog})
+	assert.DeepEqual(t, merged.Syscalls, []specs.LinuxSyscall{
+		{Names: []string{"read"}, Action: specs.ActAllow},
+		{Names: []string{"ptrace"}, Action: specs.ActAllow},
+	})
+
+	// Inputs are left untouched
+	assert.Equal(t, len(base.Syscalls), 1)
+	assert.Equal(t, len(base.Architectures), 1)
+
+	overlay.DefaultAction = specs.ActKillProcess
+	assert.Equal(t, seccomp.Merge(base, overlay).DefaultAction, specs.ActKillProcess)
+	assert.DeepEqual(t, seccomp.Merge(nil, nil), &specs.LinuxSeccomp{})
+}
@@ -38,15 +38,21 @@ type (
 	Hook    = runtime.Hook
 	Hooks   = runtime.Hooks
 
-	Linux             = runtime.Linux
-	Windows           = runtime.Windows
-	LinuxResources    = runtime.LinuxResources
-	LinuxBlockIO      = runtime.LinuxBlockIO
-	LinuxCPU          = runtime.LinuxCPU
-	LinuxMemory       = runtime.LinuxMemory
-	LinuxPids         = runtime.LinuxPids
-	LinuxCapabilities = runtime.LinuxCapabilities
-	LinuxNamespace    = runtime.LinuxNamespace
+	Linux               = runtime.Linux
+	Windows             = runtime.Windows
+	LinuxResources      = runtime.LinuxResources
+	LinuxBlockIO        = runtime.LinuxBlockIO
+	LinuxBlockIODevice  = runtime.LinuxBlockIODevice
+	LinuxWeightDevice   = runtime.LinuxWeightDevice
+	LinuxThrottleDevice = runtime.LinuxThrottleDevice
+	LinuxHugepageLimit  = runtime.LinuxHugepageLimit
+	LinuxRdma           = runtime.LinuxRdma
+	LinuxNetwork        = runtime.LinuxNetwork
+	LinuxCPU            = runtime.LinuxCPU
+	LinuxMemory         = runtime.LinuxMemory
+	LinuxPids           = runtime.LinuxPids
+	LinuxCapabilities   = runtime.LinuxCapabilities
+	LinuxNamespace      = runtime.LinuxNamespace
 
 	POSIXRlimit = runtime.POSIXRlimit
 )
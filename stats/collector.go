@@ -0,0 +1,27 @@
+/*
+   Copyright Farcloser.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package stats
+
+// Collector computes the entries of a container from successive samples of its cgroup metrics, as returned by
+// containerd (eg: Task.Metrics). A collector is bound to a container, and is not safe for concurrent use.
+type Collector interface {
+	// Collect returns the metrics of the container with init process `pid`, and their summary. The CPU percentage
+	// is computed since the previous call, and is 0 for the first one.
+	Collect(anydata interface{}, pid int) (*Metrics, Entry, error)
+	// Reset forgets the previous sample, eg: when the container restarted.
+	Reset()
+}
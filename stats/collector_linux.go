@@ -0,0 +1,174 @@
+/*
+   Copyright Farcloser.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+/*
+   Portions from
+	https://github.com/docker/cli/blob/v27.5.1/cli/command/container/stats_helpers.go
+	https://github.com/moby/moby/blob/v27.5.1/daemon/stats/collector_unix.go
+   Copyright (C) Docker/Moby authors.
+   Licensed under the Apache License, Version 2.0
+   NOTICE: https://github.com/moby/moby/blob/v27.5.1/NOTICE
+*/
+
+package stats
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.farcloser.world/containers/security/cgroups"
+)
+
+const (
+	procStatPath = "/proc/stat"
+	// clockTicks is USER_HZ, which is 100 on all architectures Linux supports.
+	clockTicks = 100
+	// cpuStatFields are the fields of the cpu line of /proc/stat that docker accounts for (user to steal).
+	cpuStatFields = 8
+)
+
+// NewCollector returns the collector for the cgroup `version` of the host.
+// `cpus` is the number of CPUs the container may use (see EffectiveCPUs), and only matters for cgroup v2.
+func NewCollector(version cgroups.SystemVersion, cpus float64) Collector { //nolint:ireturn
+	if version == cgroups.Version1 {
+		return NewCgroup1Collector()
+	}
+
+	return NewCgroup2Collector(cpus)
+}
+
+// Cgroup2Collector collects cgroup v2 metrics. Its CPU percentage is per core (see Sampler).
+type Cgroup2Collector struct {
+	sampler *Sampler
+}
+
+func NewCgroup2Collector(cpus float64) *Cgroup2Collector {
+	return &Cgroup2Collector{sampler: NewSampler(cpus)}
+}
+
+func (c *Cgroup2Collector) Collect(anydata interface{}, pid int) (*Metrics, Entry, error) {
+	metrics, err := Cgroup2Metrics(anydata, pid)
+	if err != nil {
+		return nil, Entry{}, err
+	}
+
+	entry := metrics.Entry()
+	if usage, ok := c.sampler.Sample(metrics); ok {
+		entry.CPUPercentage = usage.PerCore
+	}
+
+	return metrics, entry, nil
+}
+
+func (c *Cgroup2Collector) Reset() {
+	c.sampler.Reset()
+}
+
+// Cgroup1Collector collects cgroup v1 metrics. As docker does, its CPU percentage is the share of the host CPU time
+// the container used, multiplied by the number of CPUs: it is per core as well.
+type Cgroup1Collector struct {
+	previousCPU    time.Duration
+	previousSystem time.Duration
+}
+
+func NewCgroup1Collector() *Cgroup1Collector {
+	return &Cgroup1Collector{}
+}
+
+func (c *Cgroup1Collector) Collect(anydata interface{}, pid int) (*Metrics, Entry, error) {
+	metrics, err := Cgroup1Metrics(anydata, pid)
+	if err != nil {
+		return nil, Entry{}, err
+	}
+
+	system, err := getSystemCPUUsage()
+	if err != nil {
+		return nil, Entry{}, err
+	}
+
+	entry := metrics.Entry()
+
+	if c.previousSystem != 0 {
+		entry.CPUPercentage = calculateCgroup1CPUPercent(c.previousCPU, c.previousSystem, metrics, system)
+	}
+
+	c.previousCPU = metrics.CPU.Usage
+	c.previousSystem = system
+
+	return metrics, entry, nil
+}
+
+func (c *Cgroup1Collector) Reset() {
+	c.previousCPU = 0
+	c.previousSystem = 0
+}
+
+func calculateCgroup1CPUPercent(previousCPU, previousSystem time.Duration, metrics *Metrics, system time.Duration,
+) float64 {
+	var (
+		cpuPercent  = 0.0
+		cpuDelta    = float64(metrics.CPU.Usage) - float64(previousCPU)
+		systemDelta = float64(system) - float64(previousSystem)
+		onlineCPUs  = float64(len(metrics.CPU.PerCPU))
+	)
+
+	if systemDelta > 0.0 && cpuDelta > 0.0 {
+		cpuPercent = (cpuDelta / systemDelta) * onlineCPUs * percent
+	}
+
+	return cpuPercent
+}
+
+// getSystemCPUUsage returns the CPU time of the host, from the cpu line of /proc/stat.
+func getSystemCPUUsage() (time.Duration, error) {
+	file, err := os.Open(procStatPath)
+	if err != nil {
+		return 0, errors.Join(ErrSystemCPU, err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || fields[0] != "cpu" {
+			continue
+		}
+
+		if len(fields) < cpuStatFields+1 {
+			return 0, fmt.Errorf("%w: invalid cpu line in %s", ErrSystemCPU, procStatPath)
+		}
+
+		var ticks uint64
+
+		for _, field := range fields[1 : cpuStatFields+1] {
+			value, err := strconv.ParseUint(field, 10, 64)
+			if err != nil {
+				return 0, fmt.Errorf("%w: %w", ErrSystemCPU, err)
+			}
+
+			ticks += value
+		}
+
+		return time.Duration(ticks) * time.Second / clockTicks, nil
+	}
+
+	return 0, fmt.Errorf("%w: no cpu line in %s", ErrSystemCPU, procStatPath)
+}
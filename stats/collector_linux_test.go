@@ -0,0 +1,118 @@
+/*
+   Copyright Farcloser.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package stats_test
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	v1stats "github.com/containerd/cgroups/v3/cgroup1/stats"
+	cstats "github.com/containerd/cgroups/v3/cgroup2/stats"
+	"gotest.tools/v3/assert"
+
+	"go.farcloser.world/containers/security/cgroups"
+	"go.farcloser.world/containers/stats"
+)
+
+func cgroup1Metrics(usage uint64) *cgroups.MetricsV1 {
+	return &cgroups.MetricsV1{
+		Pids: &v1stats.PidsStat{Current: 3},
+		CPU: &v1stats.CPUStat{
+			Usage: &v1stats.CPUUsage{Total: usage, PerCPU: []uint64{usage / 2, usage / 2}},
+		},
+		Memory: &v1stats.MemoryStat{
+			TotalInactiveFile: 10 << 20,
+			Usage:             &v1stats.MemoryEntry{Usage: 50 << 20, Limit: 100 << 20},
+			Swap:              &v1stats.MemoryEntry{Usage: 60 << 20, Limit: 200 << 20},
+			Kernel:            &v1stats.MemoryEntry{Usage: 4 << 20},
+			KernelTCP:         &v1stats.MemoryEntry{Usage: 1 << 20},
+		},
+		Blkio: &v1stats.BlkIOStat{
+			IoServiceBytesRecursive: []*v1stats.BlkIOEntry{
+				{Op: "Read", Major: 8, Value: 4096},
+				{Op: "Write", Major: 8, Value: 1024},
+				{Op: "Total", Major: 8, Value: 5120},
+				{Op: "Read", Major: 8, Minor: 16, Value: 512},
+			},
+			IoServicedRecursive: []*v1stats.BlkIOEntry{
+				{Op: "Read", Major: 8, Value: 2},
+			},
+		},
+	}
+}
+
+func TestFromCgroup1(t *testing.T) {
+	t.Parallel()
+
+	_, err := stats.FromCgroup1(nil)
+	assert.ErrorIs(t, err, stats.ErrEmptyMetrics)
+
+	metrics, err := stats.FromCgroup1(cgroup1Metrics(uint64(time.Second)))
+	assert.NilError(t, err)
+
+	assert.DeepEqual(t, metrics.CPU.PerCPU, []time.Duration{500 * time.Millisecond, 500 * time.Millisecond})
+	assert.Equal(t, metrics.Memory.WorkingSet(), uint64(40<<20))
+	assert.Equal(t, metrics.Memory.Swap, uint64(10<<20))
+	// Kernel memory is not only made of stacks
+	assert.Equal(t, metrics.Memory.KernelStack, uint64(0))
+	assert.Equal(t, metrics.Memory.Sock, uint64(1<<20))
+	assert.DeepEqual(t, metrics.IO, []stats.IODevice{
+		{Major: 8, ReadBytes: 4096, WriteBytes: 1024, ReadOps: 2},
+		{Major: 8, Minor: 16, ReadBytes: 512},
+	})
+
+	entry := metrics.Entry()
+	assert.Equal(t, entry.MemoryPercentage, 40.0)
+	assert.Equal(t, entry.BlockRead, 4608.0)
+	assert.Equal(t, entry.BlockWrite, 1024.0)
+	assert.Equal(t, entry.PidsCurrent, uint64(3))
+}
+
+func TestCollector(t *testing.T) {
+	t.Parallel()
+
+	collector := stats.NewCollector(cgroups.Version1, 0)
+
+	_, _, err := collector.Collect(&cgroups.Metrics{}, os.Getpid())
+	assert.ErrorIs(t, err, stats.ErrFailedConversion)
+
+	metrics, entry, err := collector.Collect(cgroup1Metrics(0), os.Getpid())
+	assert.NilError(t, err)
+	assert.Equal(t, entry.CPUPercentage, 0.0)
+	assert.Equal(t, metrics.Memory.Limit, uint64(100<<20))
+
+	// Consume some CPU time on the host so that the system delta is not 0
+	for start := time.Now(); time.Since(start) < 50*time.Millisecond; {
+		_ = os.Getpid()
+	}
+
+	_, entry, err = collector.Collect(cgroup1Metrics(uint64(time.Millisecond)), os.Getpid())
+	assert.NilError(t, err)
+	assert.Assert(t, entry.CPUPercentage > 0)
+
+	collector = stats.NewCollector(cgroups.Version2, 2)
+
+	_, _, err = collector.Collect(cgroup1Metrics(0), os.Getpid())
+	assert.ErrorIs(t, err, stats.ErrFailedConversion)
+
+	metrics, _, err = collector.Collect(&cgroups.Metrics{
+		Memory: &cstats.MemoryStat{UsageLimit: ^uint64(0)},
+	}, os.Getpid())
+	assert.NilError(t, err)
+	assert.Assert(t, metrics.Memory.Limit < ^uint64(0))
+}
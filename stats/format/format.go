@@ -0,0 +1,241 @@
+/*
+   Copyright Farcloser.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+/*
+   Portions from
+	https://github.com/docker/cli/blob/v27.5.1/cli/command/container/formatter_stats.go
+	https://github.com/docker/go-units/blob/v0.5.0/size.go
+   Copyright (C) Docker/Moby authors.
+   Licensed under the Apache License, Version 2.0
+   NOTICE: https://github.com/docker/cli/blob/v27.5.1/NOTICE
+*/
+
+// Package format renders stats.Entry the way docker stats does: as a table, as JSON lines, or with a Go template
+// using the same field names (eg: {{.CPUPerc}}).
+package format
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+
+	"go.farcloser.world/containers/stats"
+)
+
+const (
+	// TableFormat is the table with the default columns.
+	TableFormat = "table"
+	// JSONFormat renders each entry as a JSON object, on its own line.
+	JSONFormat = "json"
+	// DefaultTableFormat is the format of TableFormat.
+	DefaultTableFormat = "table {{.ID}}\t{{.Name}}\t{{.CPUPerc}}\t{{.MemUsage}}\t{{.MemPerc}}\t" +
+		"{{.NetIO}}\t{{.BlockIO}}\t{{.PIDs}}"
+
+	tablePrefix   = "table "
+	invalid       = "--"
+	shortIDLen    = 12
+	decimalBase   = 1000.0
+	decimalDigits = 3
+	binaryBase    = 1024.0
+	binaryDigits  = 4
+
+	minWidth = 10
+	tabWidth = 1
+	padding  = 3
+)
+
+var ErrInvalidFormat = errors.New("invalid format")
+
+var (
+	//nolint:gochecknoglobals
+	decimalUnits = []string{"B", "kB", "MB", "GB", "TB", "PB", "EB", "ZB", "YB"}
+	//nolint:gochecknoglobals
+	binaryUnits = []string{"B", "KiB", "MiB", "GiB", "TiB", "PiB", "EiB", "ZiB", "YiB"}
+	//nolint:gochecknoglobals
+	headers = map[string]string{
+		"Container": "CONTAINER",
+		"Name":      "NAME",
+		"ID":        "CONTAINER ID",
+		"CPUPerc":   "CPU %",
+		"MemUsage":  "MEM USAGE / LIMIT",
+		"MemPerc":   "MEM %",
+		"NetIO":     "NET I/O",
+		"BlockIO":   "BLOCK I/O",
+		"PIDs":      "PIDS",
+	}
+)
+
+// Formatter writes entries in a given format.
+type Formatter struct {
+	tmpl    *template.Template
+	table   bool
+	noTrunc bool
+}
+
+// New returns a formatter for `format`: TableFormat, JSONFormat, or a Go template, which is rendered as a table
+// with headers if prefixed by "table ". IDs are shortened in tables, unless `noTrunc` is set.
+func New(format string, noTrunc bool) (*Formatter, error) {
+	switch format {
+	case "", TableFormat:
+		format = DefaultTableFormat
+	case JSONFormat:
+		format = "{{json .}}"
+	}
+
+	formatter := &Formatter{noTrunc: noTrunc}
+
+	if trimmed, ok := strings.CutPrefix(format, tablePrefix); ok {
+		formatter.table = true
+		format = trimmed
+	}
+
+	// Allow escaped tabs and newlines from the command line
+	format = strings.NewReplacer(`\t`, "\t", `\n`, "\n").Replace(format)
+
+	tmpl, err := template.New("").Funcs(funcs()).Option("missingkey=error").Parse(format + "\n")
+	if err != nil {
+		return nil, errors.Join(ErrInvalidFormat, err)
+	}
+
+	formatter.tmpl = tmpl
+
+	return formatter, nil
+}
+
+// Write renders `entries`, preceded by the headers for tables.
+func (f *Formatter) Write(writer io.Writer, entries []stats.Entry) error {
+	var buffer bytes.Buffer
+
+	output := io.Writer(&buffer)
+
+	var tabs *tabwriter.Writer
+	if f.table {
+		tabs = tabwriter.NewWriter(&buffer, minWidth, tabWidth, padding, ' ', 0)
+		output = tabs
+
+		if err := f.tmpl.Execute(output, headers); err != nil {
+			return errors.Join(ErrInvalidFormat, err)
+		}
+	}
+
+	for _, entry := range entries {
+		if err := f.tmpl.Execute(output, Fields(entry, f.noTrunc)); err != nil {
+			return errors.Join(ErrInvalidFormat, err)
+		}
+	}
+
+	if tabs != nil {
+		if err := tabs.Flush(); err != nil {
+			return err
+		}
+	}
+
+	_, err := buffer.WriteTo(writer)
+
+	return err
+}
+
+// Fields returns the fields of `entry` available to templates. The ID is shortened unless `noTrunc` is set.
+func Fields(entry stats.Entry, noTrunc bool) map[string]string {
+	id := entry.ID
+	if !noTrunc && len(id) > shortIDLen {
+		id = id[:shortIDLen]
+	}
+
+	name := strings.TrimPrefix(entry.Name, "/")
+
+	container := name
+	if container == "" {
+		container = id
+	}
+
+	fields := map[string]string{
+		"Container": container,
+		"Name":      name,
+		"ID":        id,
+		"CPUPerc":   invalid,
+		"MemUsage":  invalid + " / " + invalid,
+		"MemPerc":   invalid,
+		"NetIO":     invalid,
+		"BlockIO":   invalid,
+		"PIDs":      invalid,
+	}
+
+	if entry.IsInvalid {
+		return fields
+	}
+
+	fields["CPUPerc"] = Percent(entry.CPUPercentage)
+	fields["MemUsage"] = BytesSize(entry.Memory) + " / " + BytesSize(entry.MemoryLimit)
+	fields["MemPerc"] = Percent(entry.MemoryPercentage)
+	fields["NetIO"] = HumanSize(entry.NetworkRx) + " / " + HumanSize(entry.NetworkTx)
+	fields["BlockIO"] = HumanSize(entry.BlockRead) + " / " + HumanSize(entry.BlockWrite)
+	fields["PIDs"] = strconv.FormatUint(entry.PidsCurrent, 10)
+
+	return fields
+}
+
+// Percent formats a percentage with two decimals (eg: 12.34%).
+func Percent(value float64) string {
+	return fmt.Sprintf("%.2f%%", value)
+}
+
+// HumanSize formats a size with decimal units and 3 significant digits (eg: 1.23MB).
+func HumanSize(size float64) string {
+	return customSize(size, decimalBase, decimalUnits, decimalDigits)
+}
+
+// BytesSize formats a size with binary units and 4 significant digits (eg: 1.234GiB).
+func BytesSize(size float64) string {
+	return customSize(size, binaryBase, binaryUnits, binaryDigits)
+}
+
+func customSize(size float64, base float64, units []string, digits int) string {
+	index := 0
+	for size >= base && index < len(units)-1 {
+		size /= base
+		index++
+	}
+
+	return fmt.Sprintf("%.*g%s", digits, size, units[index])
+}
+
+func funcs() template.FuncMap {
+	return template.FuncMap{
+		"json": func(value any) (string, error) {
+			encoded, err := json.Marshal(value)
+
+			return string(encoded), err
+		},
+		"join":  strings.Join,
+		"split": strings.Split,
+		"lower": strings.ToLower,
+		"upper": strings.ToUpper,
+		"truncate": func(value string, length int) string {
+			if len(value) > length {
+				return value[:length]
+			}
+
+			return value
+		},
+	}
+}
@@ -0,0 +1,118 @@
+/*
+   Copyright Farcloser.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package format_test
+
+import (
+	"bytes"
+	"testing"
+
+	"gotest.tools/v3/assert"
+
+	"go.farcloser.world/containers/stats"
+	"go.farcloser.world/containers/stats/format"
+)
+
+//nolint:gochecknoglobals
+var entries = []stats.Entry{
+	{
+		ID:               "4f1c2e3d5a6b7c8d9e0f",
+		Name:             "/web",
+		CPUPercentage:    12.3456,
+		Memory:           1.2 * 1024 * 1024 * 1024,
+		MemoryLimit:      7.6 * 1024 * 1024 * 1024,
+		MemoryPercentage: 15.789,
+		NetworkRx:        1234,
+		NetworkTx:        5.6e6,
+		BlockRead:        0,
+		BlockWrite:       8.2e9,
+		PidsCurrent:      42,
+	},
+	{
+		ID:        "0123456789abcdef",
+		IsInvalid: true,
+	},
+}
+
+func TestSizes(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, format.BytesSize(0), "0B")
+	assert.Equal(t, format.BytesSize(1023), "1023B")
+	assert.Equal(t, format.BytesSize(1.5*1024*1024), "1.5MiB")
+	assert.Equal(t, format.HumanSize(999), "999B")
+	assert.Equal(t, format.HumanSize(1234), "1.23kB")
+	assert.Equal(t, format.HumanSize(5.6e6), "5.6MB")
+	assert.Equal(t, format.Percent(0.5), "0.50%")
+}
+
+func TestTable(t *testing.T) {
+	t.Parallel()
+
+	formatter, err := format.New(format.TableFormat, false)
+	assert.NilError(t, err)
+
+	var buffer bytes.Buffer
+
+	assert.NilError(t, formatter.Write(&buffer, entries))
+	assert.Equal(t, buffer.String(), ""+
+		"CONTAINER ID   NAME      CPU %     MEM USAGE / LIMIT   MEM %     NET I/O          BLOCK I/O    PIDS\n"+
+		"4f1c2e3d5a6b   web       12.35%    1.2GiB / 7.6GiB     15.79%    1.23kB / 5.6MB   0B / 8.2GB   42\n"+
+		"0123456789ab             --        -- / --             --        --               --           --\n")
+}
+
+func TestTemplate(t *testing.T) {
+	t.Parallel()
+
+	formatter, err := format.New(`table {{.Container}}\t{{.CPUPerc}}\t{{.PIDs}}`, true)
+	assert.NilError(t, err)
+
+	var buffer bytes.Buffer
+
+	assert.NilError(t, formatter.Write(&buffer, entries))
+	assert.Equal(t, buffer.String(), ""+
+		"CONTAINER          CPU %     PIDS\n"+
+		"web                12.35%    42\n"+
+		"0123456789abcdef   --        --\n")
+
+	formatter, err = format.New(`{{.Name}}: {{.MemUsage}} {{upper .NetIO}}`, false)
+	assert.NilError(t, err)
+
+	buffer.Reset()
+	assert.NilError(t, formatter.Write(&buffer, entries[:1]))
+	assert.Equal(t, buffer.String(), "web: 1.2GiB / 7.6GiB 1.23KB / 5.6MB\n")
+
+	_, err = format.New(`{{.Name`, false)
+	assert.ErrorIs(t, err, format.ErrInvalidFormat)
+
+	formatter, err = format.New(`{{.Nope.Name}}`, false)
+	assert.NilError(t, err)
+	assert.ErrorIs(t, formatter.Write(&buffer, entries), format.ErrInvalidFormat)
+}
+
+func TestJSON(t *testing.T) {
+	t.Parallel()
+
+	formatter, err := format.New(format.JSONFormat, false)
+	assert.NilError(t, err)
+
+	var buffer bytes.Buffer
+
+	assert.NilError(t, formatter.Write(&buffer, entries[:1]))
+	assert.Equal(t, buffer.String(), `{"BlockIO":"0B / 8.2GB","CPUPerc":"12.35%","Container":"web",`+
+		`"ID":"4f1c2e3d5a6b","MemPerc":"15.79%","MemUsage":"1.2GiB / 7.6GiB","Name":"web",`+
+		`"NetIO":"1.23kB / 5.6MB","PIDs":"42"}`+"\n")
+}
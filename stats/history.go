@@ -0,0 +1,251 @@
+/*
+   Copyright Farcloser.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package stats
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Point is an entry of a container at a given time.
+type Point struct {
+	Time  time.Time
+	Entry Entry
+}
+
+// Rate is the throughput of a container between two points, in bytes per second.
+type Rate struct {
+	// Time is the end of the interval.
+	Time       time.Time
+	NetworkRx  float64
+	NetworkTx  float64
+	BlockRead  float64
+	BlockWrite float64
+}
+
+// Summary is the minimum, maximum and average of a value over a window.
+type Summary struct {
+	Min     float64
+	Max     float64
+	Average float64
+	// Count is the number of values; the other fields are 0 if there are none.
+	Count int
+}
+
+// History keeps the latest points of a container, up to a fixed capacity, after which the oldest are dropped.
+// It is safe for concurrent use.
+type History struct {
+	mutex  sync.RWMutex
+	points []Point
+	// next is where the next point goes, and full whether points wrapped around.
+	next int
+	full bool
+}
+
+func NewHistory(capacity int) *History {
+	return &History{points: make([]Point, max(1, capacity))}
+}
+
+// Add records `entry`, as of `at`.
+func (h *History) Add(at time.Time, entry Entry) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.points[h.next] = Point{Time: at, Entry: entry}
+	h.next = (h.next + 1) % len(h.points)
+	h.full = h.full || h.next == 0
+}
+
+// Len returns the number of points recorded.
+func (h *History) Len() int {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	if h.full {
+		return len(h.points)
+	}
+
+	return h.next
+}
+
+// Points returns the points recorded, oldest first.
+func (h *History) Points() []Point {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	if !h.full {
+		return append([]Point{}, h.points[:h.next]...)
+	}
+
+	return append(append([]Point{}, h.points[h.next:]...), h.points[:h.next]...)
+}
+
+// Window returns the points recorded over the last `window` before the latest one, oldest first. A window of 0 or
+// less returns all points.
+func (h *History) Window(window time.Duration) []Point {
+	points := h.Points()
+	if window <= 0 || len(points) == 0 {
+		return points
+	}
+
+	since := points[len(points)-1].Time.Add(-window)
+	for index, point := range points {
+		if !point.Time.Before(since) {
+			return points[index:]
+		}
+	}
+
+	return nil
+}
+
+// Rates returns the rates between consecutive valid points over `window` (see Window).
+// Counters going down are assumed to have been reset (eg: the container restarted), and to have started over from 0.
+func (h *History) Rates(window time.Duration) []Rate {
+	intervals := h.intervals(window)
+	rates := make([]Rate, 0, len(intervals))
+
+	for _, interval := range intervals {
+		rates = append(rates, interval.bytes.divide(interval.seconds))
+	}
+
+	return rates
+}
+
+// Rate returns the average rates over `window`.
+func (h *History) Rate(window time.Duration) (Rate, bool) {
+	intervals := h.intervals(window)
+	if len(intervals) == 0 {
+		return Rate{}, false
+	}
+
+	var (
+		total   Rate
+		seconds float64
+	)
+
+	for _, interval := range intervals {
+		total.NetworkRx += interval.bytes.NetworkRx
+		total.NetworkTx += interval.bytes.NetworkTx
+		total.BlockRead += interval.bytes.BlockRead
+		total.BlockWrite += interval.bytes.BlockWrite
+		seconds += interval.seconds
+	}
+
+	total.Time = intervals[len(intervals)-1].bytes.Time
+
+	return total.divide(seconds), true
+}
+
+// interval is the bytes transferred between two consecutive valid points.
+type interval struct {
+	bytes   Rate
+	seconds float64
+}
+
+func (h *History) intervals(window time.Duration) []interval {
+	var (
+		intervals []interval
+		previous  *Point
+	)
+
+	for _, point := range h.Window(window) {
+		if point.Entry.IsInvalid {
+			continue
+		}
+
+		if previous != nil {
+			if seconds := point.Time.Sub(previous.Time).Seconds(); seconds > 0 {
+				intervals = append(intervals, interval{
+					bytes: Rate{
+						Time:       point.Time,
+						NetworkRx:  counterDelta(previous.Entry.NetworkRx, point.Entry.NetworkRx),
+						NetworkTx:  counterDelta(previous.Entry.NetworkTx, point.Entry.NetworkTx),
+						BlockRead:  counterDelta(previous.Entry.BlockRead, point.Entry.BlockRead),
+						BlockWrite: counterDelta(previous.Entry.BlockWrite, point.Entry.BlockWrite),
+					},
+					seconds: seconds,
+				})
+			}
+		}
+
+		previous = &point
+	}
+
+	return intervals
+}
+
+func (r Rate) divide(seconds float64) Rate {
+	return Rate{
+		Time:       r.Time,
+		NetworkRx:  r.NetworkRx / seconds,
+		NetworkTx:  r.NetworkTx / seconds,
+		BlockRead:  r.BlockRead / seconds,
+		BlockWrite: r.BlockWrite / seconds,
+	}
+}
+
+// Summarize returns the summary of a value of the valid entries over `window` (eg: the CPU percentage).
+func (h *History) Summarize(window time.Duration, value func(entry Entry) float64) Summary {
+	var values []float64
+
+	for _, point := range h.Window(window) {
+		if !point.Entry.IsInvalid {
+			values = append(values, value(point.Entry))
+		}
+	}
+
+	return summarize(values)
+}
+
+// SummarizeRates returns the summary of a rate over `window` (eg: the bytes received per second).
+func (h *History) SummarizeRates(window time.Duration, value func(rate Rate) float64) Summary {
+	rates := h.Rates(window)
+	values := make([]float64, 0, len(rates))
+
+	for _, rate := range rates {
+		values = append(values, value(rate))
+	}
+
+	return summarize(values)
+}
+
+func summarize(values []float64) Summary {
+	if len(values) == 0 {
+		return Summary{}
+	}
+
+	summary := Summary{Min: math.Inf(1), Max: math.Inf(-1), Count: len(values)}
+
+	for _, value := range values {
+		summary.Min = math.Min(summary.Min, value)
+		summary.Max = math.Max(summary.Max, value)
+		summary.Average += value
+	}
+
+	summary.Average /= float64(len(values))
+
+	return summary
+}
+
+func counterDelta(previous float64, current float64) float64 {
+	if current < previous {
+		return current
+	}
+
+	return current - previous
+}
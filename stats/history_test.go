@@ -0,0 +1,97 @@
+/*
+   Copyright Farcloser.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package stats_test
+
+import (
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+
+	"go.farcloser.world/containers/stats"
+)
+
+func TestHistoryRing(t *testing.T) {
+	t.Parallel()
+
+	start := time.Now()
+	history := stats.NewHistory(3)
+	assert.Equal(t, len(history.Points()), 0)
+
+	for index := range 5 {
+		history.Add(start.Add(time.Duration(index)*time.Second), stats.Entry{PidsCurrent: uint64(index)})
+	}
+
+	assert.Equal(t, history.Len(), 3)
+
+	points := history.Points()
+	assert.Equal(t, len(points), 3)
+
+	for index, point := range points {
+		assert.Equal(t, point.Entry.PidsCurrent, uint64(index+2))
+	}
+
+	assert.Equal(t, len(history.Window(time.Second)), 2)
+	assert.Equal(t, len(history.Window(0)), 3)
+}
+
+func TestHistoryRates(t *testing.T) {
+	t.Parallel()
+
+	start := time.Now()
+	history := stats.NewHistory(10)
+
+	for index, entry := range []stats.Entry{
+		{NetworkRx: 1000, BlockWrite: 0, CPUPercentage: 10},
+		{NetworkRx: 3000, BlockWrite: 500, CPUPercentage: 30},
+		{IsInvalid: true},
+		// Restarted: counters start over
+		{NetworkRx: 400, BlockWrite: 100, CPUPercentage: 50},
+		{NetworkRx: 1400, BlockWrite: 100, CPUPercentage: 20},
+	} {
+		history.Add(start.Add(time.Duration(index)*time.Second), entry)
+	}
+
+	rates := history.Rates(0)
+	assert.Equal(t, len(rates), 3)
+	assert.Equal(t, rates[0].NetworkRx, 2000.0)
+	assert.Equal(t, rates[0].BlockWrite, 500.0)
+	assert.Equal(t, rates[1].NetworkRx, 200.0)
+	assert.Equal(t, rates[1].BlockWrite, 50.0)
+	assert.Equal(t, rates[2].NetworkRx, 1000.0)
+	assert.Equal(t, rates[2].BlockWrite, 0.0)
+
+	// (2000 + 400 + 1000) bytes over 4 seconds
+	rate, ok := history.Rate(0)
+	assert.Assert(t, ok)
+	assert.Equal(t, rate.NetworkRx, 850.0)
+	assert.Equal(t, rate.Time, start.Add(4*time.Second))
+
+	rate, ok = history.Rate(time.Second)
+	assert.Assert(t, ok)
+	assert.Equal(t, rate.NetworkRx, 1000.0)
+
+	_, ok = stats.NewHistory(10).Rate(0)
+	assert.Assert(t, !ok)
+
+	assert.DeepEqual(t, history.Summarize(0, func(entry stats.Entry) float64 { return entry.CPUPercentage }),
+		stats.Summary{Min: 10, Max: 50, Average: 27.5, Count: 4})
+	assert.DeepEqual(t, history.SummarizeRates(0, func(rate stats.Rate) float64 { return rate.NetworkRx }),
+		stats.Summary{Min: 200, Max: 2000, Average: 3200.0 / 3, Count: 3})
+	assert.DeepEqual(t, history.Summarize(-1, func(entry stats.Entry) float64 { return entry.Memory }),
+		stats.Summary{Count: 4})
+}
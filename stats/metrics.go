@@ -0,0 +1,343 @@
+/*
+   Copyright Farcloser.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package stats
+
+import (
+	"strings"
+	"time"
+
+	"go.farcloser.world/containers/netlink"
+	"go.farcloser.world/containers/security/cgroups"
+)
+
+// Metrics is the detailed resource usage of a container, which Entry summarizes.
+type Metrics struct {
+	// Time is when the metrics were collected.
+	Time    time.Time
+	CPU     CPUMetrics
+	Memory  MemoryMetrics
+	IO      []IODevice
+	Hugetlb []HugetlbUsage
+	RDMA    []RDMAUsage
+	Network NetworkMetrics
+	Pids    PidsMetrics
+	// Pressure is nil for kernels without PSI, or if it is disabled (psi=0).
+	Pressure *PressureMetrics
+}
+
+type CPUMetrics struct {
+	Usage  time.Duration
+	User   time.Duration
+	System time.Duration
+	// PerCPU is the usage on each CPU of the host, for cgroup v1 only.
+	PerCPU []time.Duration
+	// Periods is the number of elapsed enforcement periods of cpu.max, of which Throttled were throttled.
+	Periods          uint64
+	ThrottledPeriods uint64
+	ThrottledTime    time.Duration
+}
+
+type MemoryMetrics struct {
+	Usage uint64
+	// Limit is math.MaxUint64 when unlimited.
+	Limit        uint64
+	MaxUsage     uint64
+	Anon         uint64
+	File         uint64
+	InactiveFile uint64
+	// KernelStack is zero on cgroup v1, which only reports the total kernel memory usage.
+	KernelStack uint64
+	Slab        uint64
+	Sock        uint64
+	Shmem       uint64
+	Swap        uint64
+	SwapLimit   uint64
+}
+
+type IODevice struct {
+	Major      uint64
+	Minor      uint64
+	ReadBytes  uint64
+	WriteBytes uint64
+	ReadOps    uint64
+	WriteOps   uint64
+}
+
+type HugetlbUsage struct {
+	// PageSize is the size of the pages (eg: 2MB).
+	PageSize string
+	Usage    uint64
+	Limit    uint64
+}
+
+type RDMAUsage struct {
+	Device          string
+	HCAHandles      uint32
+	HCAObjects      uint32
+	HCAHandlesLimit uint32
+	HCAObjectsLimit uint32
+}
+
+type NetworkMetrics struct {
+	RxBytes    uint64
+	TxBytes    uint64
+	Interfaces []netlink.InterfaceStats
+}
+
+type PidsMetrics struct {
+	Current uint64
+	Limit   uint64
+}
+
+type PressureMetrics struct {
+	CPU    *cgroups.Pressure
+	Memory *cgroups.Pressure
+	IO     *cgroups.Pressure
+}
+
+// FromCgroup2 converts the metrics of a cgroup v2 group. Network metrics are not part of them, and are left empty.
+func FromCgroup2(metrics *cgroups.Metrics) (*Metrics, error) {
+	if metrics == nil {
+		return nil, ErrEmptyMetrics
+	}
+
+	cpu := metrics.GetCPU()
+	memory := metrics.GetMemory()
+
+	res := &Metrics{
+		Time: time.Now(),
+		CPU: CPUMetrics{
+			Usage:            time.Duration(cpu.GetUsageUsec()) * time.Microsecond,
+			User:             time.Duration(cpu.GetUserUsec()) * time.Microsecond,
+			System:           time.Duration(cpu.GetSystemUsec()) * time.Microsecond,
+			Periods:          cpu.GetNrPeriods(),
+			ThrottledPeriods: cpu.GetNrThrottled(),
+			ThrottledTime:    time.Duration(cpu.GetThrottledUsec()) * time.Microsecond,
+		},
+		Memory: MemoryMetrics{
+			Usage:        memory.GetUsage(),
+			Limit:        memory.GetUsageLimit(),
+			MaxUsage:     memory.GetMaxUsage(),
+			Anon:         memory.GetAnon(),
+			File:         memory.GetFile(),
+			InactiveFile: memory.GetInactiveFile(),
+			KernelStack:  memory.GetKernelStack(),
+			Slab:         memory.GetSlab(),
+			Sock:         memory.GetSock(),
+			Shmem:        memory.GetShmem(),
+			Swap:         memory.GetSwapUsage(),
+			SwapLimit:    memory.GetSwapLimit(),
+		},
+		Pids: PidsMetrics{
+			Current: metrics.GetPids().GetCurrent(),
+			Limit:   metrics.GetPids().GetLimit(),
+		},
+	}
+
+	for _, entry := range metrics.GetIo().GetUsage() {
+		res.IO = append(res.IO, IODevice{
+			Major:      entry.GetMajor(),
+			Minor:      entry.GetMinor(),
+			ReadBytes:  entry.GetRbytes(),
+			WriteBytes: entry.GetWbytes(),
+			ReadOps:    entry.GetRios(),
+			WriteOps:   entry.GetWios(),
+		})
+	}
+
+	for _, entry := range metrics.GetHugetlb() {
+		res.Hugetlb = append(res.Hugetlb, HugetlbUsage{
+			PageSize: entry.GetPagesize(),
+			Usage:    entry.GetCurrent(),
+			Limit:    entry.GetMax(),
+		})
+	}
+
+	res.RDMA = rdmaUsage(metrics.GetRdma().GetCurrent(), metrics.GetRdma().GetLimit())
+
+	if cpu.GetPSI() != nil || memory.GetPSI() != nil || metrics.GetIo().GetPSI() != nil {
+		res.Pressure = &PressureMetrics{
+			CPU:    cgroups.PressureFromPSI(cpu.GetPSI()),
+			Memory: cgroups.PressureFromPSI(memory.GetPSI()),
+			IO:     cgroups.PressureFromPSI(metrics.GetIo().GetPSI()),
+		}
+	}
+
+	return res, nil
+}
+
+// FromCgroup1 converts the metrics of a cgroup v1 container. Network metrics are left empty, and there is no
+// pressure information.
+func FromCgroup1(metrics *cgroups.MetricsV1) (*Metrics, error) {
+	if metrics == nil {
+		return nil, ErrEmptyMetrics
+	}
+
+	usage := metrics.GetCPU().GetUsage()
+	throttling := metrics.GetCPU().GetThrottling()
+	memory := metrics.GetMemory()
+
+	res := &Metrics{
+		Time: time.Now(),
+		CPU: CPUMetrics{
+			Usage:            time.Duration(usage.GetTotal()),
+			User:             time.Duration(usage.GetUser()),
+			System:           time.Duration(usage.GetKernel()),
+			Periods:          throttling.GetPeriods(),
+			ThrottledPeriods: throttling.GetThrottledPeriods(),
+			ThrottledTime:    time.Duration(throttling.GetThrottledTime()),
+		},
+		Memory: MemoryMetrics{
+			Usage:        memory.GetUsage().GetUsage(),
+			Limit:        memory.GetUsage().GetLimit(),
+			MaxUsage:     memory.GetUsage().GetMax(),
+			Anon:         memory.GetTotalRSS(),
+			File:         memory.GetTotalCache(),
+			InactiveFile: memory.GetTotalInactiveFile(),
+			Sock:         memory.GetKernelTCP().GetUsage(),
+		},
+		Pids: PidsMetrics{
+			Current: metrics.GetPids().GetCurrent(),
+			Limit:   metrics.GetPids().GetLimit(),
+		},
+	}
+
+	for _, cpu := range usage.GetPerCPU() {
+		res.CPU.PerCPU = append(res.CPU.PerCPU, time.Duration(cpu))
+	}
+
+	// memory.memsw.usage_in_bytes accounts for both memory and swap
+	if swap := memory.GetSwap(); swap.GetUsage() > res.Memory.Usage {
+		res.Memory.Swap = swap.GetUsage() - res.Memory.Usage
+		res.Memory.SwapLimit = swap.GetLimit()
+	}
+
+	devices := map[[2]uint64]int{}
+	device := func(major uint64, minor uint64) *IODevice {
+		index, ok := devices[[2]uint64{major, minor}]
+		if !ok {
+			index = len(res.IO)
+			devices[[2]uint64{major, minor}] = index
+			res.IO = append(res.IO, IODevice{Major: major, Minor: minor})
+		}
+
+		return &res.IO[index]
+	}
+
+	for _, entry := range metrics.GetBlkio().GetIoServiceBytesRecursive() {
+		switch strings.ToLower(entry.GetOp()) {
+		case "read":
+			device(entry.GetMajor(), entry.GetMinor()).ReadBytes += entry.GetValue()
+		case "write":
+			device(entry.GetMajor(), entry.GetMinor()).WriteBytes += entry.GetValue()
+		}
+	}
+
+	for _, entry := range metrics.GetBlkio().GetIoServicedRecursive() {
+		switch strings.ToLower(entry.GetOp()) {
+		case "read":
+			device(entry.GetMajor(), entry.GetMinor()).ReadOps += entry.GetValue()
+		case "write":
+			device(entry.GetMajor(), entry.GetMinor()).WriteOps += entry.GetValue()
+		}
+	}
+
+	for _, entry := range metrics.GetHugetlb() {
+		res.Hugetlb = append(res.Hugetlb, HugetlbUsage{
+			PageSize: entry.GetPagesize(),
+			Usage:    entry.GetUsage(),
+			Limit:    entry.GetMax(),
+		})
+	}
+
+	res.RDMA = rdmaUsage(metrics.GetRdma().GetCurrent(), metrics.GetRdma().GetLimit())
+
+	return res, nil
+}
+
+// WorkingSet returns the memory usage without the inactive page cache, which is what docker reports.
+func (m *MemoryMetrics) WorkingSet() uint64 {
+	if m.InactiveFile < m.Usage {
+		return m.Usage - m.InactiveFile
+	}
+
+	return m.Usage
+}
+
+// IOTotals returns the bytes read and written across all devices.
+func (m *Metrics) IOTotals() (uint64, uint64) {
+	var read, write uint64
+
+	for _, device := range m.IO {
+		read += device.ReadBytes
+		write += device.WriteBytes
+	}
+
+	return read, write
+}
+
+// Entry returns the summary of the metrics. CPUPercentage needs a previous sample, and is left to the caller.
+func (m *Metrics) Entry() Entry {
+	read, write := m.IOTotals()
+	memory := float64(m.Memory.WorkingSet())
+	limit := float64(m.Memory.Limit)
+
+	return Entry{
+		Memory:           memory,
+		MemoryLimit:      limit,
+		MemoryPercentage: calculateMemPercent(limit, memory),
+		NetworkRx:        float64(m.Network.RxBytes),
+		NetworkTx:        float64(m.Network.TxBytes),
+		BlockRead:        float64(read),
+		BlockWrite:       float64(write),
+		PidsCurrent:      m.Pids.Current,
+		Networks:         m.Network.Interfaces,
+	}
+}
+
+// rdmaEntry is an entry of rdma.current or rdma.max, from the cgroup v1 or v2 metrics.
+type rdmaEntry interface {
+	GetDevice() string
+	GetHcaHandles() uint32
+	GetHcaObjects() uint32
+}
+
+func rdmaUsage[E rdmaEntry](current []E, limits []E) []RDMAUsage {
+	maxByDevice := map[string]E{}
+	for _, entry := range limits {
+		maxByDevice[entry.GetDevice()] = entry
+	}
+
+	var res []RDMAUsage
+
+	for _, entry := range current {
+		usage := RDMAUsage{
+			Device:     entry.GetDevice(),
+			HCAHandles: entry.GetHcaHandles(),
+			HCAObjects: entry.GetHcaObjects(),
+		}
+
+		if limit, ok := maxByDevice[entry.GetDevice()]; ok {
+			usage.HCAHandlesLimit = limit.GetHcaHandles()
+			usage.HCAObjectsLimit = limit.GetHcaObjects()
+		}
+
+		res = append(res, usage)
+	}
+
+	return res
+}
@@ -0,0 +1,102 @@
+/*
+   Copyright Farcloser.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package stats_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	cstats "github.com/containerd/cgroups/v3/cgroup2/stats"
+	"gotest.tools/v3/assert"
+
+	"go.farcloser.world/containers/security/cgroups"
+	"go.farcloser.world/containers/stats"
+)
+
+func TestFromCgroup2(t *testing.T) {
+	t.Parallel()
+
+	_, err := stats.FromCgroup2(nil)
+	assert.ErrorIs(t, err, stats.ErrEmptyMetrics)
+
+	metrics, err := stats.FromCgroup2(&cgroups.Metrics{
+		Pids: &cstats.PidsStat{Current: 12, Limit: 100},
+		CPU: &cstats.CPUStat{
+			UsageUsec:     3000,
+			UserUsec:      2000,
+			SystemUsec:    1000,
+			NrPeriods:     10,
+			NrThrottled:   4,
+			ThrottledUsec: 500,
+			PSI: &cstats.PSIStats{
+				Some: &cstats.PSIData{Avg10: 1.5, Total: 2000},
+			},
+		},
+		Memory: &cstats.MemoryStat{
+			Usage:        100 << 20,
+			UsageLimit:   math.MaxUint64,
+			InactiveFile: 40 << 20,
+			Anon:         50 << 20,
+			Slab:         4 << 20,
+			SwapUsage:    1 << 20,
+		},
+		Io: &cstats.IOStat{Usage: []*cstats.IOEntry{
+			{Major: 8, Minor: 0, Rbytes: 4096, Rios: 1, Wbytes: 8192, Wios: 2},
+			{Major: 8, Minor: 16, Rbytes: 1024, Rios: 1},
+		}},
+		Hugetlb: []*cstats.HugeTlbStat{{Pagesize: "2MB", Current: 2 << 20, Max: 4 << 20}},
+		Rdma: &cstats.RdmaStat{
+			Current: []*cstats.RdmaEntry{{Device: "mlx4_0", HcaHandles: 2, HcaObjects: 20}},
+			Limit:   []*cstats.RdmaEntry{{Device: "mlx4_0", HcaHandles: 10, HcaObjects: 100}},
+		},
+	})
+	assert.NilError(t, err)
+
+	assert.DeepEqual(t, metrics.CPU, stats.CPUMetrics{
+		Usage:            3 * time.Millisecond,
+		User:             2 * time.Millisecond,
+		System:           time.Millisecond,
+		Periods:          10,
+		ThrottledPeriods: 4,
+		ThrottledTime:    500 * time.Microsecond,
+	})
+	assert.Equal(t, metrics.Memory.WorkingSet(), uint64(60<<20))
+	assert.Equal(t, metrics.Memory.Swap, uint64(1<<20))
+	assert.Equal(t, len(metrics.IO), 2)
+	assert.DeepEqual(t, metrics.Hugetlb, []stats.HugetlbUsage{{PageSize: "2MB", Usage: 2 << 20, Limit: 4 << 20}})
+	assert.DeepEqual(t, metrics.RDMA, []stats.RDMAUsage{
+		{Device: "mlx4_0", HCAHandles: 2, HCAObjects: 20, HCAHandlesLimit: 10, HCAObjectsLimit: 100},
+	})
+	assert.DeepEqual(t, metrics.Pressure, &stats.PressureMetrics{
+		CPU: &cgroups.Pressure{Some: &cgroups.PressureData{Avg10: 1.5, Total: 2 * time.Millisecond}},
+	})
+
+	metrics.Memory.Limit = 200 << 20
+	metrics.Network = stats.NetworkMetrics{RxBytes: 10, TxBytes: 20}
+
+	assert.DeepEqual(t, metrics.Entry(), stats.Entry{
+		Memory:           60 << 20,
+		MemoryLimit:      200 << 20,
+		MemoryPercentage: 30,
+		NetworkRx:        10,
+		NetworkTx:        20,
+		BlockRead:        5120,
+		BlockWrite:       8192,
+		PidsCurrent:      12,
+	})
+}
@@ -0,0 +1,214 @@
+/*
+   Copyright Farcloser.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package stats
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync"
+	"time"
+)
+
+const defaultInterval = time.Second
+
+// ErrContainerNotFound is to be returned by a Source for containers that do not exist anymore, or are not running.
+var ErrContainerNotFound = errors.New("container not found")
+
+// Source returns the cgroup metrics of a container, and the pid of its init process (eg: from containerd
+// Task.Metrics and Task.Pid).
+type Source func(ctx context.Context, id string) (anydata interface{}, pid int, err error)
+
+// Update is the result of sampling a container.
+type Update struct {
+	Entry
+	// Metrics is nil if Err is set.
+	Metrics *Metrics
+	Err     error
+}
+
+// Monitor samples a set of containers at a fixed interval, with a bounded number of workers.
+type Monitor struct {
+	// Interval is the time between samples, one second by default.
+	Interval time.Duration
+	// Workers is the maximum number of containers sampled at once, the number of CPUs by default.
+	Workers int
+
+	source       Source
+	newCollector func(id string) Collector
+
+	mutex      sync.Mutex
+	containers map[string]*monitored
+}
+
+type monitored struct {
+	name      string
+	stats     *Stats
+	collector Collector
+}
+
+// NewMonitor returns a monitor sampling containers from `source`, with a collector from `newCollector` for each
+// (eg: NewCollector for the cgroup version of the host).
+func NewMonitor(source Source, newCollector func(id string) Collector) *Monitor {
+	return &Monitor{
+		Interval:     defaultInterval,
+		Workers:      runtime.NumCPU(),
+		source:       source,
+		newCollector: newCollector,
+		containers:   map[string]*monitored{},
+	}
+}
+
+// Add starts monitoring a container, and returns its statistics holder. Adding a container twice returns the same
+// holder.
+func (m *Monitor) Add(id string, name string) *Stats {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if container, ok := m.containers[id]; ok {
+		return container.stats
+	}
+
+	container := &monitored{
+		name:      name,
+		stats:     NewStats(id),
+		collector: m.newCollector(id),
+	}
+	container.stats.Name = name
+	m.containers[id] = container
+
+	return container.stats
+}
+
+// Remove stops monitoring a container.
+func (m *Monitor) Remove(id string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	delete(m.containers, id)
+}
+
+// Get returns the statistics holder of a container.
+func (m *Monitor) Get(id string) (*Stats, bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	container, ok := m.containers[id]
+	if !ok {
+		return nil, false
+	}
+
+	return container.stats, true
+}
+
+// Run samples the containers until `ctx` is done, and sends the updates of each round on the returned channel,
+// which is closed afterwards. The first round starts right away.
+// Containers that disappear (see ErrContainerNotFound) are reset and marked invalid, but kept until removed.
+func (m *Monitor) Run(ctx context.Context) <-chan []Update {
+	updates := make(chan []Update)
+
+	go func() {
+		defer close(updates)
+
+		ticker := time.NewTicker(m.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case updates <- m.sample(ctx):
+			case <-ctx.Done():
+				return
+			}
+
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return updates
+}
+
+// sample samples all the containers once.
+func (m *Monitor) sample(ctx context.Context) []Update {
+	m.mutex.Lock()
+	containers := make([]*monitored, 0, len(m.containers))
+
+	for _, container := range m.containers {
+		containers = append(containers, container)
+	}
+	m.mutex.Unlock()
+
+	results := make([]Update, len(containers))
+	jobs := make(chan int)
+
+	var wg sync.WaitGroup
+
+	for range max(1, min(m.Workers, len(containers))) {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for index := range jobs {
+				results[index] = m.collect(ctx, containers[index])
+			}
+		}()
+	}
+
+	for index := range containers {
+		jobs <- index
+	}
+
+	close(jobs)
+	wg.Wait()
+
+	return results
+}
+
+func (m *Monitor) collect(ctx context.Context, container *monitored) Update {
+	id := container.stats.GetStatistics().ID
+
+	anydata, pid, err := m.source(ctx, id)
+	if err == nil {
+		var (
+			metrics *Metrics
+			entry   Entry
+		)
+
+		metrics, entry, err = container.collector.Collect(anydata, pid)
+		if err == nil {
+			entry.ID = id
+			entry.Name = container.name
+			container.stats.SetStatistics(entry)
+			container.stats.SetError(nil)
+
+			return Update{Entry: entry, Metrics: metrics}
+		}
+	}
+
+	if errors.Is(err, ErrContainerNotFound) {
+		container.collector.Reset()
+		container.stats.SetErrorAndReset(err)
+	} else {
+		container.stats.SetError(err)
+	}
+
+	return Update{Entry: container.stats.GetStatistics(), Err: err}
+}
@@ -0,0 +1,143 @@
+/*
+   Copyright Farcloser.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package stats_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+
+	"go.farcloser.world/containers/stats"
+)
+
+var errBroken = errors.New("broken")
+
+type fakeCollector struct {
+	samples int
+}
+
+func (c *fakeCollector) Collect(anydata interface{}, pid int) (*stats.Metrics, stats.Entry, error) {
+	c.samples++
+
+	return &stats.Metrics{}, stats.Entry{PidsCurrent: uint64(pid), CPUPercentage: float64(c.samples)}, nil
+}
+
+func (c *fakeCollector) Reset() {
+	c.samples = 0
+}
+
+type fakeSource struct {
+	mutex sync.Mutex
+	pids  map[string]int
+}
+
+func (s *fakeSource) metrics(_ context.Context, id string) (interface{}, int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	pid, ok := s.pids[id]
+	if !ok {
+		return nil, 0, fmt.Errorf("%w: %s", stats.ErrContainerNotFound, id)
+	}
+
+	if pid == 0 {
+		return nil, 0, errBroken
+	}
+
+	return nil, pid, nil
+}
+
+func (s *fakeSource) set(id string, pid int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if pid < 0 {
+		delete(s.pids, id)
+	} else {
+		s.pids[id] = pid
+	}
+}
+
+func TestMonitor(t *testing.T) {
+	t.Parallel()
+
+	source := &fakeSource{pids: map[string]int{}}
+	monitor := stats.NewMonitor(source.metrics, func(string) stats.Collector { return &fakeCollector{} })
+	monitor.Interval = 10 * time.Millisecond
+	monitor.Workers = 4
+
+	for index := range 200 {
+		id := fmt.Sprintf("c%d", index)
+		source.set(id, index+1)
+		monitor.Add(id, "name-"+id)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	updates := monitor.Run(ctx)
+
+	round := <-updates
+	assert.Equal(t, len(round), 200)
+
+	for _, update := range round {
+		assert.NilError(t, update.Err)
+		assert.Equal(t, update.Name, "name-"+update.ID)
+		assert.Equal(t, update.CPUPercentage, 1.0)
+	}
+
+	// c0 disappears, c1 fails, c2 is removed, and c200 is added
+	source.set("c0", -1)
+	source.set("c1", 0)
+	monitor.Remove("c2")
+	source.set("c200", 1000)
+	monitor.Add("c200", "late")
+
+	byID := map[string]stats.Update{}
+	for _, update := range <-updates {
+		byID[update.ID] = update
+	}
+
+	assert.Equal(t, len(byID), 200)
+	assert.ErrorIs(t, byID["c0"].Err, stats.ErrContainerNotFound)
+	assert.Assert(t, byID["c0"].IsInvalid)
+	assert.Equal(t, byID["c0"].PidsCurrent, uint64(0))
+	assert.ErrorIs(t, byID["c1"].Err, errBroken)
+	assert.Assert(t, byID["c1"].IsInvalid)
+	assert.Equal(t, byID["c1"].PidsCurrent, uint64(2))
+	assert.Equal(t, byID["c3"].CPUPercentage, 2.0)
+	assert.Equal(t, byID["c200"].CPUPercentage, 1.0)
+
+	holder, ok := monitor.Get("c0")
+	assert.Assert(t, ok)
+	assert.ErrorIs(t, holder.GetError(), stats.ErrContainerNotFound)
+
+	_, ok = monitor.Get("c2")
+	assert.Assert(t, !ok)
+
+	cancel()
+
+	// Rounds in flight are dropped, and the channel is closed
+	for open := true; open; {
+		_, open = <-updates
+	}
+}
@@ -0,0 +1,223 @@
+/*
+   Copyright Farcloser.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package prometheus
+
+import (
+	"strconv"
+
+	"go.farcloser.world/containers/security/cgroups"
+	"go.farcloser.world/containers/stats"
+)
+
+// validFamily is the only family exposed for invalid entries (eg: stopped containers).
+const validFamily = "container_stats_valid"
+
+type label struct {
+	name  string
+	value string
+}
+
+type value struct {
+	labels []label
+	value  float64
+}
+
+type family struct {
+	name   string
+	help   string
+	kind   string
+	values func(sample Sample) []value
+}
+
+func families() []family {
+	return []family{
+		{validFamily, "Whether the statistics of the container could be collected.", gauge, fromEntry(
+			func(entry stats.Entry) float64 {
+				if entry.IsInvalid {
+					return 0
+				}
+
+				return 1
+			})},
+		{"container_cpu_usage_percent", "CPU usage, 100% per fully used core.", gauge, fromEntry(
+			func(entry stats.Entry) float64 { return entry.CPUPercentage })},
+		{"container_memory_working_set_bytes", "Memory usage, without the inactive page cache.", gauge, fromEntry(
+			func(entry stats.Entry) float64 { return entry.Memory })},
+		{"container_memory_limit_bytes", "Memory limit, or memory of the host if unlimited.", gauge, fromEntry(
+			func(entry stats.Entry) float64 { return entry.MemoryLimit })},
+		{"container_network_receive_bytes_total", "Bytes received by the container.", counter, fromEntry(
+			func(entry stats.Entry) float64 { return entry.NetworkRx })},
+		{"container_network_transmit_bytes_total", "Bytes sent by the container.", counter, fromEntry(
+			func(entry stats.Entry) float64 { return entry.NetworkTx })},
+		{"container_fs_reads_bytes_total", "Bytes read from block devices.", counter, fromEntry(
+			func(entry stats.Entry) float64 { return entry.BlockRead })},
+		{"container_fs_writes_bytes_total", "Bytes written to block devices.", counter, fromEntry(
+			func(entry stats.Entry) float64 { return entry.BlockWrite })},
+		{"container_pids", "Number of processes and threads.", gauge, fromEntry(
+			func(entry stats.Entry) float64 { return float64(entry.PidsCurrent) })},
+
+		{"container_cpu_usage_seconds_total", "CPU time consumed.", counter, fromMetrics(
+			func(metrics *stats.Metrics) float64 { return metrics.CPU.Usage.Seconds() })},
+		{"container_cpu_user_seconds_total", "CPU time consumed in user mode.", counter, fromMetrics(
+			func(metrics *stats.Metrics) float64 { return metrics.CPU.User.Seconds() })},
+		{"container_cpu_system_seconds_total", "CPU time consumed in kernel mode.", counter, fromMetrics(
+			func(metrics *stats.Metrics) float64 { return metrics.CPU.System.Seconds() })},
+		{"container_cpu_cfs_periods_total", "Elapsed enforcement periods of the CPU quota.", counter, fromMetrics(
+			func(metrics *stats.Metrics) float64 { return float64(metrics.CPU.Periods) })},
+		{"container_cpu_cfs_throttled_periods_total", "Throttled enforcement periods.", counter, fromMetrics(
+			func(metrics *stats.Metrics) float64 { return float64(metrics.CPU.ThrottledPeriods) })},
+		{"container_cpu_cfs_throttled_seconds_total", "Time the container was throttled for.", counter, fromMetrics(
+			func(metrics *stats.Metrics) float64 { return metrics.CPU.ThrottledTime.Seconds() })},
+		{"container_memory_usage_bytes", "Memory usage, including all caches.", gauge, fromMetrics(
+			func(metrics *stats.Metrics) float64 { return float64(metrics.Memory.Usage) })},
+		{"container_memory_max_usage_bytes", "Maximum memory usage recorded.", gauge, fromMetrics(
+			func(metrics *stats.Metrics) float64 { return float64(metrics.Memory.MaxUsage) })},
+		{"container_memory_anon_bytes", "Anonymous memory.", gauge, fromMetrics(
+			func(metrics *stats.Metrics) float64 { return float64(metrics.Memory.Anon) })},
+		{"container_memory_file_bytes", "Page cache.", gauge, fromMetrics(
+			func(metrics *stats.Metrics) float64 { return float64(metrics.Memory.File) })},
+		{"container_memory_kernel_stack_bytes", "Kernel stacks.", gauge, fromMetrics(
+			func(metrics *stats.Metrics) float64 { return float64(metrics.Memory.KernelStack) })},
+		{"container_memory_slab_bytes", "Kernel slab allocations.", gauge, fromMetrics(
+			func(metrics *stats.Metrics) float64 { return float64(metrics.Memory.Slab) })},
+		{"container_memory_sock_bytes", "Network transmission buffers.", gauge, fromMetrics(
+			func(metrics *stats.Metrics) float64 { return float64(metrics.Memory.Sock) })},
+		{"container_memory_shmem_bytes", "Shared memory.", gauge, fromMetrics(
+			func(metrics *stats.Metrics) float64 { return float64(metrics.Memory.Shmem) })},
+		{"container_memory_swap_bytes", "Swap usage.", gauge, fromMetrics(
+			func(metrics *stats.Metrics) float64 { return float64(metrics.Memory.Swap) })},
+		{"container_pids_limit", "Maximum number of processes and threads.", gauge, fromMetrics(
+			func(metrics *stats.Metrics) float64 { return float64(metrics.Pids.Limit) })},
+
+		{"container_io_read_bytes_total", "Bytes read from a block device.", counter, perDevice(
+			func(device stats.IODevice) uint64 { return device.ReadBytes })},
+		{"container_io_write_bytes_total", "Bytes written to a block device.", counter, perDevice(
+			func(device stats.IODevice) uint64 { return device.WriteBytes })},
+		{"container_io_reads_total", "Read operations on a block device.", counter, perDevice(
+			func(device stats.IODevice) uint64 { return device.ReadOps })},
+		{"container_io_writes_total", "Write operations on a block device.", counter, perDevice(
+			func(device stats.IODevice) uint64 { return device.WriteOps })},
+
+		{"container_hugetlb_usage_bytes", "Huge pages usage.", gauge, perPageSize(
+			func(usage stats.HugetlbUsage) uint64 { return usage.Usage })},
+		{"container_hugetlb_limit_bytes", "Huge pages limit.", gauge, perPageSize(
+			func(usage stats.HugetlbUsage) uint64 { return usage.Limit })},
+		{"container_rdma_hca_handles", "RDMA HCA handles in use.", gauge, perRDMADevice(
+			func(usage stats.RDMAUsage) uint32 { return usage.HCAHandles })},
+		{"container_rdma_hca_objects", "RDMA HCA objects in use.", gauge, perRDMADevice(
+			func(usage stats.RDMAUsage) uint32 { return usage.HCAObjects })},
+
+		{"container_pressure_cpu_waiting_seconds_total", "Time at least one task waited for CPU.", counter,
+			pressure(func(psi *stats.PressureMetrics) *cgroups.Pressure { return psi.CPU }, false)},
+		{"container_pressure_cpu_stalled_seconds_total", "Time all tasks waited for CPU.", counter,
+			pressure(func(psi *stats.PressureMetrics) *cgroups.Pressure { return psi.CPU }, true)},
+		{"container_pressure_memory_waiting_seconds_total", "Time at least one task waited for memory.", counter,
+			pressure(func(psi *stats.PressureMetrics) *cgroups.Pressure { return psi.Memory }, false)},
+		{"container_pressure_memory_stalled_seconds_total", "Time all tasks waited for memory.", counter,
+			pressure(func(psi *stats.PressureMetrics) *cgroups.Pressure { return psi.Memory }, true)},
+		{"container_pressure_io_waiting_seconds_total", "Time at least one task waited for IO.", counter,
+			pressure(func(psi *stats.PressureMetrics) *cgroups.Pressure { return psi.IO }, false)},
+		{"container_pressure_io_stalled_seconds_total", "Time all tasks waited for IO.", counter,
+			pressure(func(psi *stats.PressureMetrics) *cgroups.Pressure { return psi.IO }, true)},
+	}
+}
+
+func fromEntry(get func(entry stats.Entry) float64) func(sample Sample) []value {
+	return func(sample Sample) []value {
+		return []value{{value: get(sample.Entry)}}
+	}
+}
+
+func fromMetrics(get func(metrics *stats.Metrics) float64) func(sample Sample) []value {
+	return func(sample Sample) []value {
+		if sample.Metrics == nil {
+			return nil
+		}
+
+		return []value{{value: get(sample.Metrics)}}
+	}
+}
+
+func perDevice(get func(device stats.IODevice) uint64) func(sample Sample) []value {
+	return func(sample Sample) []value {
+		if sample.Metrics == nil {
+			return nil
+		}
+
+		values := make([]value, 0, len(sample.Metrics.IO))
+		for _, device := range sample.Metrics.IO {
+			id := strconv.FormatUint(device.Major, 10) + ":" + strconv.FormatUint(device.Minor, 10)
+			values = append(values, value{labels: []label{{"device", id}}, value: float64(get(device))})
+		}
+
+		return values
+	}
+}
+
+func perPageSize(get func(usage stats.HugetlbUsage) uint64) func(sample Sample) []value {
+	return func(sample Sample) []value {
+		if sample.Metrics == nil {
+			return nil
+		}
+
+		values := make([]value, 0, len(sample.Metrics.Hugetlb))
+		for _, usage := range sample.Metrics.Hugetlb {
+			values = append(values, value{labels: []label{{"pagesize", usage.PageSize}}, value: float64(get(usage))})
+		}
+
+		return values
+	}
+}
+
+func perRDMADevice(get func(usage stats.RDMAUsage) uint32) func(sample Sample) []value {
+	return func(sample Sample) []value {
+		if sample.Metrics == nil {
+			return nil
+		}
+
+		values := make([]value, 0, len(sample.Metrics.RDMA))
+		for _, usage := range sample.Metrics.RDMA {
+			values = append(values, value{labels: []label{{"device", usage.Device}}, value: float64(get(usage))})
+		}
+
+		return values
+	}
+}
+
+func pressure(get func(psi *stats.PressureMetrics) *cgroups.Pressure, full bool) func(sample Sample) []value {
+	return func(sample Sample) []value {
+		if sample.Metrics == nil || sample.Metrics.Pressure == nil {
+			return nil
+		}
+
+		resource := get(sample.Metrics.Pressure)
+		if resource == nil {
+			return nil
+		}
+
+		data := resource.Some
+		if full {
+			data = resource.Full
+		}
+
+		if data == nil {
+			return nil
+		}
+
+		return []value{{value: data.Total.Seconds()}}
+	}
+}
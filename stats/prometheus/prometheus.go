@@ -0,0 +1,158 @@
+/*
+   Copyright Farcloser.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// Package prometheus exposes container statistics in the Prometheus text exposition format, or in OpenMetrics.
+// Metrics are named after cAdvisor ones where there is an equivalent.
+package prometheus
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"go.farcloser.world/containers/stats"
+)
+
+type Format string
+
+const (
+	// TextFormat is the Prometheus text format, which the node-exporter textfile collector reads.
+	TextFormat Format = "text/plain; version=0.0.4; charset=utf-8"
+	// OpenMetricsFormat is the OpenMetrics text format.
+	OpenMetricsFormat Format = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+
+	openMetricsType = "application/openmetrics-text"
+	counterSuffix   = "_total"
+
+	counter = "counter"
+	gauge   = "gauge"
+)
+
+var ErrCannotGather = errors.New("cannot gather container statistics")
+
+// Sample is the statistics of a container, and the labels to expose them with.
+type Sample struct {
+	ID    string
+	Name  string
+	Image string
+	Entry stats.Entry
+	// Metrics is optional: only the Entry values are exposed without it.
+	Metrics *stats.Metrics
+}
+
+// Gatherer returns the samples to expose on each scrape (eg: from a stats.Monitor).
+type Gatherer func(ctx context.Context) ([]Sample, error)
+
+// Handler serves the samples of a Gatherer, in OpenMetrics if the scraper accepts it.
+type Handler struct {
+	gather Gatherer
+}
+
+func NewHandler(gather Gatherer) *Handler {
+	return &Handler{gather: gather}
+}
+
+func (h *Handler) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	samples, err := h.gather(request.Context())
+	if err != nil {
+		http.Error(writer, errors.Join(ErrCannotGather, err).Error(), http.StatusInternalServerError)
+
+		return
+	}
+
+	format := TextFormat
+	if strings.Contains(request.Header.Get("Accept"), openMetricsType) {
+		format = OpenMetricsFormat
+	}
+
+	writer.Header().Set("Content-Type", string(format))
+
+	_ = Write(writer, samples, format)
+}
+
+// Write writes `samples` in `format`.
+func Write(writer io.Writer, samples []Sample, format Format) error {
+	buffered := bufio.NewWriter(writer)
+
+	for _, family := range families() {
+		name := family.name
+		if format == OpenMetricsFormat && family.kind == counter {
+			name = strings.TrimSuffix(name, counterSuffix)
+		}
+
+		var lines []string
+
+		for _, sample := range samples {
+			if sample.Entry.IsInvalid && family.name != validFamily {
+				continue
+			}
+
+			for _, value := range family.values(sample) {
+				lines = append(lines, family.name+formatLabels(sample, value.labels)+" "+formatValue(value.value))
+			}
+		}
+
+		if len(lines) == 0 {
+			continue
+		}
+
+		fmt.Fprintf(buffered, "# HELP %s %s\n# TYPE %s %s\n", name, family.help, name, family.kind)
+
+		for _, line := range lines {
+			fmt.Fprintln(buffered, line)
+		}
+	}
+
+	if format == OpenMetricsFormat {
+		fmt.Fprintln(buffered, "# EOF")
+	}
+
+	return buffered.Flush()
+}
+
+func formatLabels(sample Sample, extra []label) string {
+	labels := append([]label{{"id", sample.ID}, {"name", sample.Name}, {"image", sample.Image}}, extra...)
+	pairs := make([]string, 0, len(labels))
+
+	for _, label := range labels {
+		pairs = append(pairs, label.name+`="`+escape(label.value)+`"`)
+	}
+
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func escape(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+func formatValue(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
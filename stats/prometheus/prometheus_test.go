@@ -0,0 +1,120 @@
+/*
+   Copyright Farcloser.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package prometheus_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+
+	"go.farcloser.world/containers/security/cgroups"
+	"go.farcloser.world/containers/stats"
+	"go.farcloser.world/containers/stats/prometheus"
+)
+
+var errGather = errors.New("cannot gather")
+
+func samples() []prometheus.Sample {
+	return []prometheus.Sample{
+		{
+			ID:    "4f1c2e3d",
+			Name:  "web",
+			Image: `docker.io/library/nginx:latest`,
+			Entry: stats.Entry{CPUPercentage: 12.5, Memory: 1024, NetworkRx: 2048, PidsCurrent: 3},
+			Metrics: &stats.Metrics{
+				CPU: stats.CPUMetrics{Usage: 1500 * time.Millisecond},
+				IO:  []stats.IODevice{{Major: 8, Minor: 16, ReadBytes: 4096}},
+				Pressure: &stats.PressureMetrics{
+					Memory: &cgroups.Pressure{Some: &cgroups.PressureData{Total: 250 * time.Millisecond}},
+				},
+			},
+		},
+		{
+			ID:    "0a1b2c3d",
+			Name:  `odd"name`,
+			Entry: stats.Entry{IsInvalid: true},
+		},
+	}
+}
+
+func TestWrite(t *testing.T) {
+	t.Parallel()
+
+	var buffer bytes.Buffer
+
+	assert.NilError(t, prometheus.Write(&buffer, samples(), prometheus.TextFormat))
+
+	output := buffer.String()
+	for _, expected := range []string{
+		"# HELP container_stats_valid Whether the statistics of the container could be collected.\n" +
+			"# TYPE container_stats_valid gauge\n" +
+			`container_stats_valid{id="4f1c2e3d",name="web",image="docker.io/library/nginx:latest"} 1` + "\n" +
+			`container_stats_valid{id="0a1b2c3d",name="odd\"name",image=""} 0` + "\n",
+		"# TYPE container_cpu_usage_percent gauge\n" +
+			`container_cpu_usage_percent{id="4f1c2e3d",name="web",image="docker.io/library/nginx:latest"} 12.5` + "\n",
+		"# TYPE container_network_receive_bytes_total counter\n" +
+			`container_network_receive_bytes_total{id="4f1c2e3d",name="web",image="docker.io/library/nginx:latest"} 2048`,
+		`container_cpu_usage_seconds_total{id="4f1c2e3d",name="web",image="docker.io/library/nginx:latest"} 1.5`,
+		`container_io_read_bytes_total{id="4f1c2e3d",name="web",image="docker.io/library/nginx:latest",device="8:16"} 4096`,
+		`container_pressure_memory_waiting_seconds_total{id="4f1c2e3d",name="web",image="docker.io/library/nginx:latest"} 0.25`,
+	} {
+		assert.Assert(t, strings.Contains(output, expected), "missing %q in:\n%s", expected, output)
+	}
+
+	// Invalid entries and missing metrics are not exposed
+	assert.Assert(t, !strings.Contains(output, `container_pids{id="0a1b2c3d"`))
+	assert.Assert(t, !strings.Contains(output, "container_pressure_cpu"))
+	assert.Assert(t, !strings.Contains(output, "# EOF"))
+}
+
+func TestHandler(t *testing.T) {
+	t.Parallel()
+
+	handler := prometheus.NewHandler(func(context.Context) ([]prometheus.Sample, error) {
+		return samples(), nil
+	})
+
+	request := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	request.Header.Set("Accept", "application/openmetrics-text;version=1.0.0,text/plain;q=0.5")
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+
+	assert.Equal(t, recorder.Code, http.StatusOK)
+	assert.Equal(t, recorder.Header().Get("Content-Type"), string(prometheus.OpenMetricsFormat))
+
+	output := recorder.Body.String()
+	assert.Assert(t, strings.HasSuffix(output, "# EOF\n"))
+	// Counter families are named without their suffix in OpenMetrics
+	assert.Assert(t, strings.Contains(output, "# TYPE container_cpu_usage_seconds counter\n"+
+		"container_cpu_usage_seconds_total{"))
+
+	failing := prometheus.NewHandler(func(context.Context) ([]prometheus.Sample, error) {
+		return nil, errGather
+	})
+
+	recorder = httptest.NewRecorder()
+	failing.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, recorder.Code, http.StatusInternalServerError)
+}
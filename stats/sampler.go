@@ -0,0 +1,119 @@
+/*
+   Copyright Farcloser.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package stats
+
+import (
+	"math"
+	"runtime"
+
+	"go.farcloser.world/containers/security/cgroups"
+	"go.farcloser.world/containers/specs"
+)
+
+// CPUUsage is the CPU usage of a container between two samples.
+type CPUUsage struct {
+	// PerCore counts 100% for each fully used core, as top and docker stats do.
+	PerCore float64
+	// Normalized is relative to the CPUs the container may use, from 0 to 100%.
+	Normalized float64
+	// User and System split PerCore between user and kernel mode.
+	User   float64
+	System float64
+}
+
+// Sampler computes the CPU usage of a container from successive samples of its metrics.
+// It is not safe for concurrent use.
+type Sampler struct {
+	cpus     float64
+	previous *Metrics
+}
+
+// NewSampler returns a sampler for a container that may use `cpus` CPUs (see EffectiveCPUs).
+// All online CPUs are assumed if `cpus` is not positive.
+func NewSampler(cpus float64) *Sampler {
+	if cpus <= 0 {
+		cpus = float64(runtime.NumCPU())
+	}
+
+	return &Sampler{cpus: cpus}
+}
+
+// Sample records `metrics` and returns the CPU usage since the previous sample.
+// The returned boolean is false for the first sample, or if the usage went down (eg: the container restarted), as
+// there is nothing to compare to then.
+func (s *Sampler) Sample(metrics *Metrics) (CPUUsage, bool) {
+	previous := s.previous
+	s.previous = metrics
+
+	if previous == nil || metrics.CPU.Usage < previous.CPU.Usage {
+		return CPUUsage{}, false
+	}
+
+	elapsed := metrics.Time.Sub(previous.Time)
+	if elapsed <= 0 {
+		return CPUUsage{}, false
+	}
+
+	ratio := func(current, last int64) float64 {
+		if current < last {
+			return 0
+		}
+
+		return float64(current-last) / float64(elapsed) * percent
+	}
+
+	usage := CPUUsage{
+		PerCore: ratio(int64(metrics.CPU.Usage), int64(previous.CPU.Usage)),
+		User:    ratio(int64(metrics.CPU.User), int64(previous.CPU.User)),
+		System:  ratio(int64(metrics.CPU.System), int64(previous.CPU.System)),
+	}
+	usage.Normalized = math.Min(usage.PerCore/s.cpus, percent)
+
+	return usage, true
+}
+
+// Reset forgets the previous sample.
+func (s *Sampler) Reset() {
+	s.previous = nil
+}
+
+// EffectiveCPUs returns the number of CPUs a container may use: the lowest of its cpuset, its quota, and the `online`
+// CPUs of the host.
+func EffectiveCPUs(cpu *specs.LinuxCPU, online int) float64 {
+	cpus := float64(online)
+
+	if cpu == nil {
+		return cpus
+	}
+
+	if cpu.Cpus != "" {
+		if ids, err := cgroups.ParseSet(cpu.Cpus); err == nil && len(ids) > 0 {
+			cpus = math.Min(cpus, float64(len(ids)))
+		}
+	}
+
+	if cpu.Quota != nil && *cpu.Quota > 0 {
+		period := uint64(defaultCPUPeriod)
+		if cpu.Period != nil && *cpu.Period > 0 {
+			period = *cpu.Period
+		}
+
+		cpus = math.Min(cpus, float64(*cpu.Quota)/float64(period))
+	}
+
+	return cpus
+}
@@ -0,0 +1,79 @@
+/*
+   Copyright Farcloser.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package stats_test
+
+import (
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+
+	"go.farcloser.world/containers/specs"
+	"go.farcloser.world/containers/stats"
+)
+
+func TestSampler(t *testing.T) {
+	t.Parallel()
+
+	start := time.Now()
+	sample := func(offset time.Duration, user time.Duration, system time.Duration) *stats.Metrics {
+		return &stats.Metrics{
+			Time: start.Add(offset),
+			CPU:  stats.CPUMetrics{Usage: user + system, User: user, System: system},
+		}
+	}
+
+	sampler := stats.NewSampler(4)
+
+	_, ok := sampler.Sample(sample(0, time.Second, time.Second))
+	assert.Assert(t, !ok)
+
+	// 3 cores used over a second
+	usage, ok := sampler.Sample(sample(time.Second, 3*time.Second, 2*time.Second))
+	assert.Assert(t, ok)
+	assert.DeepEqual(t, usage, stats.CPUUsage{PerCore: 300, Normalized: 75, User: 200, System: 100})
+
+	// The container restarted
+	_, ok = sampler.Sample(sample(2*time.Second, 0, 0))
+	assert.Assert(t, !ok)
+
+	usage, ok = sampler.Sample(sample(4*time.Second, time.Second, 0))
+	assert.Assert(t, ok)
+	assert.Equal(t, usage.PerCore, 50.0)
+	assert.Equal(t, usage.Normalized, 12.5)
+}
+
+func TestEffectiveCPUs(t *testing.T) {
+	t.Parallel()
+
+	quota := int64(150000)
+	period := uint64(50000)
+
+	for _, tc := range []struct {
+		cpu      *specs.LinuxCPU
+		expected float64
+	}{
+		{nil, 8},
+		{&specs.LinuxCPU{Cpus: "0-1,4"}, 3},
+		{&specs.LinuxCPU{Quota: &quota}, 1.5},
+		{&specs.LinuxCPU{Quota: &quota, Period: &period}, 3},
+		{&specs.LinuxCPU{Cpus: "0-1", Quota: &quota}, 1.5},
+		{&specs.LinuxCPU{Cpus: "0-15"}, 8},
+	} {
+		assert.Equal(t, stats.EffectiveCPUs(tc.cpu, 8), tc.expected)
+	}
+}
@@ -20,11 +20,20 @@ import (
 	"errors"
 	"sync"
 	"time"
+
+	"go.farcloser.world/containers/netlink"
+)
+
+const (
+	percent = 100.0
+	// defaultCPUPeriod is the cpu.max period, in microseconds, when only the quota is set.
+	defaultCPUPeriod = 100000
 )
 
 var (
 	ErrFailedConversion = errors.New("cannot convert metric data to cgroups.Metrics")
 	ErrEmptyMetrics     = errors.New("nothing in provided metric")
+	ErrSystemCPU        = errors.New("cannot read the host CPU usage")
 )
 
 // Entry represents the statistics data collected from a container.
@@ -40,10 +49,12 @@ type Entry struct {
 	BlockRead        float64
 	BlockWrite       float64
 	PidsCurrent      uint64
-	IsInvalid        bool
+	// Networks are the counters of each interface, which NetworkRx and NetworkTx are the totals of.
+	Networks  []netlink.InterfaceStats
+	IsInvalid bool
 }
 
-// ContainerStats represents the runtime container stats.
+// ContainerStats represents the runtime container stats, as of the previous sample.
 type ContainerStats struct {
 	Time          time.Time
 	Cgroup2CPU    uint64
@@ -99,6 +110,7 @@ func (cs *Stats) SetErrorAndReset(err error) {
 	cs.BlockRead = 0
 	cs.BlockWrite = 0
 	cs.PidsCurrent = 0
+	cs.Networks = nil
 	cs.err = err
 	cs.IsInvalid = true
 }
@@ -113,3 +125,13 @@ func (cs *Stats) SetError(err error) {
 		cs.IsInvalid = true
 	}
 }
+
+func calculateMemPercent(limit float64, usedNo float64) float64 {
+	// Limit will never be 0 unless the container is not running, and we haven't
+	// got any data from cgroup
+	if limit != 0 {
+		return usedNo / limit * percent
+	}
+
+	return 0
+}
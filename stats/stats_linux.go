@@ -18,83 +18,100 @@ package stats
 
 import (
 	"bufio"
+	"math"
 	"os"
 	"strconv"
 	"strings"
-	"time"
 
 	"go.farcloser.world/containers/netlink"
 	"go.farcloser.world/containers/security/cgroups"
 )
 
 const (
-	microsecPerSecond = 1000
-	percent           = 100.0
-	kiloPerMega       = 1024
-	procMemInfoPath   = "/proc/meminfo"
-	memoryMaxLimit    = float64(^uint64(0))
+	kiloPerMega     = 1024
+	procMemInfoPath = "/proc/meminfo"
 )
 
-func SetCgroup2StatsFields(previousStats *ContainerStats, anydata interface{}, pid int) (Entry, error) {
+// Cgroup2Metrics returns the metrics of a container, from its cgroup v2 metrics and the links of its network
+// namespace. An unlimited memory is reported as the memory of the host.
+func Cgroup2Metrics(anydata interface{}, pid int) (*Metrics, error) {
 	var metrics *cgroups.Metrics
 
 	switch v := anydata.(type) {
 	case *cgroups.Metrics:
 		metrics = v
 	default:
-		return Entry{}, ErrFailedConversion
+		return nil, ErrFailedConversion
+	}
+
+	res, err := FromCgroup2(metrics)
+	if err != nil {
+		return nil, err
 	}
 
-	if metrics == nil {
-		return Entry{}, ErrEmptyMetrics
+	return res, addHostMetrics(res, pid)
+}
+
+// Cgroup1Metrics returns the metrics of a container, from its cgroup v1 metrics and the links of its network
+// namespace. A memory limit over the memory of the host is reported as the latter.
+func Cgroup1Metrics(anydata interface{}, pid int) (*Metrics, error) {
+	var metrics *cgroups.MetricsV1
+
+	switch v := anydata.(type) {
+	case *cgroups.MetricsV1:
+		metrics = v
+	default:
+		return nil, ErrFailedConversion
 	}
 
-	links, err := netlink.GetNetNsLinks(pid)
+	res, err := FromCgroup1(metrics)
 	if err != nil {
-		return Entry{}, err
+		return nil, err
+	}
+
+	return res, addHostMetrics(res, pid)
+}
+
+// addHostMetrics adds what the cgroup metrics lack: network metrics, and the memory actually available to containers
+// without a limit of their own (eg: limited by their parent slice, or by the memory of the host).
+func addHostMetrics(metrics *Metrics, pid int) error {
+	interfaces, err := netlink.GetNetNsInterfaceStats(pid, netlink.LinkFilter{})
+	if err != nil {
+		return err
 	}
 
-	netRx, netTx := netlink.StatsForLinks(links)
-	blkRead, blkWrite := cgroups.CalculateIO(metrics)
-	mem := cgroups.CalculateMemUsage(metrics)
+	netRx, netTx := netlink.TotalsForInterfaces(interfaces)
+	metrics.Network = NetworkMetrics{RxBytes: netRx, TxBytes: netTx, Interfaces: interfaces}
 
-	memLimit := float64(metrics.GetMemory().GetUsageLimit())
-	if memLimit == memoryMaxLimit {
-		memLimit = getHostMemLimit()
+	available := getHostMemLimit()
+	if limit, err := cgroups.EffectiveMemoryLimitForPID(pid); err == nil {
+		available = limit.Max
 	}
 
-	memPercent := calculateMemPercent(memLimit, mem)
-	pidsStatsCurrent := metrics.GetPids().GetCurrent()
-
-	cpuPercent := calculateCgroup2CPUPercent(previousStats, metrics)
-
-	return Entry{
-		CPUPercentage:    cpuPercent,
-		Memory:           mem,
-		MemoryPercentage: memPercent,
-		MemoryLimit:      memLimit,
-		NetworkRx:        netRx,
-		NetworkTx:        netTx,
-		BlockRead:        float64(blkRead),
-		BlockWrite:       float64(blkWrite),
-		PidsCurrent:      pidsStatsCurrent,
-	}, nil
+	metrics.Memory.Limit = min(metrics.Memory.Limit, available)
+
+	return nil
 }
 
-func calculateMemPercent(limit float64, usedNo float64) float64 {
-	// Limit will never be 0 unless the container is not running, and we haven't
-	// got any data from cgroup
-	if limit != 0 {
-		return usedNo / limit * percent
+// SetCgroup2StatsFields returns the entry for the cgroup v2 metrics of a container.
+// The CPU percentage is computed since `previousStats`, which is then updated with this sample; it is per core (see
+// Sampler).
+func SetCgroup2StatsFields(previousStats *ContainerStats, anydata interface{}, pid int) (Entry, error) {
+	res, err := Cgroup2Metrics(anydata, pid)
+	if err != nil {
+		return Entry{}, err
 	}
 
-	return 0
+	entry := res.Entry()
+	entry.CPUPercentage = calculateCgroup2CPUPercent(previousStats, res)
+
+	return entry, nil
 }
 
-func getHostMemLimit() float64 {
+func getHostMemLimit() uint64 {
 	file, err := os.Open(procMemInfoPath)
 	if err != nil {
-		return memoryMaxLimit
+		return math.MaxUint64
 	}
 	defer file.Close()
 
@@ -105,7 +122,7 @@ func getHostMemLimit() float64 {
 			if len(fields) > 1 {
 				memKb, err := strconv.ParseUint(fields[1], 10, 64)
 				if err == nil {
-					return float64(memKb * kiloPerMega) // kB to bytes
+					return memKb * kiloPerMega // kB to bytes
 				}
 			}
 
@@ -113,25 +130,29 @@ func getHostMemLimit() float64 {
 		}
 	}
 
-	return float64(^uint64(0))
+	return math.MaxUint64
 }
 
 // PercpuUsage is not supported in CgroupV2.
-func calculateCgroup2CPUPercent(previousStats *ContainerStats, metrics *cgroups.Metrics) float64 {
+func calculateCgroup2CPUPercent(previousStats *ContainerStats, metrics *Metrics) float64 {
+	if previousStats == nil {
+		return 0
+	}
+
 	var (
 		cpuPercent = 0.0
-		cpu        = metrics.GetCPU()
-		// calculate the change for the cpu usage of the container in between readings
-		cpuDelta = float64(cpu.GetUsageUsec()*microsecPerSecond) - float64(previousStats.Cgroup2CPU)
-		// calculate the change for the entire system between readings
-		_ = float64(cpu.GetSystemUsec()*microsecPerSecond) - float64(previousStats.Cgroup2System)
-		// time duration
-		timeDelta = time.Since(previousStats.Time)
+		cpu        = uint64(metrics.CPU.Usage.Nanoseconds())
+		cpuDelta   = float64(cpu) - float64(previousStats.Cgroup2CPU)
+		timeDelta  = metrics.Time.Sub(previousStats.Time)
 	)
 
-	if cpuDelta > 0.0 {
+	if !previousStats.Time.IsZero() && cpuDelta > 0.0 && timeDelta > 0 {
 		cpuPercent = cpuDelta / float64(timeDelta.Nanoseconds()) * percent
 	}
 
+	previousStats.Time = metrics.Time
+	previousStats.Cgroup2CPU = cpu
+	previousStats.Cgroup2System = uint64(metrics.CPU.System.Nanoseconds())
+
 	return cpuPercent
 }
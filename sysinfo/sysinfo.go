@@ -30,6 +30,9 @@ type SysInfo struct {
 	// Whether IPv4 forwarding is supported or not, if this was disabled, networking will not work
 	IPv4ForwardingDisabled bool
 
+	// The memory available to the cgroup, given the limits of its ancestors. Nil if it could not be read
+	Memory *cgroups.MemoryLimit
+
 	// Warnings contains a slice of warnings that occurred  while collecting
 	// system information. These warnings are intended to be informational
 	// messages for the user, and can either be logged or returned to the
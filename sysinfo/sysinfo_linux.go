@@ -21,9 +21,8 @@ import (
 	"path/filepath"
 	"strings"
 
-	"github.com/containerd/containerd/v2/pkg/seccomp"
-
 	"go.farcloser.world/containers/security/cgroups"
+	"go.farcloser.world/containers/security/seccomp"
 )
 
 const (
@@ -31,12 +30,23 @@ const (
 	appArmorPath   = "/sys/kernel/security/apparmor"
 )
 
+// New probes the host for supported features, using the cgroup at `path` (relative to the cgroup mountpoint).
 func New(path string) (*SysInfo, []error, error) {
+	return NewAt("/", path)
+}
+
+// NewAt probes the host filesystem mounted at `root` (eg: a chroot, or the host as seen from a container) for
+// supported features, using the cgroup at `path`.
+func NewAt(root string, path string) (*SysInfo, []error, error) {
+	if root == "" {
+		root = "/"
+	}
+
 	if path == "" {
 		path = "/"
 	}
 
-	info, warnings, err := cgroups.New(path)
+	info, warnings, err := cgroups.NewAt(root, path)
 	if err != nil {
 		return nil, warnings, err
 	}
@@ -45,15 +55,19 @@ func New(path string) (*SysInfo, []error, error) {
 		Info: *info,
 	}
 
-	sysInfo.IPv4ForwardingDisabled = !readProcBool(filepath.Join(procSysNetPath, "ipv4/ip_forward"))
+	sysInfo.IPv4ForwardingDisabled = !readProcBool(filepath.Join(root, procSysNetPath, "ipv4/ip_forward"))
 
-	if _, err = os.Stat(appArmorPath); !os.IsNotExist(err) {
-		if _, err = os.ReadFile(filepath.Join(appArmorPath, "profiles")); err == nil {
+	if _, err = os.Stat(filepath.Join(root, appArmorPath)); !os.IsNotExist(err) {
+		if _, err = os.ReadFile(filepath.Join(root, appArmorPath, "profiles")); err == nil {
 			sysInfo.AppArmor = true
 		}
 	}
 
-	sysInfo.Seccomp = seccomp.IsEnabled()
+	sysInfo.Seccomp = seccomp.SupportedAt(root)
+
+	if sysInfo.Memory, err = cgroups.EffectiveMemoryLimitAt(root, path); err != nil {
+		warnings = append(warnings, err)
+	}
 
 	return sysInfo, warnings, nil
 }
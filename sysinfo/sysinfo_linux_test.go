@@ -31,6 +31,7 @@ import (
 	"golang.org/x/sys/unix"
 	"gotest.tools/v3/assert"
 
+	"go.farcloser.world/containers/internal/fixtures"
 	"go.farcloser.world/containers/sysinfo"
 )
 
@@ -109,6 +110,36 @@ func TestNewCgroupNamespacesDisabled(t *testing.T) {
 	assert.Assert(t, !sysInfo.CgroupNamespaces)
 }
 
+func TestNewAt(t *testing.T) {
+	t.Parallel()
+
+	for name, expected := range map[string]struct {
+		ipv4ForwardingDisabled bool
+		appArmor               bool
+		seccomp                bool
+		memory                 uint64
+	}{
+		fixtures.CgroupV1Legacy:    {true, false, false, 16 << 30},
+		fixtures.CgroupV2Systemd:   {false, true, true, 8 << 30},
+		fixtures.CgroupV2NoSystemd: {true, false, true, 0},
+		fixtures.CgroupV2Rootless:  {false, false, true, 0},
+		fixtures.WSL:               {false, false, true, 0},
+	} {
+		sysInfo, _, err := sysinfo.NewAt(fixtures.Host(name), "/")
+		assert.NilError(t, err, name)
+		assert.Equal(t, sysInfo.IPv4ForwardingDisabled, expected.ipv4ForwardingDisabled, name)
+		assert.Equal(t, sysInfo.AppArmor, expected.appArmor, name)
+		assert.Equal(t, sysInfo.Seccomp, expected.seccomp, name)
+
+		// Fixtures without /proc/meminfo have no memory information
+		if expected.memory == 0 {
+			assert.Assert(t, sysInfo.Memory == nil, name)
+		} else {
+			assert.Equal(t, sysInfo.Memory.Max, expected.memory, name)
+		}
+	}
+}
+
 func TestNumCPU(t *testing.T) {
 	t.Parallel()
 
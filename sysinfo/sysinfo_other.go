@@ -29,3 +29,8 @@ package sysinfo // import "github.com/docker/docker/pkg/sysinfo"
 func New(_ string) *SysInfo {
 	return &SysInfo{}
 }
+
+// NewAt returns an empty SysInfo for non linux for now.
+func NewAt(_ string, _ string) *SysInfo {
+	return &SysInfo{}
+}